@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/compare"
+	"github.com/nemoremold/perftests/pkg/metrics"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+// compare compares the results of two runs, e.g. before and after changing an etcd flag.
+// Results are either CSV reports (`--export_to_csv`), histogram dumps (`--export_histograms`),
+// result documents (`--export_result`) or JSON time series exports of single tests
+// (`--export_timeseries`) of the perftests program; confidence intervals and significance
+// tests are only available when both results are histogram dumps or time series exports.
+func main() {
+	var (
+		baselinePath        string
+		candidatePath       string
+		exportPath          string
+		quantilesStr        []string
+		verbs               []string
+		confidence          float64
+		bootstrapIterations int
+		seed                int64
+		outputStyle         string
+	)
+
+	defaultQuantiles := make([]string, 0, len(metrics.DefaultQuantiles))
+	for _, quantile := range metrics.DefaultQuantiles {
+		defaultQuantiles = append(defaultQuantiles, strconv.FormatFloat(quantile, 'f', -1, 64))
+	}
+
+	pflag.StringVarP(&baselinePath, "baseline", "b", "", "path to the baseline result (a csv report, a histograms json file, a result json document or a json time series export)")
+	pflag.StringVarP(&candidatePath, "candidate", "c", "", "path to the candidate result (a csv report, a histograms json file, a result json document or a json time series export)")
+	pflag.StringVarP(&exportPath, "export", "o", "", "path to the csv file the comparison is exported to, not exported when empty")
+	pflag.StringSliceVarP(&quantilesStr, "quantiles", "q", defaultQuantiles, "comma-separated latency quantiles to be compared, only valid for histograms")
+	pflag.StringSliceVarP(&verbs, "verbs", "", nil, "comma-separated verbs to be compared, all verbs are compared when empty")
+	pflag.Float64VarP(&confidence, "confidence", "", 0.95, "confidence level of bootstrap confidence intervals")
+	pflag.IntVarP(&bootstrapIterations, "bootstrap_iterations", "", 1000, "number of bootstrap resamples used to estimate confidence intervals")
+	pflag.Int64VarP(&seed, "seed", "", 1, "seed of the random number generator used for bootstrapping")
+	pflag.StringVarP(&outputStyle, "output_style", "", "ascii", "style the comparison is printed to the console in, one of "+strings.Join(printer.RendererNames(), ", "))
+
+	fs := flag.NewFlagSet("klog", flag.ExitOnError)
+	klog.InitFlags(fs)
+	pflag.CommandLine.AddGoFlagSet(fs)
+	pflag.Parse()
+
+	if len(baselinePath) == 0 || len(candidatePath) == 0 {
+		klog.Fatal("both '--baseline' and '--candidate' must be provided")
+	}
+	if confidence <= 0 || confidence >= 1 {
+		klog.Fatalf("%v is not a valid confidence level (should be in range (0, 1))", confidence)
+	}
+	renderer, ok := printer.Renderers[outputStyle]
+	if !ok {
+		klog.Fatalf("%v is not a valid output style (should be one of %v)", outputStyle, strings.Join(printer.RendererNames(), ", "))
+	}
+	printer.Default = renderer
+
+	var quantiles []float64
+	for _, quantileStr := range quantilesStr {
+		quantile, err := strconv.ParseFloat(quantileStr, 64)
+		if err != nil || quantile < 0 || quantile > 1 {
+			klog.Fatalf("%v is not a valid quantile (should be in range [0, 1])", quantileStr)
+		}
+		quantiles = append(quantiles, quantile)
+	}
+
+	baseline, err := compare.Load(baselinePath, quantiles)
+	if err != nil {
+		klog.Fatalf("failed to load baseline: %v", err.Error())
+	}
+	candidate, err := compare.Load(candidatePath, quantiles)
+	if err != nil {
+		klog.Fatalf("failed to load candidate: %v", err.Error())
+	}
+
+	rows := compare.Compare(baseline, candidate, compare.Config{
+		Verbs:               verbs,
+		Confidence:          confidence,
+		BootstrapIterations: bootstrapIterations,
+		Seed:                seed,
+	})
+	if len(rows) == 0 {
+		klog.Fatal("no common latency-percent pair and verb found in baseline and candidate")
+	}
+
+	compare.Summary(rows, baselinePath, candidatePath, confidence)
+
+	if len(exportPath) > 0 {
+		klog.V(2).Infof("writing comparison to %v", exportPath)
+		if err := compare.ExportCSV(context.Background(), rows, exportPath); err != nil {
+			klog.Fatalf("failed to export to file %v: %v", exportPath, err.Error())
+		}
+		klog.V(2).Infof("successfully wrote comparison to %v", exportPath)
+	}
+}
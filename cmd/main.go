@@ -2,30 +2,84 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
 
+	"github.com/nemoremold/perftests/pkg/metrics"
 	"github.com/nemoremold/perftests/pkg/options"
+	"github.com/nemoremold/perftests/pkg/slo"
 	"github.com/nemoremold/perftests/pkg/testflow"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
 )
 
+// sloViolationExitCode is the exit code when the tests finished but SLO assertions failed.
+const sloViolationExitCode = 3
+
 func parseFlags(opts *options.Options) {
+	pflag.StringVarP(&opts.ArchiveFolderPath, "archive_folder", "", opts.ArchiveFolderPath, "path to the results archive folder the result document of the run is appended to (created if missing), keyed by run id, cluster and tags, see the trend command; not archived when empty")
+	pflag.BoolVarP(&opts.Baseline, "baseline", "", opts.Baseline, "when set to true, tests the cluster without any IOChaos first and reports every latency-percent pair as slowdown factors relative to it")
+	pflag.BoolVarP(&opts.BaselineRerun, "baseline_rerun", "", opts.BaselineRerun, "when set to true, tests the cluster without any IOChaos again at the end of the run to detect drift (requires --baseline)")
 	pflag.IntVarP(&opts.ChaosAgentPollIntervalInSeconds, "chaos_agent_poll_interval", "", opts.ChaosAgentPollIntervalInSeconds, "interval in seconds between polls when waiting for IOChaos status change")
 	pflag.IntVarP(&opts.ChaosAgentPollTimeoutInSeconds, "chaos_agent_poll_timeout", "", opts.ChaosAgentPollTimeoutInSeconds, "timeout in seconds between polls when waiting for IOChaos status change")
 	pflag.StringVarP(&opts.ChaosAgentIOChaosTemplateFilePath, "chaos_agent_template", "", opts.ChaosAgentIOChaosTemplateFilePath, "path to the template IOChaos file")
-	pflag.StringVarP(&opts.ExportFolderPath, "export_folder_path", "f", opts.ExportFolderPath, "path to the folder where exported reports will be saved, only valid when '--write_to_csv' is true")
+	pflag.StringVarP(&opts.Cluster, "cluster", "", opts.Cluster, "name of the cluster under test, identifying the run in the results archive")
+	pflag.StringVarP(&opts.CSVLayout, "csv_layout", "", opts.CSVLayout, "layout of the exported csv report, either 'stacked' (one table per percent and verb, one column per latency) or 'long' (one row per test, verb and metric)")
+	pflag.BoolVarP(&opts.Dashboard, "dashboard", "", opts.Dashboard, "show a live progress dashboard refreshed in place when stdout is a terminal, log periodic plain-text progress lines otherwise")
+	pflag.DurationVarP(&opts.DashboardLineInterval, "dashboard_line_interval", "", opts.DashboardLineInterval, "interval between plain-text progress lines, when the live progress dashboard is not drawn")
+	pflag.DurationVarP(&opts.DashboardRefreshInterval, "dashboard_refresh_interval", "", opts.DashboardRefreshInterval, "interval between refreshes of the live progress dashboard")
+	pflag.StringVarP(&opts.EtcdCAFilePath, "etcd_cacert", "", opts.EtcdCAFilePath, "path to the CA file used to verify etcd members when scraping their metrics")
+	pflag.StringVarP(&opts.EtcdCertFilePath, "etcd_cert", "", opts.EtcdCertFilePath, "path to the client certificate file used to scrape the metrics of etcd members")
+	pflag.BoolVarP(&opts.EtcdInsecureSkipVerify, "etcd_insecure_skip_verify", "", opts.EtcdInsecureSkipVerify, "skip verifying the certificates of etcd members when scraping their metrics")
+	pflag.StringVarP(&opts.EtcdKeyFilePath, "etcd_key", "", opts.EtcdKeyFilePath, "path to the client key file used to scrape the metrics of etcd members")
+	pflag.StringSliceVarP(&opts.EtcdMetricsEndpoints, "etcd_metrics_endpoints", "", opts.EtcdMetricsEndpoints, "comma-separated client URLs of etcd members (e.g. 'https://10.0.0.1:2379') whose WAL fsync and backend commit latencies, leader changes and failed proposals are scraped before and after every test, disabled when empty")
+	pflag.StringVarP(&opts.ExportFolderPath, "export_folder_path", "f", opts.ExportFolderPath, "path to the folder where exported reports will be saved, only valid when '--export_to_csv', '--export_html', '--export_histograms', '--export_junit', '--export_markdown', '--export_result' or '--export_timeseries' is true")
+	pflag.BoolVarP(&opts.ExportHTML, "export_html", "", opts.ExportHTML, "export a self-contained html report with charts of latency quantiles, the heatmap chosen by '--heatmap_quantile' and '--heatmap_verb' and success rates to a html file")
+	pflag.BoolVarP(&opts.ExportHistograms, "export_histograms", "", opts.ExportHistograms, "export the latency histograms of every test to a json file, which can be merged or compared afterwards")
+	pflag.BoolVarP(&opts.ExportJUnit, "export_junit", "", opts.ExportJUnit, "export the SLO evaluations to a JUnit XML file, only valid when SLO assertions are configured")
+	pflag.BoolVarP(&opts.ExportMarkdown, "export_markdown", "", opts.ExportMarkdown, "export the final matrix and the summary of every test to a markdown file, e.g. to be posted in code reviews")
+	pflag.BoolVarP(&opts.ExportResult, "export_result", "", opts.ExportResult, "export a versioned json document of the run metadata, options and the per-verb results of every test, for downstream tools")
+	pflag.BoolVarP(&opts.ExportTimeSeries, "export_timeseries", "", opts.ExportTimeSeries, "export the per-interval request counts, error counts and latency quantiles of every test to a file")
 	pflag.StringVarP(&opts.IOChaosKubeconfigFilePath, "chaos_agent_kubeconfig", "c", opts.IOChaosKubeconfigFilePath, "path to the kubeconfig file used by chaos agent")
+	pflag.BoolVarP(&opts.Heatmap, "heatmap", "", opts.Heatmap, "print the heatmap of a latency quantile of a verb across every latency-percent pair once every test has finished, graded by color on color terminals")
+	pflag.Float64VarP(&opts.HeatmapQuantile, "heatmap_quantile", "", opts.HeatmapQuantile, "latency quantile the printed heatmap and the heatmap of the html report show")
+	pflag.StringVarP(&opts.HeatmapVerb, "heatmap_verb", "", opts.HeatmapVerb, "verb the printed heatmap and the heatmap of the html report show the latencies of, one of create, get, update, patch, list, delete or all")
+	pflag.BoolVarP(&opts.IncludeWarmUp, "include_warmup", "", opts.IncludeWarmUp, "include the API requests of the warm-up period of every test in reports")
 	pflag.IntVarP(&opts.JobsPerWorker, "jobs", "j", opts.JobsPerWorker, "number of jobs to be done per worker")
 	pflag.StringVarP(&opts.KubeconfigFilePath, "kubeconfig", "k", opts.KubeconfigFilePath, "path to the kubeconfig file")
 	pflag.StringSliceVarP(&opts.Latencies, "latencies", "l", opts.Latencies, "comma-separated latencies to be applied to IOChaos for performance testing")
+	pflag.Float64VarP(&opts.LatencyAccuracy, "latency_accuracy", "", opts.LatencyAccuracy, "relative accuracy of the recorded API request latencies, e.g. 0.01 for 1%")
+	pflag.StringVarP(&opts.MetricsAddress, "metrics_address", "", opts.MetricsAddress, "address to serve Prometheus metrics on '/metrics' during the tests (e.g. ':9090'), disabled when empty")
+	pflag.StringVarP(&opts.OutputStyle, "output_style", "", opts.OutputStyle, "style summaries and tables are printed to the console in, one of "+strings.Join(printer.RendererNames(), ", "))
 	pflag.StringSliceVarP(&opts.PercentsStr, "percents", "p", opts.PercentsStr, "comma-separated percents to be applied to IOChaos for performance testing")
+	pflag.BoolVarP(&opts.PerWorker, "per_worker", "", opts.PerWorker, "break down the API requests of every test by worker, reporting per-worker latencies and fairness indicators")
+	pflag.StringVarP(&opts.PushgatewayURL, "pushgateway_url", "", opts.PushgatewayURL, "URL of the Pushgateway to push the results of every test to, disabled when empty")
+	pflag.StringVarP(&opts.PushJobName, "push_job", "", opts.PushJobName, "value of the 'job' label of pushed metrics")
+	pflag.IntVarP(&opts.PushRetries, "push_retries", "", opts.PushRetries, "number of times a failed push is retried")
+	pflag.StringSliceVarP(&opts.QuantilesStr, "quantiles", "q", opts.QuantilesStr, "comma-separated latency quantiles to be reported, e.g. 0.999 for P99.9 and 1 for the maximum")
+	pflag.BoolVarP(&opts.RandomizeTrials, "randomize_trials", "", opts.RandomizeTrials, "interleave the trials of all latency-percent pairs in a randomized order instead of running them back to back")
+	pflag.DurationVarP(&opts.ResourceSampleInterval, "resource_sample_interval", "", opts.ResourceSampleInterval, "interval between samples of the CPU, memory, goroutines and GC of this program during every test, warning when it is likely saturated, disabled when 0")
+	pflag.StringVarP(&opts.RunID, "run_id", "", opts.RunID, "identity of the run in pushed metrics and exported reports, defaults to the start time of the program")
+	pflag.BoolVarP(&opts.ScrapeAPIServer, "scrape_apiserver", "", opts.ScrapeAPIServer, "scrape kube-apiserver '/metrics' before and after every test, reporting server-side request and etcd latencies and stored objects")
+	pflag.StringArrayVarP(&opts.SLOs, "slo", "", opts.SLOs, "SLO assertion evaluated for every test, e.g. 'create.p99<1s@latency<=50ms' or 'all.success_rate>=99.9', can be repeated")
+	pflag.StringVarP(&opts.SLOFilePath, "slo_file", "", opts.SLOFilePath, "path to a yaml file of SLO assertions evaluated for every test")
+	pflag.StringSliceVarP(&opts.SensitivityVerbs, "sensitivity_verbs", "", opts.SensitivityVerbs, "comma-separated verbs whose latency quantiles are fitted against the injected latency (slope, intercept, R^2 and knee) once every test has finished, none when empty")
 	pflag.IntVarP(&opts.SleepTimeInSeconds, "sleep", "s", opts.SleepTimeInSeconds, "waiting time in seconds after performance testing and before cleanup")
 	pflag.BoolVarP(&opts.Summarize, "summarize", "", opts.Summarize, "print the report of each test to stdout")
+	pflag.StringVarP(&opts.SummaryPlot, "summary_plot", "", opts.SummaryPlot, "per-verb latency plot in log-scaled buckets appended to the report of each test, either 'none', 'histogram' or 'cdf'")
+	pflag.StringVarP(&opts.TimeSeriesFormat, "timeseries_format", "", opts.TimeSeriesFormat, "format of exported time series, either 'csv' or 'json'")
+	pflag.DurationVarP(&opts.TimeSeriesInterval, "timeseries_interval", "", opts.TimeSeriesInterval, "length of every interval of exported time series")
+	pflag.StringSliceVarP(&opts.TagsStr, "tags", "", opts.TagsStr, "comma-separated key=value tags identifying the run in the results archive, e.g. 'etcd=v3.5,disk=ssd'")
+	pflag.IntVarP(&opts.Trials, "trials", "", opts.Trials, "number of times every latency-percent pair is tested, reports show the mean, stddev and 95% confidence interval across trials when greater than 1")
+	pflag.DurationVarP(&opts.WarmUpDuration, "warmup_duration", "", opts.WarmUpDuration, "length of the warm-up period at the beginning of every test, whose API requests are recorded separately and excluded from reports")
+	pflag.IntVarP(&opts.WarmUpRequests, "warmup_requests", "", opts.WarmUpRequests, "number of API requests of the warm-up period at the beginning of every test, whose API requests are recorded separately and excluded from reports")
 	pflag.IntVarP(&opts.WorkerNumber, "workers", "w", opts.WorkerNumber, "number of workers")
 	pflag.BoolVarP(&opts.WriteToCSV, "export_to_csv", "", opts.WriteToCSV, "export the final testing report to a csv file")
 
@@ -36,6 +90,24 @@ func parseFlags(opts *options.Options) {
 	pflag.Parse()
 }
 
+// serveMetrics starts an HTTP server exposing the metrics registry on `/metrics`. The
+// server lives as long as the program does.
+func serveMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	svr := &http.Server{
+		Addr:    address,
+		Handler: mux,
+	}
+
+	go func() {
+		klog.V(2).Infof("serving metrics on %v/metrics", address)
+		if err := svr.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("failed to serve metrics on %v: %v", address, err.Error())
+		}
+	}()
+}
+
 // TODO: enhance logging with customized logger.
 func main() {
 	// Prepare global context and setup notifications for os signals.
@@ -59,6 +131,12 @@ func main() {
 	if err := opts.Parse(); err != nil {
 		klog.Fatalf("failed to parse options: %v", err.Error())
 	}
+	printer.Default = printer.Renderers[opts.OutputStyle]
+
+	// Serve metrics while the tests are running.
+	if len(opts.MetricsAddress) > 0 {
+		serveMetrics(opts.MetricsAddress)
+	}
 
 	// Initialize test flow.
 	flow, err := testflow.NewTestFlow(opts)
@@ -71,6 +149,11 @@ func main() {
 		klog.Fatal("failed to create test flow, empty flow returned")
 	} else {
 		if err := flow.RunTestFlow(ctx); err != nil {
+			if errors.Is(err, slo.ErrViolated) {
+				klog.Errorf("test flow finished with failures: %v", err.Error())
+				klog.Flush()
+				os.Exit(sloViolationExitCode)
+			}
 			klog.Fatalf("failed to run test flow: %v", err.Error())
 		}
 	}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/archive"
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/metrics"
+	"github.com/nemoremold/perftests/pkg/options"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+// regressionExitCode is the exit code when regressions are flagged and `--fail_on_regression`
+// is set, distinct from the exit codes of the perftests program.
+const regressionExitCode = 4
+
+// trend prints how latency quantiles of latency-percent pairs evolved over the last runs
+// archived by the perftests program (`--archive_folder`), flagging the latency quantiles of
+// the most recent run that regressed beyond a threshold over the median of the previous runs.
+func main() {
+	var (
+		archivePath      string
+		cluster          string
+		tagsStr          []string
+		last             int
+		cellsStr         []string
+		verbs            []string
+		quantilesStr     []string
+		threshold        float64
+		failOnRegression bool
+		outputStyle      string
+	)
+
+	pflag.StringVarP(&archivePath, "archive", "a", "", "path to the results archive folder")
+	pflag.StringVarP(&cluster, "cluster", "", "", "only follow the runs of this cluster, runs of any cluster when empty")
+	pflag.StringSliceVarP(&tagsStr, "tags", "", nil, "comma-separated key=value tags the followed runs must all have")
+	pflag.IntVarP(&last, "last", "n", 10, "number of most recent runs followed")
+	pflag.StringSliceVarP(&cellsStr, "cells", "", nil, "comma-separated latency-percent pairs followed, as latency/percent (e.g. '100ms/50'), the pairs of the most recent run when empty")
+	pflag.StringSliceVarP(&verbs, "verbs", "", []string{constants.ALL}, "comma-separated verbs followed")
+	pflag.StringSliceVarP(&quantilesStr, "quantiles", "q", []string{"0.5", "0.99"}, "comma-separated latency quantiles followed, they must have been reported by the runs")
+	pflag.Float64VarP(&threshold, "threshold", "", 0.1, "relative increase of a latency quantile in the most recent run over the median of the previous runs flagged as a regression")
+	pflag.BoolVarP(&failOnRegression, "fail_on_regression", "", false, "when set to true, exits with code "+strconv.Itoa(regressionExitCode)+" if any regression is flagged")
+	pflag.StringVarP(&outputStyle, "output_style", "", "ascii", "style the trend is printed to the console in, one of "+strings.Join(printer.RendererNames(), ", "))
+
+	fs := flag.NewFlagSet("klog", flag.ExitOnError)
+	klog.InitFlags(fs)
+	pflag.CommandLine.AddGoFlagSet(fs)
+	pflag.Parse()
+
+	if len(archivePath) == 0 {
+		klog.Fatal("'--archive' must be provided")
+	}
+	if last < 2 {
+		klog.Fatalf("%v is not a valid number of runs (should be at least 2)", last)
+	}
+	if threshold < 0 {
+		klog.Fatalf("%v is not a valid threshold (should not be negative)", threshold)
+	}
+	renderer, ok := printer.Renderers[outputStyle]
+	if !ok {
+		klog.Fatalf("%v is not a valid output style (should be one of %v)", outputStyle, strings.Join(printer.RendererNames(), ", "))
+	}
+	printer.Default = renderer
+
+	tags, err := options.ParseTags(tagsStr)
+	if err != nil {
+		klog.Fatal(err.Error())
+	}
+	cfg := archive.TrendConfig{
+		Cluster:   cluster,
+		Tags:      tags,
+		Last:      last,
+		Verbs:     verbs,
+		Threshold: threshold,
+	}
+	for _, cellStr := range cellsStr {
+		latency, percent, ok := strings.Cut(cellStr, "/")
+		if !ok || len(latency) == 0 || len(percent) == 0 {
+			klog.Fatalf("%v is not a valid latency-percent pair (should be latency/percent, e.g. 100ms/50)", cellStr)
+		}
+		cfg.Cells = append(cfg.Cells, metrics.MetricSetID{Latency: latency, Percent: percent})
+	}
+	for _, quantileStr := range quantilesStr {
+		quantile, err := strconv.ParseFloat(quantileStr, 64)
+		if err != nil || quantile < 0 || quantile > 1 {
+			klog.Fatalf("%v is not a valid quantile (should be in range [0, 1])", quantileStr)
+		}
+		cfg.Quantiles = append(cfg.Quantiles, quantile)
+	}
+
+	trend, err := archive.NewArchive(archivePath).Trend(cfg)
+	if err != nil {
+		klog.Fatalf("failed to follow the trend: %v", err.Error())
+	}
+	if len(trend.Series) == 0 {
+		klog.Fatal("none of the latency-percent pairs, verbs and quantiles followed found in the archived runs")
+	}
+
+	archive.Summary(trend, cfg)
+
+	if regressions := trend.Regressions(); len(regressions) > 0 {
+		klog.Warningf("%v latency quantiles regressed by more than %.1f%% in run %v", len(regressions), threshold*100, trend.Runs[len(trend.Runs)-1].RunID)
+		if failOnRegression {
+			klog.Flush()
+			os.Exit(regressionExitCode)
+		}
+	}
+}
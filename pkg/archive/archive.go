@@ -0,0 +1,225 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+const (
+	// IndexFileName is the name of the index file of an archive.
+	IndexFileName = "index.json"
+	// RunsFolderName is the name of the folder of an archive the result documents are saved in.
+	RunsFolderName = "runs"
+	// IndexSchemaVersion is the version of the schema of index files.
+	IndexSchemaVersion = 1
+
+	// lockFileName is the name of the lock file held while the index of an archive is updated.
+	lockFileName = IndexFileName + ".lock"
+	// lockTimeout is how long appending a run waits for the lock of the index.
+	lockTimeout = 30 * time.Second
+	// lockPollInterval is the interval between attempts to take the lock of the index.
+	lockPollInterval = 50 * time.Millisecond
+	// lockStaleAge is the age beyond which a lock file is deemed left behind by a crashed run.
+	lockStaleAge = 5 * time.Minute
+)
+
+// unsafeFileNameCharacters matches the characters that are replaced in the file names of
+// result documents.
+var unsafeFileNameCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Index lists the runs of an archive.
+type Index struct {
+	// SchemaVersion is the version of the schema of the index.
+	SchemaVersion int `json:"schemaVersion"`
+	// Runs are the runs of the archive by the order they started.
+	Runs []Entry `json:"runs"`
+}
+
+// Entry is a run of an archive.
+type Entry struct {
+	// RunID identifies the run.
+	RunID string `json:"runId"`
+	// Cluster is the name of the cluster the run has tested.
+	Cluster string `json:"cluster,omitempty"`
+	// Tags map the keys of the tags of the run to their values.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Start is the time the run started.
+	Start time.Time `json:"start"`
+	// End is the time the run finished.
+	End time.Time `json:"end"`
+	// File is the path of the result document of the run, relative to the archive folder.
+	File string `json:"file"`
+}
+
+// Matches returns whether the run has been run on a cluster (any cluster when empty) and has
+// every given tag.
+func (e Entry) Matches(cluster string, tags map[string]string) bool {
+	if len(cluster) > 0 && e.Cluster != cluster {
+		return false
+	}
+	for key, value := range tags {
+		if actual, ok := e.Tags[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Archive is a folder of the result documents of past runs, along with an index file.
+type Archive struct {
+	// Path is the path of the archive folder.
+	Path string
+}
+
+// NewArchive instantiates the archive of a folder.
+func NewArchive(path string) *Archive {
+	return &Archive{Path: path}
+}
+
+// Index loads the index of the archive, which is empty if the archive has no index file yet.
+func (a *Archive) Index() (*Index, error) {
+	index := &Index{SchemaVersion: IndexSchemaVersion}
+	data, err := os.ReadFile(filepath.Join(a.Path, IndexFileName))
+	if os.IsNotExist(err) {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to decode archive index of %v: %w", a.Path, err)
+	}
+	if index.SchemaVersion != IndexSchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %v of archive index of %v (should be %v)", index.SchemaVersion, a.Path, IndexSchemaVersion)
+	}
+	return index, nil
+}
+
+// Append saves the result document of a run to the archive and adds the run to the index,
+// replacing the run of the same run ID and cluster if it has already been archived. The index
+// is locked meanwhile, so that concurrent runs sharing the archive do not lose each other.
+func (a *Archive) Append(document *metrics.ResultDocument) error {
+	if err := os.MkdirAll(filepath.Join(a.Path, RunsFolderName), 0755); err != nil {
+		return err
+	}
+	unlock, err := a.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	index, err := a.Index()
+	if err != nil {
+		return err
+	}
+
+	entry := Entry{
+		RunID:   document.Run.ID,
+		Cluster: document.Run.Cluster,
+		Tags:    document.Run.Tags,
+		Start:   document.Run.Start,
+		End:     document.Run.End,
+		File:    filepath.Join(RunsFolderName, fileName(document.Run.ID, document.Run.Cluster)),
+	}
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(a.Path, entry.File), data); err != nil {
+		return err
+	}
+
+	runs := make([]Entry, 0, len(index.Runs)+1)
+	for _, run := range index.Runs {
+		if run.RunID != entry.RunID || run.Cluster != entry.Cluster {
+			runs = append(runs, run)
+		}
+	}
+	index.Runs = append(runs, entry)
+	sort.SliceStable(index.Runs, func(i, j int) bool {
+		return index.Runs[i].Start.Before(index.Runs[j].Start)
+	})
+
+	data, err = json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(a.Path, IndexFileName), data)
+}
+
+// Runs returns the last runs of the archive, at most `last` of them (all of them when not
+// positive), which have been run on a cluster (any cluster when empty) and have every given
+// tag, by the order they started.
+func (a *Archive) Runs(cluster string, tags map[string]string, last int) ([]Entry, error) {
+	index, err := a.Index()
+	if err != nil {
+		return nil, err
+	}
+	var runs []Entry
+	for _, run := range index.Runs {
+		if run.Matches(cluster, tags) {
+			runs = append(runs, run)
+		}
+	}
+	if last > 0 && len(runs) > last {
+		runs = runs[len(runs)-last:]
+	}
+	return runs, nil
+}
+
+// lock takes the lock of the index by creating the lock file, waiting for it to be released
+// by another run if it exists. A lock file older than `lockStaleAge` is taken over. It returns
+// the function releasing the lock.
+func (a *Archive) lock() (func(), error) {
+	path := filepath.Join(a.Path, lockFileName)
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_ = file.Close()
+			return func() {
+				_ = os.Remove(path)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStaleAge {
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("archive %v is locked by another run (remove %v if no run is appending to it)", a.Path, path)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// DocumentPath returns the path of the result document of a run.
+func (a *Archive) DocumentPath(entry Entry) string {
+	return filepath.Join(a.Path, entry.File)
+}
+
+// fileName returns the name of the file of the result document of a run.
+func fileName(runID, cluster string) string {
+	name := runID
+	if len(cluster) > 0 {
+		name = cluster + "_" + runID
+	}
+	return unsafeFileNameCharacters.ReplaceAllString(name, "_") + ".json"
+}
+
+// writeFile writes a file atomically, so that an interrupted run does not corrupt the archive.
+func writeFile(path string, data []byte) error {
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
@@ -0,0 +1,136 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+// testStart is the time the first run of test archives started.
+var testStart = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestDocument instantiates the result document of a run started `offset` runs after the
+// first one, with a single latency-percent pair whose P99 of verb `all` is `p99`.
+func newTestDocument(runID, cluster string, tags map[string]string, offset int, p99 float64) *metrics.ResultDocument {
+	start := testStart.Add(time.Duration(offset) * time.Hour)
+	return &metrics.ResultDocument{
+		SchemaVersion: metrics.ResultSchemaVersion,
+		Run: metrics.RunMetadata{
+			ID:      runID,
+			Start:   start,
+			End:     start.Add(time.Minute),
+			Cluster: cluster,
+			Tags:    tags,
+		},
+		Cells: []metrics.ResultCell{{
+			Latency: "10ms",
+			Percent: "50",
+			Verbs: map[string]metrics.ResultVerb{
+				constants.ALL: {Quantiles: map[string]float64{"P99": p99}},
+			},
+		}},
+	}
+}
+
+// runIDs returns the run IDs of entries by order.
+func runIDs(entries []Entry) []string {
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.RunID)
+	}
+	return ids
+}
+
+func TestAppendReplacesSameRun(t *testing.T) {
+	a := NewArchive(t.TempDir())
+	for _, document := range []*metrics.ResultDocument{
+		newTestDocument("nightly-2", "kind", nil, 2, 0.2),
+		newTestDocument("nightly-1", "kind", nil, 1, 0.1),
+		newTestDocument("nightly-1", "gke", nil, 3, 0.3),
+		newTestDocument("nightly-1", "kind", map[string]string{"etcd": "3.5"}, 4, 0.4),
+	} {
+		if err := a.Append(document); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	index, err := a.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The run of the same run ID and cluster is replaced, runs are sorted by start time.
+	if got, want := runIDs(index.Runs), []string{"nightly-2", "nightly-1", "nightly-1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got runs %v, want %v", got, want)
+	}
+	if index.Runs[1].Cluster != "gke" || index.Runs[2].Cluster != "kind" || index.Runs[2].Tags["etcd"] != "3.5" {
+		t.Errorf("got runs %+v, want nightly-1 of gke then the replaced nightly-1 of kind", index.Runs)
+	}
+	for _, run := range index.Runs {
+		if _, err := os.Stat(a.DocumentPath(run)); err != nil {
+			t.Errorf("result document of run %v not saved: %v", run.RunID, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(a.Path, lockFileName)); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestAppendTakesOverStaleLock(t *testing.T) {
+	a := NewArchive(t.TempDir())
+	path := filepath.Join(a.Path, lockFileName)
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * lockStaleAge)
+	if err := os.Chtimes(path, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Append(newTestDocument("nightly-1", "", nil, 0, 0.1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	a := NewArchive(t.TempDir())
+	for _, document := range []*metrics.ResultDocument{
+		newTestDocument("1", "kind", map[string]string{"etcd": "3.5", "disk": "ssd"}, 1, 0.1),
+		newTestDocument("2", "gke", map[string]string{"etcd": "3.5"}, 2, 0.1),
+		newTestDocument("3", "kind", map[string]string{"etcd": "3.4"}, 3, 0.1),
+		newTestDocument("4", "kind", map[string]string{"etcd": "3.5"}, 4, 0.1),
+		newTestDocument("5", "kind", nil, 5, 0.1),
+	} {
+		if err := a.Append(document); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name    string
+		cluster string
+		tags    map[string]string
+		last    int
+		want    []string
+	}{
+		{name: "all", want: []string{"1", "2", "3", "4", "5"}},
+		{name: "cluster", cluster: "kind", want: []string{"1", "3", "4", "5"}},
+		{name: "tags", tags: map[string]string{"etcd": "3.5"}, want: []string{"1", "2", "4"}},
+		{name: "cluster and tags", cluster: "kind", tags: map[string]string{"etcd": "3.5"}, want: []string{"1", "4"}},
+		{name: "last", cluster: "kind", last: 2, want: []string{"4", "5"}},
+		{name: "none", cluster: "eks", want: []string{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			runs, err := a.Runs(test.cluster, test.tags, test.last)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := runIDs(runs); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got runs %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package archive
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/nemoremold/perftests/pkg/metrics"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+// Summary prints the trend sheet of every latency-percent pair, with one table per verb.
+func Summary(trend *Trend, cfg TrendConfig) {
+	for start := 0; start < len(trend.Series); {
+		end := start
+		for end < len(trend.Series) && trend.Series[end].Set == trend.Series[start].Set {
+			end++
+		}
+		printer.PrintEmptyLine()
+		TrendSheet(trend.Runs, trend.Series[start:end], cfg).Print()
+		printer.PrintEmptyLine()
+		start = end
+	}
+}
+
+// TrendSheet generates the trend sheet of a latency-percent pair: one table per verb, with
+// one row per run and one column per quantile, followed by the change of the most recent
+// run over the median of the previous runs. Regressions are listed in the footer.
+func TrendSheet(runs []Entry, series []Series, cfg TrendConfig) *printer.Sheet {
+	set := series[0].Set
+	sheet := printer.NewSheet(0, printer.LineAlignCenter("Performance Trend"))
+	header := []printer.Line{
+		printer.LineAlignRight("Latency: " + set.Latency),
+		printer.LineAlignRight("Percent: " + set.Percent),
+		printer.LineAlignRight(fmt.Sprintf("Runs: %v", len(runs))),
+	}
+	if len(cfg.Cluster) > 0 {
+		header = append(header, printer.LineAlignRight("Cluster: "+cfg.Cluster))
+	}
+	if len(cfg.Tags) > 0 {
+		header = append(header, printer.LineAlignRight("Tags: "+formatTags(cfg.Tags)))
+	}
+	sheet.SetHeader(header)
+
+	var (
+		tables []printer.Table
+		footer []printer.Line
+	)
+	for start := 0; start < len(series); {
+		end := start
+		for end < len(series) && series[end].Verb == series[start].Verb {
+			end++
+		}
+		tables = append(tables, prepareTrendTable(runs, series[start:end], cfg.Threshold))
+		start = end
+	}
+	for _, s := range series {
+		if s.Regression {
+			footer = append(footer, printer.LineAlignLeft(fmt.Sprintf("REGRESSION: %v %v is %.5f, %+.1f%% over the median %.5f of the previous runs",
+				strings.ToUpper(s.Verb), metrics.QuantileName(s.Quantile), s.Values[len(s.Values)-1], s.Change*100, s.Reference)))
+		}
+	}
+	if len(footer) == 0 {
+		footer = append(footer, printer.LineAlignLeft(fmt.Sprintf("No regression beyond %.1f%%", cfg.Threshold*100)))
+	}
+	sheet.SetTables(tables)
+	sheet.SetFooter(footer)
+	return sheet
+}
+
+// prepareTrendTable generates the trend table of a verb.
+func prepareTrendTable(runs []Entry, series []Series, threshold float64) printer.Table {
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Run"),
+		printer.LineAlignRight("Start"),
+	}
+	for _, s := range series {
+		headerRow.AddEntry(printer.LineAlignRight(metrics.QuantileName(s.Quantile)))
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter(strings.ToUpper(series[0].Verb)+" Latency(s)"))
+	table.SetHeaders(headerRow)
+
+	var tableRows []printer.TableRow
+	for index, run := range runs {
+		row := printer.TableRow{
+			printer.LineAlignRight(run.RunID),
+			printer.LineAlignRight(run.Start.Local().Format("2006-01-02 15:04")),
+		}
+		for _, s := range series {
+			row.AddEntry(printer.LineAlignRight(formatValue(s.Values[index])))
+		}
+		tableRows = append(tableRows, row)
+	}
+	medianRow := printer.TableRow{
+		printer.LineAlignRight("Median"),
+		printer.LineAlignRight("(previous)"),
+	}
+	changeRow := printer.TableRow{
+		printer.LineAlignRight("Change"),
+		printer.LineAlignRight(fmt.Sprintf("(> %.1f%%)", threshold*100)),
+	}
+	for _, s := range series {
+		medianRow.AddEntry(printer.LineAlignRight(formatValue(s.Reference)))
+		changeRow.AddEntry(printer.LineAlignRight(formatChange(s)))
+	}
+	table.SetDatum(append(tableRows, medianRow, changeRow))
+	return *table
+}
+
+// formatValue formats a latency for the terminal.
+func formatValue(value float64) string {
+	if math.IsNaN(value) {
+		return "-"
+	}
+	return fmt.Sprintf("%.5f", value)
+}
+
+// formatChange formats the change of a series for the terminal, marking regressions.
+func formatChange(s Series) string {
+	if math.IsNaN(s.Change) {
+		return "-"
+	}
+	change := fmt.Sprintf("%+.1f%%", s.Change*100)
+	if s.Regression {
+		change += " (!)"
+	}
+	return change
+}
+
+// formatTags formats tags by alphabetical order of keys.
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]string, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, key+"="+tags[key])
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,160 @@
+package archive
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nemoremold/perftests/pkg/compare"
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+// TrendConfig configures which runs and values of an archive a trend follows.
+type TrendConfig struct {
+	// Cluster restricts the trend to the runs of a cluster, any cluster when empty.
+	Cluster string
+	// Tags restrict the trend to the runs having every tag.
+	Tags map[string]string
+	// Last is the maximum number of runs followed, the most recent ones.
+	Last int
+	// Cells are the latency-percent pairs followed, the pairs of the most recent run when empty.
+	Cells []metrics.MetricSetID
+	// Verbs are the verbs followed.
+	Verbs []string
+	// Quantiles are the latency quantiles followed.
+	Quantiles []float64
+	// Threshold is the relative increase of a latency quantile in the most recent run over the
+	// median of the previous runs beyond which it is flagged as a regression, e.g. 0.1.
+	Threshold float64
+}
+
+// Trend is how latency quantiles of latency-percent pairs evolved over the runs of an archive.
+type Trend struct {
+	// Runs are the runs followed by the order they started.
+	Runs []Entry
+	// Series are the values followed, by latency-percent pair, then verb, then quantile.
+	Series []Series
+}
+
+// Series is how a latency quantile of a verb in a latency-percent pair evolved over runs.
+type Series struct {
+	// Set identifies the latency-percent pair.
+	Set metrics.MetricSetID
+	// Verb is the verb of the API requests.
+	Verb string
+	// Quantile is the latency quantile.
+	Quantile float64
+	// Values are the latencies in seconds of every run (averaged across trials), NaN when the
+	// run has not reported it.
+	Values []float64
+	// Reference is the median of the values of every run but the most recent one, NaN when
+	// there is none.
+	Reference float64
+	// Change is the relative change of the value of the most recent run over the reference,
+	// NaN when either is missing.
+	Change float64
+	// Regression indicates whether the change is beyond the threshold.
+	Regression bool
+}
+
+// Trend loads the last runs of the archive matching the configuration, and follows every
+// configured latency quantile of every configured verb in every configured latency-percent
+// pair across them.
+func (a *Archive) Trend(cfg TrendConfig) (*Trend, error) {
+	runs, err := a.Runs(cfg.Cluster, cfg.Tags, cfg.Last)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no run found in archive %v", a.Path)
+	}
+
+	// Result documents are loaded like the results of the compare command, trials averaged.
+	results := make([]*compare.ResultSet, 0, len(runs))
+	for _, run := range runs {
+		result, err := compare.Load(a.DocumentPath(run), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load run %v: %w", run.RunID, err)
+		}
+		results = append(results, result)
+	}
+	cells := cfg.Cells
+	if len(cells) == 0 {
+		cells = results[len(results)-1].Sets
+	}
+
+	trend := &Trend{Runs: runs}
+	for _, set := range cells {
+		for _, verb := range cfg.Verbs {
+			for _, quantile := range cfg.Quantiles {
+				series := Series{
+					Set:      set,
+					Verb:     verb,
+					Quantile: quantile,
+					Values:   make([]float64, len(results)),
+				}
+				found := false
+				for index, result := range results {
+					series.Values[index] = math.NaN()
+					if cell, ok := result.Cells[set]; ok {
+						if verbResult, ok := cell.Verbs[verb]; ok {
+							if value, ok := verbResult.Values[metrics.QuantileName(quantile)]; ok {
+								series.Values[index] = value
+								found = true
+							}
+						}
+					}
+				}
+				if found {
+					series.evaluate(cfg.Threshold)
+					trend.Series = append(trend.Series, series)
+				}
+			}
+		}
+	}
+	return trend, nil
+}
+
+// Regressions returns the series flagged as regressions.
+func (t *Trend) Regressions() []Series {
+	var regressions []Series
+	for _, series := range t.Series {
+		if series.Regression {
+			regressions = append(regressions, series)
+		}
+	}
+	return regressions
+}
+
+// evaluate compares the value of the most recent run to the median of the previous runs,
+// flagging a regression when it has increased beyond a threshold.
+func (s *Series) evaluate(threshold float64) {
+	s.Reference, s.Change = math.NaN(), math.NaN()
+	var previous []float64
+	for _, value := range s.Values[:len(s.Values)-1] {
+		if !math.IsNaN(value) {
+			previous = append(previous, value)
+		}
+	}
+	if len(previous) == 0 {
+		return
+	}
+	s.Reference = median(previous)
+	latest := s.Values[len(s.Values)-1]
+	if math.IsNaN(latest) || s.Reference <= 0 {
+		return
+	}
+	s.Change = latest/s.Reference - 1
+	s.Regression = s.Change > threshold
+}
+
+// median returns the median of non-empty values.
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+	return (sorted[middle-1] + sorted[middle]) / 2
+}
@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+// sameValue returns whether two values are equal, NaN being equal to NaN.
+func sameValue(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSeriesEvaluate(t *testing.T) {
+	nan := math.NaN()
+	for _, test := range []struct {
+		name       string
+		values     []float64
+		reference  float64
+		change     float64
+		regression bool
+	}{
+		{name: "median of odd previous runs", values: []float64{0.3, 0.1, 0.2, 0.24}, reference: 0.2, change: 0.2, regression: true},
+		{name: "median of even previous runs", values: []float64{0.1, 0.3, 0.2, 0.4, 0.22}, reference: 0.25, change: -0.12},
+		{name: "within threshold", values: []float64{0.2, 0.2, 0.21}, reference: 0.2, change: 0.05},
+		{name: "previous runs missing the value", values: []float64{nan, 0.2, nan, 0.3}, reference: 0.2, change: 0.5, regression: true},
+		{name: "latest run missing the value", values: []float64{0.2, 0.2, nan}, reference: 0.2, change: nan},
+		{name: "no previous value", values: []float64{nan, nan, 0.2}, reference: nan, change: nan},
+		{name: "zero reference", values: []float64{0, 0, 0.2}, reference: 0, change: nan},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			series := Series{Values: test.values}
+			series.evaluate(0.1)
+			if !sameValue(series.Reference, test.reference) {
+				t.Errorf("got reference %v, want %v", series.Reference, test.reference)
+			}
+			if !sameValue(series.Change, test.change) {
+				t.Errorf("got change %v, want %v", series.Change, test.change)
+			}
+			if series.Regression != test.regression {
+				t.Errorf("got regression %v, want %v", series.Regression, test.regression)
+			}
+		})
+	}
+}
+
+func TestTrend(t *testing.T) {
+	a := NewArchive(t.TempDir())
+	for index, p99 := range []float64{0.1, 0.12, 0.11, 0.15} {
+		if err := a.Append(newTestDocument(string(rune('a'+index)), "kind", nil, index, p99)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trend, err := a.Trend(TrendConfig{
+		Cluster:   "kind",
+		Last:      3,
+		Verbs:     []string{constants.ALL, constants.CREATE},
+		Quantiles: []float64{0.99},
+		Threshold: 0.2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := runIDs(trend.Runs); len(got) != 3 || got[0] != "b" || got[2] != "d" {
+		t.Fatalf("got runs %v, want b, c and d", got)
+	}
+	// Verb `create` has not been reported by any run, so it is not followed.
+	if len(trend.Series) != 1 {
+		t.Fatalf("got %v series, want 1", len(trend.Series))
+	}
+	series := trend.Series[0]
+	if series.Set != (metrics.MetricSetID{Latency: "10ms", Percent: "50"}) || series.Verb != constants.ALL {
+		t.Errorf("got series of %v and verb %v, want 10ms/50 and verb all", series.Set, series.Verb)
+	}
+	if !sameValue(series.Reference, 0.115) || !series.Regression {
+		t.Errorf("got reference %v and regression %v, want 0.115 and a regression", series.Reference, series.Regression)
+	}
+	if regressions := trend.Regressions(); len(regressions) != 1 {
+		t.Errorf("got %v regressions, want 1", len(regressions))
+	}
+}
@@ -0,0 +1,158 @@
+package compare
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+// Config configures how two result sets are compared.
+type Config struct {
+	// Verbs are the verbs to be compared, every verb is compared when it is empty.
+	Verbs []string
+	// Confidence is the confidence level of bootstrap confidence intervals, e.g. 0.95.
+	Confidence float64
+	// BootstrapIterations is the number of bootstrap resamples.
+	BootstrapIterations int
+	// Seed seeds the random number generator used for bootstrapping.
+	Seed int64
+}
+
+// Row is the comparison of a metric of a verb in a latency-percent pair. Values that
+// cannot be computed (e.g. confidence intervals of CSV reports) are NaN.
+type Row struct {
+	// Set identifies the latency-percent pair.
+	Set metrics.MetricSetID
+	// Verb is the compared verb.
+	Verb string
+	// Metric is the name of the compared metric, a quantile name or `SuccessRate`.
+	Metric string
+	// Baseline is the value of the metric in the baseline result set.
+	Baseline float64
+	// Candidate is the value of the metric in the candidate result set.
+	Candidate float64
+	// Delta is the candidate value minus the baseline value.
+	Delta float64
+	// Ratio is the candidate value divided by the baseline value.
+	Ratio float64
+	// DeltaLow is the lower bound of the confidence interval of the delta.
+	DeltaLow float64
+	// DeltaHigh is the upper bound of the confidence interval of the delta.
+	DeltaHigh float64
+	// Superiority is the probability that a candidate latency is greater than a baseline
+	// latency of the verb.
+	Superiority float64
+	// PValue is the p-value of the Mann-Whitney U test on the latencies of the verb.
+	PValue float64
+}
+
+// Compare aligns two result sets by latency-percent pair and verb, and compares every
+// metric found in both.
+func Compare(baseline, candidate *ResultSet, cfg Config) []Row {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	verbs := cfg.Verbs
+	if len(verbs) == 0 {
+		verbs = constants.Verbs
+	}
+
+	var rows []Row
+	for _, set := range baseline.Sets {
+		candidateCell, ok := candidate.Cells[set]
+		if !ok {
+			continue
+		}
+		for _, verb := range verbs {
+			baselineResult, ok := baseline.Cells[set].Verbs[verb]
+			if !ok {
+				continue
+			}
+			candidateResult, ok := candidateCell.Verbs[verb]
+			if !ok {
+				continue
+			}
+			rows = append(rows, compareVerb(set, verb, baselineResult, candidateResult, cfg, rng)...)
+		}
+	}
+	return rows
+}
+
+// compareVerb compares the metrics of a verb in a latency-percent pair.
+func compareVerb(set metrics.MetricSetID, verb string, baseline, candidate *VerbResult, cfg Config, rng *rand.Rand) []Row {
+	withHistograms := baseline.Stats != nil && candidate.Stats != nil &&
+		baseline.Stats.Latencies.RelativeAccuracy() == candidate.Stats.Latencies.RelativeAccuracy()
+
+	superiority, pValue := math.NaN(), math.NaN()
+	if withHistograms {
+		superiority, pValue = MannWhitneyU(baseline.Stats.Latencies, candidate.Stats.Latencies)
+	}
+
+	var rows []Row
+	for _, metric := range sortedMetrics(baseline.Values, candidate.Values) {
+		row := Row{
+			Set:         set,
+			Verb:        verb,
+			Metric:      metric,
+			Baseline:    baseline.Values[metric],
+			Candidate:   candidate.Values[metric],
+			Ratio:       math.NaN(),
+			DeltaLow:    math.NaN(),
+			DeltaHigh:   math.NaN(),
+			Superiority: superiority,
+			PValue:      pValue,
+		}
+		row.Delta = row.Candidate - row.Baseline
+		if row.Baseline != 0 {
+			row.Ratio = row.Candidate / row.Baseline
+		}
+		if quantile, ok := ParseQuantileName(metric); ok && withHistograms {
+			row.DeltaLow, row.DeltaHigh = BootstrapQuantileDelta(baseline.Stats.Latencies, candidate.Stats.Latencies, quantile, cfg.Confidence, cfg.BootstrapIterations, rng)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// sortedMetrics returns the metrics found in both value maps, quantiles by ascending
+// order followed by the other metrics by alphabetical order.
+func sortedMetrics(baseline, candidate map[string]float64) []string {
+	var metricNames []string
+	for metric := range baseline {
+		if _, ok := candidate[metric]; ok {
+			metricNames = append(metricNames, metric)
+		}
+	}
+	sort.Slice(metricNames, func(i, j int) bool {
+		qi, iIsQuantile := ParseQuantileName(metricNames[i])
+		qj, jIsQuantile := ParseQuantileName(metricNames[j])
+		switch {
+		case iIsQuantile && jIsQuantile:
+			return qi < qj
+		case iIsQuantile != jIsQuantile:
+			return iIsQuantile
+		default:
+			return metricNames[i] < metricNames[j]
+		}
+	})
+	return metricNames
+}
+
+// ParseQuantileName converts a quantile name generated by `metrics.QuantileName` back
+// to the quantile, returning false if the name is not a quantile name.
+func ParseQuantileName(name string) (float64, bool) {
+	if name == metrics.QuantileName(1) {
+		return 1, true
+	}
+	if !strings.HasPrefix(name, "P") {
+		return 0, false
+	}
+	percent, err := strconv.ParseFloat(strings.TrimPrefix(name, "P"), 64)
+	if err != nil {
+		return 0, false
+	}
+	return percent / 100, true
+}
@@ -0,0 +1,375 @@
+package compare
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+const (
+	// SuccessRate is the name of the success rate metric.
+	SuccessRate = "Success Rate"
+)
+
+// ErrTraceUnsupported is returned when loading raw traces that do not hold the latency
+// histograms of their intervals (i.e. CSV time series exports), which cannot be merged into
+// the latency distribution of a whole test.
+var ErrTraceUnsupported = errors.New("raw traces without histograms are not supported, export time series in json to compare them")
+
+// ResultSet is the result of a run, loaded from an exported report.
+type ResultSet struct {
+	// Path is the path of the exported report.
+	Path string
+	// Sets are the metric sets found in the report by the order they appear.
+	Sets []metrics.MetricSetID
+	// Cells maps metric sets to their results.
+	Cells map[metrics.MetricSetID]*Cell
+}
+
+// Cell is the result of a latency-percent pair.
+type Cell struct {
+	// Verbs maps verbs to their results.
+	Verbs map[string]*VerbResult
+}
+
+// VerbResult is the result of a verb in a latency-percent pair.
+type VerbResult struct {
+	// Values maps metric names (quantile names or `SuccessRate`) to their values.
+	Values map[string]float64
+	// Stats is the full statistics of the verb, it is nil when the result set is loaded
+	// from a CSV report.
+	Stats *metrics.RequestStats
+}
+
+// Load loads a result set from a report exported by `metrics.Exporter`: either a histogram
+// dump (`.json`), a result document (`.json`), a time series export (`.json`) or a CSV
+// report. Quantiles of histogram dumps and time series exports are evaluated with the given
+// quantiles. CSV time series exports are rejected with `ErrTraceUnsupported`.
+func Load(path string, quantiles []float64) (*ResultSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		// Histogram dumps are JSON arrays, result documents and time series exports are
+		// JSON objects.
+		reader := bufio.NewReader(file)
+		if first, err := peekNonSpace(reader); err == nil && first == '{' {
+			return loadResult(path, reader, quantiles)
+		}
+		return loadHistograms(path, reader, quantiles)
+	}
+	return loadCSV(path, file)
+}
+
+// peekNonSpace returns the first byte of a reader that is not a white space, without
+// consuming it.
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		next, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(next[0])) {
+			return next[0], nil
+		}
+		if _, err := reader.Discard(1); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// loadResult loads a result set from a result document. Its values are the reported
+// quantiles, the success rate and the throughput of every verb, averaged across trials.
+// Time series exports are loaded by `loadTimeSeries` instead.
+func loadResult(path string, reader io.Reader, quantiles []float64) (*ResultSet, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	// Time series exports are JSON objects too, told apart by their interval.
+	var probe struct {
+		IntervalSeconds *float64 `json:"intervalSeconds"`
+	}
+	if err := json.Unmarshal(data, &probe); err == nil && probe.IntervalSeconds != nil {
+		return loadTimeSeries(path, data, quantiles)
+	}
+
+	var document metrics.ResultDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode result document from %v: %w", path, err)
+	}
+	if document.SchemaVersion != metrics.ResultSchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %v of result document %v (should be %v)", document.SchemaVersion, path, metrics.ResultSchemaVersion)
+	}
+
+	result := newResultSet(path)
+	counts := make(map[*VerbResult]float64)
+	for _, cell := range document.Cells {
+		set := metrics.MetricSetID{Latency: cell.Latency, Percent: cell.Percent}
+		for _, verb := range constants.Verbs {
+			resultVerb, ok := cell.Verbs[verb]
+			if !ok {
+				continue
+			}
+			verbResult := result.verb(set, verb)
+			values := map[string]float64{
+				SuccessRate:  resultVerb.SuccessRate,
+				"Throughput": resultVerb.Throughput,
+			}
+			for name, value := range resultVerb.Quantiles {
+				values[name] = value
+			}
+			// Keep a running mean of every value across trials.
+			counts[verbResult]++
+			for name, value := range values {
+				verbResult.Values[name] += (value - verbResult.Values[name]) / counts[verbResult]
+			}
+		}
+	}
+	return result, nil
+}
+
+// loadTimeSeries loads a result set from a time series export, i.e. the raw trace of a
+// single test, by merging the latency histograms of its intervals for every verb.
+func loadTimeSeries(path string, data []byte, quantiles []float64) (*ResultSet, error) {
+	var document struct {
+		Set    metrics.MetricSetID `json:"set"`
+		Points []struct {
+			Verbs map[string]struct {
+				Requests  uint64             `json:"requests"`
+				Errors    uint64             `json:"errors"`
+				Histogram *metrics.Histogram `json:"histogram"`
+			} `json:"verbs"`
+		} `json:"points"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode time series from %v: %w", path, err)
+	}
+
+	result := newResultSet(path)
+	for index, point := range document.Points {
+		for _, verb := range constants.Verbs {
+			interval, ok := point.Verbs[verb]
+			if !ok {
+				continue
+			}
+			if interval.Histogram == nil {
+				return nil, fmt.Errorf("interval %v of %v has no histogram, it was exported by an older version: %w", index, path, ErrTraceUnsupported)
+			}
+			verbResult := result.verb(document.Set.Cell(), verb)
+			stats := &metrics.RequestStats{
+				Total:      interval.Requests,
+				Successful: interval.Requests - interval.Errors,
+				Latencies:  interval.Histogram,
+			}
+			if verbResult.Stats == nil {
+				verbResult.Stats = stats
+			} else if err := verbResult.Stats.Merge(stats); err != nil {
+				return nil, fmt.Errorf("failed to merge interval %v of %v: %w", index, path, err)
+			}
+		}
+	}
+	result.evaluate(quantiles)
+	return result, nil
+}
+
+// newResultSet instantiates an empty result set.
+func newResultSet(path string) *ResultSet {
+	return &ResultSet{
+		Path:  path,
+		Cells: make(map[metrics.MetricSetID]*Cell),
+	}
+}
+
+// verb returns the result of a verb in a latency-percent pair, creating it if it
+// does not exist.
+func (r *ResultSet) verb(set metrics.MetricSetID, verb string) *VerbResult {
+	cell, ok := r.Cells[set]
+	if !ok {
+		cell = &Cell{Verbs: make(map[string]*VerbResult)}
+		r.Cells[set] = cell
+		r.Sets = append(r.Sets, set)
+	}
+	result, ok := cell.Verbs[verb]
+	if !ok {
+		result = &VerbResult{Values: make(map[string]float64)}
+		cell.Verbs[verb] = result
+	}
+	return result
+}
+
+// loadHistograms loads a result set from a histogram dump.
+func loadHistograms(path string, reader io.Reader, quantiles []float64) (*ResultSet, error) {
+	var cells []metrics.CellStats
+	if err := json.NewDecoder(reader).Decode(&cells); err != nil {
+		return nil, fmt.Errorf("failed to decode histograms from %v: %w", path, err)
+	}
+
+	// Merge the trials of every latency-percent pair.
+	result := newResultSet(path)
+	for _, cell := range cells {
+		for _, verb := range constants.Verbs {
+			stats, ok := cell.Verbs[verb]
+			if !ok || stats.Latencies == nil {
+				continue
+			}
+			verbResult := result.verb(cell.Set.Cell(), verb)
+			if verbResult.Stats == nil {
+				verbResult.Stats = stats.Copy()
+			} else if err := verbResult.Stats.Merge(stats); err != nil {
+				return nil, fmt.Errorf("failed to merge trials of %v in %v: %w", cell.Set, path, err)
+			}
+		}
+	}
+	result.evaluate(quantiles)
+	return result, nil
+}
+
+// evaluate evaluates the given quantiles and the success rate of every verb from its full
+// statistics.
+func (r *ResultSet) evaluate(quantiles []float64) {
+	for _, cell := range r.Cells {
+		for _, verbResult := range cell.Verbs {
+			for _, quantile := range quantiles {
+				verbResult.Values[metrics.QuantileName(quantile)] = verbResult.Stats.Latencies.Quantile(quantile)
+			}
+			verbResult.Values[SuccessRate] = verbResult.Stats.SuccessRate()
+		}
+	}
+}
+
+// loadCSV loads a result set from a CSV report, in either the stacked or the long layout.
+//
+// A report in the stacked layout consists of one table per percent, each starting with a
+// title row (e.g. `10% sample`), followed by a header row of latencies, followed by one row
+// per metric, holding the results of verb `all`. Newer reports also have one such table per
+// verb and percent (e.g. `10% sample create`). Reports of several trials also have tables of
+// the spread across trials (e.g. `10% sample stddev`), which are skipped along with the
+// tables of other statistics: only the mean across trials is loaded.
+func loadCSV(path string, reader io.Reader) (*ResultSet, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv report %v: %w", path, err)
+	}
+	if len(records) > 0 && strings.Join(records[0], ",") == strings.Join(metrics.LongLayoutHeader, ",") {
+		return loadLongCSV(path, records[1:])
+	}
+	if len(records) > 0 && len(records[0]) > 1 && records[0][0] == "Time" && records[0][1] == "Offset(s)" {
+		return nil, fmt.Errorf("%v is a csv time series export: %w", path, ErrTraceUnsupported)
+	}
+
+	result := newResultSet(path)
+	var (
+		percent   string
+		verb      string
+		latencies []string
+		skipped   bool
+	)
+	for line, record := range records {
+		switch {
+		case len(record) == 1 && strings.Contains(record[0], "% sample"):
+			title := strings.SplitN(record[0], "% sample", 2)
+			percent, verb = title[0], strings.TrimPrefix(title[1], " ")
+			latencies = nil
+			skipped = false
+			if len(verb) == 0 {
+				verb = constants.ALL
+			} else if !constants.IsVerb(verb) {
+				skipped = true
+			}
+		case skipped:
+			continue
+		case len(record) > 1 && (record[0] == "Quantile" || record[0] == "Metric"):
+			latencies = nil
+			for _, column := range record[1:] {
+				latency := strings.TrimSuffix(strings.TrimPrefix(column, "Latency("), ")")
+				latencies = append(latencies, latency+"ms")
+			}
+		case len(record) > 1 && latencies != nil:
+			name := normalizeMetricName(record[0])
+			for index, entry := range record[1:] {
+				if index >= len(latencies) || len(entry) == 0 {
+					continue
+				}
+				value, err := strconv.ParseFloat(strings.TrimSuffix(entry, "%"), 64)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse %v at line %v of %v: %w", entry, line+1, path, err)
+				}
+				set := metrics.MetricSetID{Latency: latencies[index], Percent: percent}
+				result.verb(set, verb).Values[name] = value
+			}
+		}
+	}
+	return result, nil
+}
+
+// loadLongCSV loads a result set from the records of a CSV report in the long layout,
+// header excluded. Only API request statistics are loaded, averaged across trials.
+func loadLongCSV(path string, records [][]string) (*ResultSet, error) {
+	type valueKey struct {
+		set    metrics.MetricSetID
+		verb   string
+		metric string
+	}
+	var (
+		keys   []valueKey
+		sums   = make(map[valueKey]float64)
+		counts = make(map[valueKey]int)
+	)
+	for line, record := range records {
+		if len(record) != len(metrics.LongLayoutHeader) {
+			return nil, fmt.Errorf("unexpected number of fields at line %v of %v", line+2, path)
+		}
+		// Baseline tests are not latency-percent pairs, they are left out.
+		if record[3] != metrics.RequestsGroup || record[0] == metrics.BaselineLatency {
+			continue
+		}
+		value, err := strconv.ParseFloat(record[6], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %v at line %v of %v: %w", record[6], line+2, path, err)
+		}
+		key := valueKey{
+			set:    metrics.MetricSetID{Latency: record[0], Percent: record[1]},
+			verb:   record[4],
+			metric: record[5],
+		}
+		if _, ok := counts[key]; !ok {
+			keys = append(keys, key)
+		}
+		sums[key] += value
+		counts[key]++
+	}
+
+	result := newResultSet(path)
+	for _, key := range keys {
+		result.verb(key.set, key.verb).Values[key.metric] = sums[key] / float64(counts[key])
+	}
+	return result, nil
+}
+
+// normalizeMetricName converts the row names of older reports (e.g. `99%`) to the
+// quantile names used now (e.g. `P99`).
+func normalizeMetricName(name string) string {
+	if strings.HasSuffix(name, "%") {
+		if percent, err := strconv.ParseFloat(strings.TrimSuffix(name, "%"), 64); err == nil {
+			return metrics.QuantileName(percent / 100)
+		}
+	}
+	return name
+}
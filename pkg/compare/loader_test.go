@@ -0,0 +1,205 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+// stackedCSV is a CSV report in the stacked layout of two trials: the tables of the spread
+// across trials are skipped.
+const stackedCSV = `10% sample
+Quantile,Latency(10),Latency(20)
+50%,0.01,0.02
+P99,0.1,0.2
+Success Rate,100%,99.5%
+
+10% sample stddev
+Quantile,Latency(10),Latency(20)
+P99,9,9
+
+10% sample create
+Quantile,Latency(10),Latency(20)
+P99,0.3,0.4
+`
+
+// longCSV is a CSV report in the long layout of two trials: other groups than API request
+// statistics and baseline tests are skipped.
+const longCSV = `latency,percent,trial,group,verb,metric,value
+10ms,50,1,requests,all,P99,0.1
+10ms,50,2,requests,all,P99,0.3
+10ms,50,1,requests,create,Success Rate,100
+10ms,50,1,server,all,P99,9
+baseline,baseline,0,requests,all,P99,5
+`
+
+// writeFixture writes a fixture to a file of a given name in a temporary folder, returning
+// the path of the file.
+func writeFixture(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTestStats instantiates the request statistics of API requests of the given latencies,
+// of which `failed` have failed.
+func newTestStats(t *testing.T, failed uint64, latencies ...float64) *metrics.RequestStats {
+	stats := metrics.NewRequestStats()
+	for _, latency := range latencies {
+		stats.Latencies.Observe(latency)
+	}
+	stats.Total = uint64(len(latencies))
+	stats.Successful = stats.Total - failed
+	return stats
+}
+
+// assertValues asserts the values of a verb in a latency-percent pair of a result set.
+func assertValues(t *testing.T, result *ResultSet, set metrics.MetricSetID, verb string, want map[string]float64) {
+	t.Helper()
+	cell, ok := result.Cells[set]
+	if !ok {
+		t.Fatalf("got no result of %v, want one", set)
+	}
+	verbResult, ok := cell.Verbs[verb]
+	if !ok {
+		t.Fatalf("got no result of verb %v in %v, want one", verb, set)
+	}
+	for name, value := range want {
+		if got, ok := verbResult.Values[name]; !ok || !closeTo(got, value, 1e-9) {
+			t.Errorf("got %v of verb %v in %v %v, want %v", name, verb, set, got, value)
+		}
+	}
+}
+
+func TestLoadStackedCSV(t *testing.T) {
+	result, err := Load(writeFixture(t, "report.csv", []byte(stackedCSV)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Sets) != 2 {
+		t.Fatalf("got sets %v, want 10ms and 20ms at 10%%", result.Sets)
+	}
+	assertValues(t, result, metrics.MetricSetID{Latency: "10ms", Percent: "10"}, constants.ALL, map[string]float64{"P50": 0.01, "P99": 0.1, SuccessRate: 100})
+	assertValues(t, result, metrics.MetricSetID{Latency: "20ms", Percent: "10"}, constants.ALL, map[string]float64{"P50": 0.02, "P99": 0.2, SuccessRate: 99.5})
+	assertValues(t, result, metrics.MetricSetID{Latency: "20ms", Percent: "10"}, constants.CREATE, map[string]float64{"P99": 0.4})
+}
+
+func TestLoadLongCSV(t *testing.T) {
+	result, err := Load(writeFixture(t, "report.csv", []byte(longCSV)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Sets) != 1 {
+		t.Fatalf("got sets %v, want 10ms at 50%% only", result.Sets)
+	}
+	set := metrics.MetricSetID{Latency: "10ms", Percent: "50"}
+	assertValues(t, result, set, constants.ALL, map[string]float64{"P99": 0.2})
+	assertValues(t, result, set, constants.CREATE, map[string]float64{SuccessRate: 100})
+}
+
+func TestLoadResultDocument(t *testing.T) {
+	document := metrics.ResultDocument{
+		SchemaVersion: metrics.ResultSchemaVersion,
+		Cells: []metrics.ResultCell{
+			{Latency: "10ms", Percent: "50", Trial: 1, Verbs: map[string]metrics.ResultVerb{
+				constants.ALL: {SuccessRate: 100, Throughput: 10, Quantiles: map[string]float64{"P99": 0.1}},
+			}},
+			{Latency: "10ms", Percent: "50", Trial: 2, Verbs: map[string]metrics.ResultVerb{
+				constants.ALL: {SuccessRate: 90, Throughput: 20, Quantiles: map[string]float64{"P99": 0.3}},
+			}},
+		},
+	}
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Load(writeFixture(t, "result.json", data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, result, metrics.MetricSetID{Latency: "10ms", Percent: "50"}, constants.ALL, map[string]float64{"P99": 0.2, SuccessRate: 95, "Throughput": 15})
+
+	document.SchemaVersion++
+	if data, err = json.Marshal(document); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(writeFixture(t, "result.json", data), nil); err == nil {
+		t.Error("got no error loading a result document of an unsupported schema version, want one")
+	}
+}
+
+func TestLoadHistograms(t *testing.T) {
+	set := metrics.MetricSetID{Latency: "10ms", Percent: "50"}
+	cells := []metrics.CellStats{
+		{Set: metrics.MetricSetID{Latency: "10ms", Percent: "50", Trial: 1}, Verbs: map[string]*metrics.RequestStats{
+			constants.ALL: newTestStats(t, 1, 1, 1, 1, 1),
+		}},
+		{Set: metrics.MetricSetID{Latency: "10ms", Percent: "50", Trial: 2}, Verbs: map[string]*metrics.RequestStats{
+			constants.ALL: newTestStats(t, 0, 1, 1, 1, 1),
+		}},
+	}
+	data, err := json.Marshal(cells)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Load(writeFixture(t, "histograms.json", data), []float64{0.99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, result, set, constants.ALL, map[string]float64{SuccessRate: 87.5})
+	stats := result.Cells[set].Verbs[constants.ALL].Stats
+	if stats == nil || stats.Total != 8 {
+		t.Fatalf("got merged statistics %+v, want 8 API requests across trials", stats)
+	}
+	if got := result.Cells[set].Verbs[constants.ALL].Values["P99"]; !closeTo(got, 1, 0.01) {
+		t.Errorf("got P99 %v, want 1", got)
+	}
+}
+
+func TestLoadTimeSeries(t *testing.T) {
+	set := metrics.MetricSetID{Latency: "compare-test", Percent: "50", Trial: 1}
+	ts := metrics.StartTimeSeries(set, time.Millisecond)
+	for index := 0; index < 10; index++ {
+		var err error
+		if index == 0 {
+			err = errors.New("failed")
+		}
+		metrics.RecordAPIRequest(constants.CREATE, err, time.Second, set, 0)
+		time.Sleep(time.Millisecond)
+	}
+
+	folder := t.TempDir()
+	path := filepath.Join(folder, "10ms_50.timeseries.json")
+	if err := ts.ExportJSON(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Load(path, []float64{0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The intervals of the test are merged into its latency-percent pair.
+	for _, verb := range []string{constants.CREATE, constants.ALL} {
+		assertValues(t, result, set.Cell(), verb, map[string]float64{SuccessRate: 90})
+		if got := result.Cells[set.Cell()].Verbs[verb].Values["P50"]; !closeTo(got, 1, 0.01) {
+			t.Errorf("got P50 of verb %v %v, want 1", verb, got)
+		}
+	}
+
+	// CSV time series exports hold no histograms.
+	path = filepath.Join(folder, "10ms_50.timeseries.csv")
+	if err := ts.ExportCSV(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, nil); !errors.Is(err, ErrTraceUnsupported) {
+		t.Errorf("got error %v, want %v", err, ErrTraceUnsupported)
+	}
+}
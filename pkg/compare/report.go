@@ -0,0 +1,158 @@
+package compare
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+// Summary prints one comparison sheet per latency-percent pair, with one table per verb.
+func Summary(rows []Row, baselinePath, candidatePath string, confidence float64) {
+	for start := 0; start < len(rows); {
+		end := start
+		for end < len(rows) && rows[end].Set == rows[start].Set {
+			end++
+		}
+		printSheet(rows[start:end], baselinePath, candidatePath, confidence)
+		start = end
+	}
+}
+
+// printSheet prints the comparison sheet of a latency-percent pair.
+func printSheet(rows []Row, baselinePath, candidatePath string, confidence float64) {
+	set := rows[0].Set
+	sheet := printer.NewSheet(0, printer.LineAlignCenter("Performance Comparison"))
+	sheet.SetHeader([]printer.Line{
+		printer.LineAlignRight("Latency: " + set.Latency),
+		printer.LineAlignRight("Percent: " + set.Percent),
+	})
+	sheet.SetFooter([]printer.Line{
+		printer.LineAlignLeft(" Baseline: " + baselinePath),
+		printer.LineAlignLeft("Candidate: " + candidatePath),
+	})
+
+	var tables []printer.Table
+	for start := 0; start < len(rows); {
+		end := start
+		for end < len(rows) && rows[end].Verb == rows[start].Verb {
+			end++
+		}
+		tables = append(tables, prepareComparisonTable(rows[start:end], confidence))
+		start = end
+	}
+	sheet.SetTables(tables)
+
+	printer.PrintEmptyLine()
+	sheet.Print()
+	printer.PrintEmptyLine()
+}
+
+// prepareComparisonTable generates the comparison table of a verb.
+func prepareComparisonTable(rows []Row, confidence float64) printer.Table {
+	title := strings.ToUpper(rows[0].Verb)
+	if !math.IsNaN(rows[0].PValue) {
+		title += fmt.Sprintf(" (Mann-Whitney U p-value: %.4g, P(candidate > baseline): %.3f)", rows[0].PValue, rows[0].Superiority)
+	}
+
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Metric"),
+		printer.LineAlignRight("Baseline"),
+		printer.LineAlignRight("Candidate"),
+		printer.LineAlignRight("Delta"),
+		printer.LineAlignRight("Ratio"),
+		printer.LineAlignRight(fmt.Sprintf("%v%% CI of Delta", math.Round(confidence*100))),
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter(title))
+	table.SetHeaders(headerRow)
+
+	var tableRows []printer.TableRow
+	for _, row := range rows {
+		tableRows = append(tableRows, printer.TableRow{
+			printer.LineAlignRight(row.Metric),
+			printer.LineAlignRight(formatValue(row.Baseline)),
+			printer.LineAlignRight(formatValue(row.Candidate)),
+			printer.LineAlignRight(formatValue(row.Delta)),
+			printer.LineAlignRight(formatRatio(row.Ratio)),
+			printer.LineAlignRight(formatInterval(row.DeltaLow, row.DeltaHigh)),
+		})
+	}
+	table.SetDatum(tableRows)
+	return *table
+}
+
+// ExportCSV exports the comparison to a CSV file, one row per latency-percent pair,
+// verb and metric.
+func ExportCSV(ctx context.Context, rows []Row, filepath string) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"Latency", "Percent", "Verb", "Metric", "Baseline", "Candidate", "Delta", "Ratio", "DeltaLow", "DeltaHigh", "Superiority", "PValue"}); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.Write([]string{
+			row.Set.Latency,
+			row.Set.Percent,
+			row.Verb,
+			row.Metric,
+			formatCSVValue(row.Baseline),
+			formatCSVValue(row.Candidate),
+			formatCSVValue(row.Delta),
+			formatCSVValue(row.Ratio),
+			formatCSVValue(row.DeltaLow),
+			formatCSVValue(row.DeltaHigh),
+			formatCSVValue(row.Superiority),
+			formatCSVValue(row.PValue),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatValue formats a metric value for the terminal.
+func formatValue(value float64) string {
+	if math.IsNaN(value) {
+		return "-"
+	}
+	return fmt.Sprintf("%.5f", value)
+}
+
+// formatRatio formats a ratio for the terminal.
+func formatRatio(ratio float64) string {
+	if math.IsNaN(ratio) || math.IsInf(ratio, 0) {
+		return "-"
+	}
+	return fmt.Sprintf("%.3fx", ratio)
+}
+
+// formatInterval formats a confidence interval for the terminal.
+func formatInterval(low, high float64) string {
+	if math.IsNaN(low) || math.IsNaN(high) {
+		return "-"
+	}
+	return fmt.Sprintf("[%.5f, %.5f]", low, high)
+}
+
+// formatCSVValue formats a value for CSV reports, leaving values that cannot be computed empty.
+func formatCSVValue(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return ""
+	}
+	return fmt.Sprintf("%.10g", value)
+}
@@ -0,0 +1,147 @@
+package compare
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+// MannWhitneyU runs a two-sided Mann-Whitney U test on two latency histograms, returning
+// the probability that a candidate latency is greater than a baseline latency (ties count
+// as half) and the p-value of the test. Values within the same histogram bucket are
+// treated as ties, which is why both histograms must have the same relative accuracy.
+func MannWhitneyU(baseline, candidate *metrics.Histogram) (float64, float64) {
+	n1, n2 := float64(baseline.Count()), float64(candidate.Count())
+	if n1 == 0 || n2 == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	// Group both samples by bucket, every group is a group of ties.
+	groups := make(map[float64][2]float64)
+	for _, bucket := range baseline.Buckets() {
+		group := groups[bucket.UpperBound]
+		group[0] += float64(bucket.Count)
+		groups[bucket.UpperBound] = group
+	}
+	for _, bucket := range candidate.Buckets() {
+		group := groups[bucket.UpperBound]
+		group[1] += float64(bucket.Count)
+		groups[bucket.UpperBound] = group
+	}
+	bounds := make([]float64, 0, len(groups))
+	for bound := range groups {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+
+	// U counts the pairs where the candidate latency is greater than the baseline latency.
+	u, smallerBaselines, tieCorrection := 0.0, 0.0, 0.0
+	for _, bound := range bounds {
+		group := groups[bound]
+		u += group[1] * (smallerBaselines + group[0]/2)
+		smallerBaselines += group[0]
+		ties := group[0] + group[1]
+		tieCorrection += ties*ties*ties - ties
+	}
+
+	n := n1 + n2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - tieCorrection/(n*(n-1)))
+	if variance <= 0 {
+		return u / (n1 * n2), 1
+	}
+	z := (u - mean) / math.Sqrt(variance)
+	return u / (n1 * n2), math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// BootstrapQuantileDelta estimates the confidence interval of the difference of a quantile
+// between two latency histograms (candidate minus baseline) with a Poisson bootstrap: every
+// bucket count is resampled from a Poisson distribution with the count as its mean.
+func BootstrapQuantileDelta(baseline, candidate *metrics.Histogram, quantile, confidence float64, iterations int, rng *rand.Rand) (float64, float64) {
+	baselineBuckets, candidateBuckets := baseline.Buckets(), candidate.Buckets()
+	if len(baselineBuckets) == 0 || len(candidateBuckets) == 0 || iterations <= 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	deltas := make([]float64, 0, iterations)
+	for iteration := 0; iteration < iterations; iteration++ {
+		baselineValue, ok := resampledQuantile(baselineBuckets, quantile, rng)
+		if !ok {
+			continue
+		}
+		candidateValue, ok := resampledQuantile(candidateBuckets, quantile, rng)
+		if !ok {
+			continue
+		}
+		deltas = append(deltas, candidateValue-baselineValue)
+	}
+	if len(deltas) == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	sort.Float64s(deltas)
+	alpha := (1 - confidence) / 2
+	return percentile(deltas, alpha), percentile(deltas, 1-alpha)
+}
+
+// resampledQuantile resamples the counts of a set of buckets and evaluates a quantile on
+// the resampled buckets. It returns false if the resample is empty.
+func resampledQuantile(buckets []metrics.Bucket, quantile float64, rng *rand.Rand) (float64, bool) {
+	counts := make([]uint64, len(buckets))
+	var total uint64
+	for index, bucket := range buckets {
+		counts[index] = poisson(float64(bucket.Count), rng)
+		total += counts[index]
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	rank := uint64(quantile * float64(total-1))
+	var seen uint64
+	for index, count := range counts {
+		seen += count
+		if seen > rank {
+			return buckets[index].Value, true
+		}
+	}
+	return buckets[len(buckets)-1].Value, true
+}
+
+// poisson draws a number from a Poisson distribution with a given mean, approximated by a
+// normal distribution for large means.
+func poisson(mean float64, rng *rand.Rand) uint64 {
+	if mean <= 0 {
+		return 0
+	}
+	if mean > 30 {
+		value := math.Round(mean + math.Sqrt(mean)*rng.NormFloat64())
+		if value < 0 {
+			return 0
+		}
+		return uint64(value)
+	}
+
+	// Knuth's algorithm.
+	limit, product := math.Exp(-mean), rng.Float64()
+	var count uint64
+	for product > limit {
+		count++
+		product *= rng.Float64()
+	}
+	return count
+}
+
+// percentile returns the value at a given percentile of a sorted slice using linear
+// interpolation.
+func percentile(sorted []float64, p float64) float64 {
+	position := p * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
@@ -0,0 +1,90 @@
+package compare
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+// newTestHistogram instantiates a histogram of 1% relative accuracy holding the given values.
+func newTestHistogram(t *testing.T, values ...float64) *metrics.Histogram {
+	histogram, err := metrics.NewHistogram(0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range values {
+		histogram.Observe(value)
+	}
+	return histogram
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		baseline    []float64
+		candidate   []float64
+		superiority float64
+		pValue      float64
+	}{
+		// U = 9 out of 9 pairs, z = 4.5 / sqrt(5.25).
+		{name: "candidate always slower", baseline: []float64{1, 2, 3}, candidate: []float64{4, 5, 6}, superiority: 1, pValue: 0.0495},
+		// U = 6 out of 9 pairs, z = 1.5 / sqrt(5.25).
+		{name: "interleaved", baseline: []float64{1, 3, 5}, candidate: []float64{2, 4, 6}, superiority: 0.6667, pValue: 0.5127},
+		{name: "identical", baseline: []float64{1, 2, 2, 3, 5}, candidate: []float64{1, 2, 2, 3, 5}, superiority: 0.5, pValue: 1},
+		{name: "empty baseline", candidate: []float64{1}, superiority: math.NaN(), pValue: math.NaN()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			superiority, pValue := MannWhitneyU(newTestHistogram(t, test.baseline...), newTestHistogram(t, test.candidate...))
+			if !closeTo(superiority, test.superiority, 1e-4) {
+				t.Errorf("got superiority %v, want %v", superiority, test.superiority)
+			}
+			if !closeTo(pValue, test.pValue, 1e-4) {
+				t.Errorf("got p-value %v, want %v", pValue, test.pValue)
+			}
+		})
+	}
+}
+
+func TestBootstrapQuantileDelta(t *testing.T) {
+	var baseline, slower []float64
+	for index := 1; index <= 1000; index++ {
+		baseline = append(baseline, float64(index)/1000)
+		slower = append(slower, float64(index)/1000+0.5)
+	}
+
+	for _, test := range []struct {
+		name      string
+		baseline  []float64
+		candidate []float64
+		// low and high bound the expected confidence interval from outside.
+		low, high float64
+	}{
+		{name: "identical", baseline: baseline, candidate: baseline, low: -0.05, high: 0.05},
+		{name: "slower", baseline: baseline, candidate: slower, low: 0.4, high: 0.6},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			low, high := BootstrapQuantileDelta(newTestHistogram(t, test.baseline...), newTestHistogram(t, test.candidate...), 0.5, 0.95, 500, rand.New(rand.NewSource(1)))
+			if low > high || low < test.low || high > test.high {
+				t.Errorf("got confidence interval [%v, %v], want within [%v, %v]", low, high, test.low, test.high)
+			}
+			if test.low < 0 && (low > 0 || high < 0) {
+				t.Errorf("got confidence interval [%v, %v], want it to contain 0", low, high)
+			}
+		})
+	}
+
+	if low, high := BootstrapQuantileDelta(newTestHistogram(t), newTestHistogram(t, 1), 0.5, 0.95, 100, rand.New(rand.NewSource(1))); !math.IsNaN(low) || !math.IsNaN(high) {
+		t.Errorf("got confidence interval [%v, %v] of an empty baseline, want NaN", low, high)
+	}
+}
+
+// closeTo returns whether two values are within a tolerance of each other, NaN being close
+// to NaN only.
+func closeTo(got, want, tolerance float64) bool {
+	if math.IsNaN(got) || math.IsNaN(want) {
+		return math.IsNaN(got) && math.IsNaN(want)
+	}
+	return math.Abs(got-want) <= tolerance
+}
@@ -19,3 +19,13 @@ const (
 	// ALL is verb for all API requests.
 	ALL string = "all"
 )
+
+// IsVerb returns whether a verb is a known API request verb.
+func IsVerb(verb string) bool {
+	for _, known := range Verbs {
+		if verb == known {
+			return true
+		}
+	}
+	return false
+}
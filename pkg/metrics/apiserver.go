@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+const (
+	// apiServerRequestDurationMetric is the latency of requests served by kube-apiserver.
+	apiServerRequestDurationMetric = "apiserver_request_duration_seconds"
+	// etcdRequestDurationMetric is the latency of requests sent by kube-apiserver to etcd.
+	etcdRequestDurationMetric = "etcd_request_duration_seconds"
+	// storageObjectsMetric is the number of stored objects by resource.
+	storageObjectsMetric = "apiserver_storage_objects"
+
+	// testedResource is the resource the workers send API requests for.
+	testedResource = "deployments"
+	// testedEtcdType is the type of the objects of the tested resource stored in etcd.
+	testedEtcdType = "*apps.Deployment"
+)
+
+var (
+	// serverQuantiles are the quantiles reported of server-side latencies. Server-side
+	// histograms have coarse buckets, finer quantiles would not be meaningful.
+	serverQuantiles = []float64{0.5, 0.9, 0.99}
+)
+
+// APIServerScraper scrapes the `/metrics` endpoint of kube-apiserver.
+type APIServerScraper struct {
+	// client is the REST client used to talk to kube-apiserver.
+	client rest.Interface
+}
+
+// NewAPIServerScraper instantiates a new scraper that scrapes kube-apiserver with a REST
+// client, e.g. the discovery client of a worker.
+func NewAPIServerScraper(client rest.Interface) *APIServerScraper {
+	return &APIServerScraper{
+		client: client,
+	}
+}
+
+// Scrape scrapes the metrics of kube-apiserver.
+func (s *APIServerScraper) Scrape(ctx context.Context) (Scrape, error) {
+	body, err := s.client.Get().AbsPath("/metrics").DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape kube-apiserver metrics: %w", err)
+	}
+	scrape, err := ParseScrape(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kube-apiserver metrics: %w", err)
+	}
+	return scrape, nil
+}
+
+// ServerStats holds the server-side statistics of a test, computed from the metrics of
+// kube-apiserver scraped before and after the test.
+type ServerStats struct {
+	// RequestDurations are the latencies of the requests served by kube-apiserver for the
+	// tested resource, keyed by kube-apiserver verb (e.g. `POST`).
+	RequestDurations map[string]*ServerHistogram `json:"requestDurations"`
+	// EtcdRequestDurations are the latencies of the requests sent by kube-apiserver to etcd
+	// for the tested resource, keyed by operation (e.g. `create`).
+	EtcdRequestDurations map[string]*ServerHistogram `json:"etcdRequestDurations"`
+	// StorageObjects are the numbers of stored objects before and after the test, keyed by
+	// resource. Only resources whose number changed are kept.
+	StorageObjects map[string]StorageObjects `json:"storageObjects"`
+}
+
+// StorageObjects is the number of stored objects of a resource before and after a test.
+type StorageObjects struct {
+	// Before is the number of objects before the test.
+	Before float64 `json:"before"`
+	// After is the number of objects after the test.
+	After float64 `json:"after"`
+}
+
+// DiffAPIServerMetrics computes the server-side statistics of a test from the metrics of
+// kube-apiserver scraped before and after the test.
+func DiffAPIServerMetrics(before, after Scrape) *ServerStats {
+	server := &ServerStats{
+		RequestDurations: histogramDeltas(before, after, apiServerRequestDurationMetric, "verb", func(labels map[string]string) bool {
+			return labels["resource"] == testedResource && len(labels["subresource"]) == 0
+		}),
+		EtcdRequestDurations: histogramDeltas(before, after, etcdRequestDurationMetric, "operation", func(labels map[string]string) bool {
+			return labels["type"] == testedEtcdType
+		}),
+		StorageObjects: make(map[string]StorageObjects),
+	}
+
+	beforeObjects, afterObjects := sampleValues(before, storageObjectsMetric, "resource"), sampleValues(after, storageObjectsMetric, "resource")
+	for resource, count := range afterObjects {
+		if beforeObjects[resource] != count {
+			server.StorageObjects[resource] = StorageObjects{Before: beforeObjects[resource], After: count}
+		}
+	}
+	for resource, count := range beforeObjects {
+		if _, ok := afterObjects[resource]; !ok {
+			server.StorageObjects[resource] = StorageObjects{Before: count}
+		}
+	}
+	return server
+}
+
+// rows flattens the server-side statistics into named values by a stable order, which
+// are the rows of the server-side tables of exported reports.
+func (s *ServerStats) rows() []namedValue {
+	var rows []namedValue
+	histogramRows := func(prefix string, histograms map[string]*ServerHistogram) {
+		for _, group := range sortedGroups(histograms) {
+			histogram := histograms[group]
+			name := prefix + " " + group
+			rows = append(rows,
+				namedValue{name: name + " count", value: float64(histogram.Count)},
+				namedValue{name: name + " mean", value: histogram.Mean()},
+			)
+			for _, quantile := range serverQuantiles {
+				rows = append(rows, namedValue{name: name + " " + QuantileName(quantile), value: histogram.Quantile(quantile)})
+			}
+		}
+	}
+	histogramRows("apiserver", s.RequestDurations)
+	histogramRows("etcd", s.EtcdRequestDurations)
+
+	resources := make([]string, 0, len(s.StorageObjects))
+	for resource := range s.StorageObjects {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		objects := s.StorageObjects[resource]
+		rows = append(rows, namedValue{name: "objects " + resource + " delta", value: objects.After - objects.Before})
+	}
+	return rows
+}
+
+// prepareServerLatencyTable generates a server-side latency table.
+func prepareServerLatencyTable(title, groupName string, histograms map[string]*ServerHistogram) printer.Table {
+	headerRow := printer.TableRow{
+		printer.LineAlignRight(groupName),
+		printer.LineAlignRight("Count"),
+		printer.LineAlignRight("Mean"),
+	}
+	for _, quantile := range serverQuantiles {
+		headerRow.AddEntry(printer.LineAlignRight(QuantileName(quantile)))
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter(title))
+	table.SetHeaders(headerRow)
+
+	var tableRows []printer.TableRow
+	for _, group := range sortedGroups(histograms) {
+		histogram := histograms[group]
+		row := printer.TableRow{
+			printer.LineAlignRight(strings.ToUpper(group)),
+			printer.LineAlignRight(fmt.Sprint(histogram.Count)),
+			printer.LineAlignRight(fmt.Sprintf("%.5f", histogram.Mean())),
+		}
+		for _, quantile := range serverQuantiles {
+			row.AddEntry(printer.LineAlignRight(fmt.Sprintf("%.5f", histogram.Quantile(quantile))))
+		}
+		tableRows = append(tableRows, row)
+	}
+	table.SetDatum(tableRows)
+
+	return *table
+}
+
+// prepareStorageObjectsTable generates the table of changed numbers of stored objects.
+func prepareStorageObjectsTable(objects map[string]StorageObjects) printer.Table {
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Resource"),
+		printer.LineAlignRight("Before"),
+		printer.LineAlignRight("After"),
+		printer.LineAlignRight("Delta"),
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter("Server-side Storage Objects"))
+	table.SetHeaders(headerRow)
+
+	resources := make([]string, 0, len(objects))
+	for resource := range objects {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	var tableRows []printer.TableRow
+	for _, resource := range resources {
+		count := objects[resource]
+		tableRows = append(tableRows, printer.TableRow{
+			printer.LineAlignRight(resource),
+			printer.LineAlignRight(fmt.Sprint(count.Before)),
+			printer.LineAlignRight(fmt.Sprint(count.After)),
+			printer.LineAlignRight(fmt.Sprintf("%+v", count.After-count.Before)),
+		})
+	}
+	table.SetDatum(tableRows)
+
+	return *table
+}
+
+// prepareServerTables generates the server-side tables of the summary sheet.
+func prepareServerTables(server *ServerStats) []printer.Table {
+	return []printer.Table{
+		prepareServerLatencyTable("Server-side Request Latency (kube-apiserver, "+testedResource+")", "Verb", server.RequestDurations),
+		prepareServerLatencyTable("Server-side Request Latency (etcd, "+testedResource+")", "Operation", server.EtcdRequestDurations),
+		prepareStorageObjectsTable(server.StorageObjects),
+	}
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+)
+
+// metricsBefore is the canned `/metrics` body of kube-apiserver before a test.
+const metricsBefore = `# HELP apiserver_request_duration_seconds Response latency distribution in seconds.
+# TYPE apiserver_request_duration_seconds histogram
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="",verb="POST",le="0.1"} 5
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="",verb="POST",le="0.5"} 8
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="",verb="POST",le="+Inf"} 10
+apiserver_request_duration_seconds_sum{resource="deployments",subresource="",verb="POST"} 2
+apiserver_request_duration_seconds_count{resource="deployments",subresource="",verb="POST"} 10
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="",verb="DELETE",le="0.1"} 4
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="",verb="DELETE",le="0.5"} 4
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="",verb="DELETE",le="+Inf"} 4
+apiserver_request_duration_seconds_sum{resource="deployments",subresource="",verb="DELETE"} 0.2
+apiserver_request_duration_seconds_count{resource="deployments",subresource="",verb="DELETE"} 4
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="status",verb="PUT",le="0.1"} 1
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="status",verb="PUT",le="0.5"} 1
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="status",verb="PUT",le="+Inf"} 1
+apiserver_request_duration_seconds_sum{resource="deployments",subresource="status",verb="PUT"} 0.01
+apiserver_request_duration_seconds_count{resource="deployments",subresource="status",verb="PUT"} 1
+# HELP etcd_request_duration_seconds Etcd request latency in seconds for each operation and object type.
+# TYPE etcd_request_duration_seconds histogram
+etcd_request_duration_seconds_bucket{operation="create",type="*apps.Deployment",le="0.01"} 6
+etcd_request_duration_seconds_bucket{operation="create",type="*apps.Deployment",le="0.1"} 10
+etcd_request_duration_seconds_bucket{operation="create",type="*apps.Deployment",le="+Inf"} 10
+etcd_request_duration_seconds_sum{operation="create",type="*apps.Deployment"} 0.3
+etcd_request_duration_seconds_count{operation="create",type="*apps.Deployment"} 10
+etcd_request_duration_seconds_bucket{operation="get",type="*apps.Deployment",le="0.01"} 90
+etcd_request_duration_seconds_bucket{operation="get",type="*apps.Deployment",le="0.1"} 100
+etcd_request_duration_seconds_bucket{operation="get",type="*apps.Deployment",le="+Inf"} 100
+etcd_request_duration_seconds_sum{operation="get",type="*apps.Deployment"} 1
+etcd_request_duration_seconds_count{operation="get",type="*apps.Deployment"} 100
+# HELP apiserver_storage_objects Number of stored objects at the time of last check split by kind.
+# TYPE apiserver_storage_objects gauge
+apiserver_storage_objects{resource="deployments.apps"} 3
+apiserver_storage_objects{resource="pods"} 5
+apiserver_storage_objects{resource="replicasets.apps"} 2
+`
+
+// metricsAfter is the canned `/metrics` body of kube-apiserver after a test: the series of
+// DELETE requests has disappeared, the etcd series of get operations has been reset, and
+// leases have been updated.
+const metricsAfter = `# HELP apiserver_request_duration_seconds Response latency distribution in seconds.
+# TYPE apiserver_request_duration_seconds histogram
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="",verb="POST",le="0.1"} 15
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="",verb="POST",le="0.5"} 26
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="",verb="POST",le="+Inf"} 30
+apiserver_request_duration_seconds_sum{resource="deployments",subresource="",verb="POST"} 8
+apiserver_request_duration_seconds_count{resource="deployments",subresource="",verb="POST"} 30
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="status",verb="PUT",le="0.1"} 6
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="status",verb="PUT",le="0.5"} 6
+apiserver_request_duration_seconds_bucket{resource="deployments",subresource="status",verb="PUT",le="+Inf"} 6
+apiserver_request_duration_seconds_sum{resource="deployments",subresource="status",verb="PUT"} 0.06
+apiserver_request_duration_seconds_count{resource="deployments",subresource="status",verb="PUT"} 6
+# HELP etcd_request_duration_seconds Etcd request latency in seconds for each operation and object type.
+# TYPE etcd_request_duration_seconds histogram
+etcd_request_duration_seconds_bucket{operation="create",type="*apps.Deployment",le="0.01"} 16
+etcd_request_duration_seconds_bucket{operation="create",type="*apps.Deployment",le="0.1"} 30
+etcd_request_duration_seconds_bucket{operation="create",type="*apps.Deployment",le="+Inf"} 30
+etcd_request_duration_seconds_sum{operation="create",type="*apps.Deployment"} 1.3
+etcd_request_duration_seconds_count{operation="create",type="*apps.Deployment"} 30
+etcd_request_duration_seconds_bucket{operation="get",type="*apps.Deployment",le="0.01"} 3
+etcd_request_duration_seconds_bucket{operation="get",type="*apps.Deployment",le="0.1"} 4
+etcd_request_duration_seconds_bucket{operation="get",type="*apps.Deployment",le="+Inf"} 4
+etcd_request_duration_seconds_sum{operation="get",type="*apps.Deployment"} 0.02
+etcd_request_duration_seconds_count{operation="get",type="*apps.Deployment"} 4
+etcd_request_duration_seconds_bucket{operation="update",type="*coordination.Lease",le="0.01"} 50
+etcd_request_duration_seconds_bucket{operation="update",type="*coordination.Lease",le="0.1"} 50
+etcd_request_duration_seconds_bucket{operation="update",type="*coordination.Lease",le="+Inf"} 50
+etcd_request_duration_seconds_sum{operation="update",type="*coordination.Lease"} 0.2
+etcd_request_duration_seconds_count{operation="update",type="*coordination.Lease"} 50
+# HELP apiserver_storage_objects Number of stored objects at the time of last check split by kind.
+# TYPE apiserver_storage_objects gauge
+apiserver_storage_objects{resource="deployments.apps"} 13
+apiserver_storage_objects{resource="events"} 7
+apiserver_storage_objects{resource="pods"} 5
+`
+
+// cannedMetricsServer serves canned `/metrics` bodies, one per scrape, the last one being
+// served again once every body has been served.
+func cannedMetricsServer(t *testing.T, bodies ...string) *httptest.Server {
+	var (
+		lock    sync.Mutex
+		scrapes int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		lock.Lock()
+		body := bodies[len(bodies)-1]
+		if scrapes < len(bodies) {
+			body = bodies[scrapes]
+		}
+		scrapes++
+		lock.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDiffAPIServerMetrics(t *testing.T) {
+	server := cannedMetricsServer(t, metricsBefore, metricsAfter)
+	client, err := discovery.NewDiscoveryClientForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scraper := NewAPIServerScraper(client.RESTClient())
+
+	before, err := scraper.Scrape(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := scraper.Scrape(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := DiffAPIServerMetrics(before, after)
+
+	// Requests of subresources are left out, and so are series missing after the test.
+	wantRequests := map[string]*ServerHistogram{
+		"POST": {Count: 20, Sum: 6, Buckets: []ServerBucket{{UpperBound: 0.1, Count: 10}, {UpperBound: 0.5, Count: 18}}},
+	}
+	if !reflect.DeepEqual(stats.RequestDurations, wantRequests) {
+		t.Errorf("got request durations %+v, want %+v", stats.RequestDurations, wantRequests)
+	}
+	if quantile := stats.RequestDurations["POST"].Quantile(0.5); quantile != 0.1 {
+		t.Errorf("got request duration P50 %v, want 0.1", quantile)
+	}
+
+	// Series that have been reset are left out, and so are series of other object types.
+	create, ok := stats.EtcdRequestDurations["create"]
+	if !ok || len(stats.EtcdRequestDurations) != 1 {
+		t.Fatalf("got etcd request durations %+v, want operation create only", stats.EtcdRequestDurations)
+	}
+	if create.Count != 20 || create.Sum < 0.999 || create.Sum > 1.001 {
+		t.Errorf("got etcd create count %v and sum %v, want 20 and 1", create.Count, create.Sum)
+	}
+	wantBuckets := []ServerBucket{{UpperBound: 0.01, Count: 10}, {UpperBound: 0.1, Count: 20}}
+	if !reflect.DeepEqual(create.Buckets, wantBuckets) {
+		t.Errorf("got etcd create buckets %+v, want %+v", create.Buckets, wantBuckets)
+	}
+
+	// Unchanged resources are left out, resources missing from either scrape count as 0.
+	wantObjects := map[string]StorageObjects{
+		"deployments.apps": {Before: 3, After: 13},
+		"events":           {Before: 0, After: 7},
+		"replicasets.apps": {Before: 2, After: 0},
+	}
+	if !reflect.DeepEqual(stats.StorageObjects, wantObjects) {
+		t.Errorf("got storage objects %+v, want %+v", stats.StorageObjects, wantObjects)
+	}
+}
+
+func TestDiffAPIServerMetricsWithoutChange(t *testing.T) {
+	before, err := ParseScrape(strings.NewReader(metricsBefore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := DiffAPIServerMetrics(before, before)
+	if len(stats.RequestDurations) != 0 || len(stats.EtcdRequestDurations) != 0 || len(stats.StorageObjects) != 0 {
+		t.Errorf("got %+v, want no server-side statistics", stats)
+	}
+}
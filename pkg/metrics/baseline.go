@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+const (
+	// BaselineLatency is the latency label of baseline tests, which run without any IOChaos.
+	// It is distinct from every injected latency, so that baseline tests are never mistaken
+	// for the tests of a latency-percent pair (e.g. `0ms` at percent 0).
+	BaselineLatency = "baseline"
+	// BaselinePercent is the percent label of baseline tests, which run without any IOChaos.
+	BaselinePercent = "baseline"
+	// SlowdownGroup is the group of slowdown factors in CSV reports in the long layout.
+	SlowdownGroup = "slowdown"
+
+	// driftTolerance is the relative change of a latency quantile between the baseline tests
+	// at the start and at the end of the run above which the cluster is deemed to have drifted.
+	driftTolerance = 0.2
+)
+
+// BaselineSet returns the identity of the metrics of a baseline test. The trial is 0 when
+// the baseline is tested once, otherwise 1 at the start of the run and 2 at its end.
+func BaselineSet(trial int) MetricSetID {
+	return MetricSetID{
+		Latency: BaselineLatency,
+		Percent: BaselinePercent,
+		Trial:   trial,
+	}
+}
+
+// CollectBaseline collects the statistics of a baseline test, like `Collect` does for the
+// tests of latency-percent pairs. Baseline tests are kept apart from the latency-percent matrix.
+func (e *Exporter) CollectBaseline(trial int, start, end time.Time, server *ServerStats, etcd *EtcdStats, usage *ResourceUsage) CellStats {
+	cell := collectCellStats(BaselineSet(trial), start, end, server, etcd, usage)
+	e.baselines = append(e.baselines, cell)
+	return cell
+}
+
+// Baselines returns the statistics of every collected baseline test, by the order of collection.
+func (e *Exporter) Baselines() []CellStats {
+	return e.baselines
+}
+
+// Baseline returns the statistics of the baseline test every test is compared to, i.e. the
+// first one, or nil if no baseline test has been collected.
+func (e *Exporter) Baseline() *CellStats {
+	if len(e.baselines) == 0 {
+		return nil
+	}
+	baseline := e.baselines[0]
+	return &baseline
+}
+
+// Slowdown returns how many times a latency quantile of a verb is slower in a test than in a
+// baseline test. It fails when either test has no API request of the verb, or when the
+// quantile of the baseline is zero.
+func Slowdown(cell, baseline *CellStats, verb string, quantile float64) (float64, bool) {
+	stats, ok := cell.Verbs[verb]
+	if !ok || stats.Latencies.Count() == 0 {
+		return 0, false
+	}
+	reference, ok := baseline.Verbs[verb]
+	if !ok || reference.Latencies.Count() == 0 {
+		return 0, false
+	}
+	if base := reference.Latencies.Quantile(quantile); base > 0 {
+		return stats.Latencies.Quantile(quantile) / base, true
+	}
+	return 0, false
+}
+
+// Slowdowns returns the slowdown factors of every reported quantile of every verb of a test
+// relative to a baseline test, by verb then by quantile name. Factors that cannot be
+// computed are left out.
+func Slowdowns(cell, baseline *CellStats) map[string]map[string]float64 {
+	slowdowns := make(map[string]map[string]float64)
+	for _, verb := range constants.Verbs {
+		for _, quantile := range SortedQuantiles {
+			if factor, ok := Slowdown(cell, baseline, verb, quantile); ok {
+				if _, ok := slowdowns[verb]; !ok {
+					slowdowns[verb] = make(map[string]float64)
+				}
+				slowdowns[verb][QuantileName(quantile)] = factor
+			}
+		}
+	}
+	return slowdowns
+}
+
+// slowdownRows flattens the slowdown factors of a test relative to a baseline test into
+// named values, e.g. `ALL P99`, by the order of verbs and quantiles.
+func slowdownRows(cell, baseline *CellStats) []namedValue {
+	var rows []namedValue
+	for _, verb := range constants.Verbs {
+		for _, row := range verbSlowdownRows(cell, baseline, verb) {
+			rows = append(rows, namedValue{name: strings.ToUpper(verb) + " " + row.name, value: row.value})
+		}
+	}
+	return rows
+}
+
+// verbSlowdownRows flattens the slowdown factors of a verb of a test relative to a baseline
+// test into values named by quantile, by ascending order of quantiles.
+func verbSlowdownRows(cell, baseline *CellStats, verb string) []namedValue {
+	var rows []namedValue
+	for _, quantile := range SortedQuantiles {
+		if factor, ok := Slowdown(cell, baseline, verb, quantile); ok {
+			rows = append(rows, namedValue{name: QuantileName(quantile), value: factor})
+		}
+	}
+	return rows
+}
+
+// Drift returns the slowdown factors of the quantiles of verb `all` in the last baseline
+// test relative to the first one, by quantile name, and whether any of them has changed by
+// more than the drift tolerance. It returns nil when less than two baseline tests have been
+// collected.
+func (e *Exporter) Drift() (map[string]float64, bool) {
+	if len(e.baselines) < 2 {
+		return nil, false
+	}
+	first, last := e.baselines[0], e.baselines[len(e.baselines)-1]
+	drift := make(map[string]float64)
+	drifted := false
+	for _, quantile := range SortedQuantiles {
+		if factor, ok := Slowdown(&last, &first, constants.ALL, quantile); ok {
+			drift[QuantileName(quantile)] = factor
+			if math.Abs(factor-1) > driftTolerance {
+				drifted = true
+			}
+		}
+	}
+	return drift, drifted
+}
+
+// formatDrift formats drift factors by the order of the reported quantiles.
+func formatDrift(drift map[string]float64) string {
+	var items []string
+	for _, quantile := range SortedQuantiles {
+		if factor, ok := drift[QuantileName(quantile)]; ok {
+			items = append(items, fmt.Sprintf("%v x%.2f", QuantileName(quantile), factor))
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// LogDrift logs how much the latencies of the baseline tests at the start and at the end of
+// the run differ, warning when the cluster has likely drifted during the run.
+func (e *Exporter) LogDrift() {
+	drift, drifted := e.Drift()
+	if drift == nil {
+		return
+	}
+	if drifted {
+		klog.Warningf("baseline latencies drifted by more than %.0f%% during the run (end vs start: %v), slowdown factors may be skewed", driftTolerance*100, formatDrift(drift))
+		return
+	}
+	klog.V(2).Infof("baseline latencies are stable across the run (end vs start: %v)", formatDrift(drift))
+}
+
+// prepareSlowdownTable generates the table of the slowdown factors of every reported
+// quantile of every verb of a test relative to a baseline test.
+func prepareSlowdownTable(cell, baseline *CellStats) printer.Table {
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Verb"),
+	}
+	for _, quantile := range SortedQuantiles {
+		headerRow.AddEntry(printer.LineAlignRight(QuantileName(quantile)))
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter("Slowdown vs Baseline (without IOChaos)"))
+	table.SetHeaders(headerRow)
+
+	var tableRows []printer.TableRow
+	for _, verb := range constants.Verbs {
+		row := printer.TableRow{
+			printer.LineAlignRight(strings.ToUpper(verb)),
+		}
+		for _, quantile := range SortedQuantiles {
+			factor := "-"
+			if value, ok := Slowdown(cell, baseline, verb, quantile); ok {
+				factor = fmt.Sprintf("x%.2f", value)
+			}
+			row.AddEntry(printer.LineAlignRight(factor))
+		}
+		tableRows = append(tableRows, row)
+	}
+	table.SetDatum(tableRows)
+	return *table
+}
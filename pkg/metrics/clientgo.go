@@ -0,0 +1,351 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+var (
+	// clients keeps the never-expiring client-side statistics of the workers' REST clients.
+	clients = &clientStatsStore{
+		stats: make(map[statsKey]*ClientStats),
+	}
+
+	clientRequestLatencies = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rest_client_request_duration_seconds",
+			Help:    "Latency of every API request sent by the client-go REST clients of the workers, including retries",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 16), // 1ms to ~32s.
+		},
+		[]string{"verb", "latency", "percent", "trial", "phase"},
+	)
+
+	clientRateLimiterLatencies = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rest_client_rate_limiter_duration_seconds",
+			Help:    "Time the client-go REST clients of the workers spent waiting for their rate limiters",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 16), // 1ms to ~32s.
+		},
+		[]string{"verb", "latency", "percent", "trial", "phase"},
+	)
+
+	clientRequestResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rest_client_requests_total",
+			Help: "Number of HTTP requests sent by the client-go REST clients of the workers, partitioned by status code and method",
+		},
+		[]string{"code", "method", "latency", "percent", "trial", "phase"},
+	)
+
+	clientRequestRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rest_client_request_retries_total",
+			Help: "Number of HTTP requests the client-go REST clients of the workers retried within a single API request",
+		},
+		[]string{"verb", "latency", "percent", "trial", "phase"},
+	)
+)
+
+func init() {
+	registry.MustRegister(clientRequestLatencies)
+	registry.MustRegister(clientRateLimiterLatencies)
+	registry.MustRegister(clientRequestResults)
+	registry.MustRegister(clientRequestRetries)
+}
+
+// apiRequestKey is the context key of the API request being sent.
+type apiRequestKey struct{}
+
+// apiRequest is an API request sent by a worker, tracked through its context by the
+// client-go metric hooks. A REST client invokes the hooks from the goroutine sending
+// the request, so no locking is needed.
+type apiRequest struct {
+	// verb is the verb of the API request.
+	verb string
+	// set is the metric set the API request belongs to.
+	set MetricSetID
+	// attempts is the number of HTTP requests sent for the API request.
+	attempts uint64
+}
+
+// WithAPIRequest returns a context for sending a single API request of a metric set, so
+// that the client-go metric hooks attribute its retries and client-side throttling to it.
+// An API request sent while its test is warming up is attributed to phase `warmup`.
+func WithAPIRequest(ctx context.Context, verb string, set MetricSetID) context.Context {
+	if warmUp.warmingUp(set) {
+		set.Phase = PhaseWarmUp
+	}
+	return context.WithValue(ctx, apiRequestKey{}, &apiRequest{verb: verb, set: set})
+}
+
+// apiRequestFrom returns the API request tracked by a context, nil if there is none.
+func apiRequestFrom(ctx context.Context) *apiRequest {
+	request, _ := ctx.Value(apiRequestKey{}).(*apiRequest)
+	return request
+}
+
+// ClientStats holds the client-side statistics of the API requests of a verb, hidden
+// inside single client-go calls.
+type ClientStats struct {
+	// Attempts is the number of HTTP requests sent, including retries.
+	Attempts uint64 `json:"attempts"`
+	// Retries is the number of HTTP requests retried.
+	Retries uint64 `json:"retries"`
+	// RetriedRequests is the number of API requests retried at least once.
+	RetriedRequests uint64 `json:"retriedRequests"`
+	// ThrottleLatencies is the histogram of time spent waiting for the client-side rate
+	// limiter, observed once per HTTP request.
+	ThrottleLatencies *Histogram `json:"throttleLatencies"`
+}
+
+// NewClientStats instantiates an empty ClientStats.
+func NewClientStats() *ClientStats {
+	throttleLatencies, _ := NewHistogram(relativeAccuracy)
+	return &ClientStats{
+		ThrottleLatencies: throttleLatencies,
+	}
+}
+
+// Merge adds the statistics of another ClientStats to the ClientStats.
+func (s *ClientStats) Merge(other *ClientStats) error {
+	if other == nil {
+		return nil
+	}
+	if err := s.ThrottleLatencies.Merge(other.ThrottleLatencies); err != nil {
+		return err
+	}
+	s.Attempts += other.Attempts
+	s.Retries += other.Retries
+	s.RetriedRequests += other.RetriedRequests
+	return nil
+}
+
+// Copy returns a deep copy of the ClientStats.
+func (s *ClientStats) Copy() *ClientStats {
+	return &ClientStats{
+		Attempts:          s.Attempts,
+		Retries:           s.Retries,
+		RetriedRequests:   s.RetriedRequests,
+		ThrottleLatencies: s.ThrottleLatencies.Copy(),
+	}
+}
+
+// clientStatsStore holds the ClientStats of every verb in every metric set.
+type clientStatsStore struct {
+	lock  sync.Mutex
+	stats map[statsKey]*ClientStats
+}
+
+// update updates the ClientStats of a verb and of verb `all` in a metric set.
+func (s *clientStatsStore) update(verb string, set MetricSetID, update func(stats *ClientStats)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, key := range []statsKey{{verb: verb, set: set}, {verb: constants.ALL, set: set}} {
+		stats, ok := s.stats[key]
+		if !ok {
+			stats = NewClientStats()
+			s.stats[key] = stats
+		}
+		update(stats)
+	}
+}
+
+// get returns a copy of the ClientStats of a verb in a metric set, which is empty if
+// no HTTP request has been recorded for them.
+func (s *clientStatsStore) get(verb string, set MetricSetID) *ClientStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats, ok := s.stats[statsKey{verb: verb, set: set}]
+	if !ok {
+		return NewClientStats()
+	}
+	return stats.Copy()
+}
+
+// collectVerbClientStats gets the client-side statistics of a verb for a metric set,
+// including the API requests of the warm-up period only when they are included in reports.
+func collectVerbClientStats(verb string, set MetricSetID) *ClientStats {
+	clientStats := clients.get(verb, set)
+	if warmUpIncluded() {
+		set.Phase = PhaseWarmUp
+		if err := clientStats.Merge(clients.get(verb, set)); err != nil {
+			klog.Errorf("failed to include client-side statistics of warm-up requests of %v: %v", set, err.Error())
+		}
+	}
+	return clientStats
+}
+
+// collectClientStats gets the client-side statistics of every verb for a metric set.
+func collectClientStats(set MetricSetID) map[string]*ClientStats {
+	stats := make(map[string]*ClientStats)
+	for _, verb := range constants.Verbs {
+		stats[verb] = collectVerbClientStats(verb, set)
+	}
+	return stats
+}
+
+// installClientGoMetrics makes sure the client-go metric hooks are only wrapped once.
+var installClientGoMetrics sync.Once
+
+// InstallClientGoMetrics feeds the metrics of every REST client in the process into the
+// registry. The client-go metric hooks are replaced rather than registered: registering
+// only takes effect once per process and has already been done by controller-runtime when
+// it is linked in, so the adapters wrap the hooks already installed, which keep receiving
+// every observation. It must be called before any REST client sends a request, only the
+// first call takes effect.
+func InstallClientGoMetrics() {
+	installClientGoMetrics.Do(func() {
+		clientmetrics.RequestLatency = &latencyAdapter{
+			metric: clientRequestLatencies,
+			next:   clientmetrics.RequestLatency,
+		}
+		clientmetrics.RateLimiterLatency = &rateLimiterAdapter{latencyAdapter: latencyAdapter{
+			metric: clientRateLimiterLatencies,
+			next:   clientmetrics.RateLimiterLatency,
+		}}
+		clientmetrics.RequestResult = &resultAdapter{
+			metric: clientRequestResults,
+			next:   clientmetrics.RequestResult,
+		}
+	})
+}
+
+// latencyAdapter implements `clientmetrics.LatencyMetric` with a histogram, labeled with
+// the metric set of the API request.
+type latencyAdapter struct {
+	metric *prometheus.HistogramVec
+	// next is the hook installed before the adapter, observing every latency too.
+	next clientmetrics.LatencyMetric
+}
+
+// Observe records the latency of a request. The URL is dropped to keep the cardinality
+// low, since it contains the names of the deployments.
+func (a *latencyAdapter) Observe(ctx context.Context, verb string, u url.URL, latency time.Duration) {
+	if a.next != nil {
+		a.next.Observe(ctx, verb, u, latency)
+	}
+	request := apiRequestFrom(ctx)
+	if request == nil {
+		a.metric.WithLabelValues(verb, "", "", "", "").Observe(latency.Seconds())
+		return
+	}
+	a.metric.WithLabelValues(verb, request.set.Latency, request.set.Percent, request.set.trialLabel(), request.set.phaseLabel()).Observe(latency.Seconds())
+}
+
+// rateLimiterAdapter implements `clientmetrics.LatencyMetric` for the client-side rate
+// limiter, recording the throttling of API requests.
+type rateLimiterAdapter struct {
+	latencyAdapter
+}
+
+// Observe records the time a request waited for the rate limiter.
+func (a *rateLimiterAdapter) Observe(ctx context.Context, verb string, u url.URL, latency time.Duration) {
+	a.latencyAdapter.Observe(ctx, verb, u, latency)
+	if request := apiRequestFrom(ctx); request != nil {
+		clients.update(request.verb, request.set, func(stats *ClientStats) {
+			stats.ThrottleLatencies.Observe(latency.Seconds())
+		})
+	}
+}
+
+// resultAdapter implements `clientmetrics.ResultMetric` with a counter, labeled with the
+// metric set of the API request. Every result is an HTTP request, so every result but the
+// first of an API request is a retry.
+type resultAdapter struct {
+	metric *prometheus.CounterVec
+	// next is the hook installed before the adapter, counting every result too.
+	next clientmetrics.ResultMetric
+}
+
+// Increment counts the result of a request.
+func (a *resultAdapter) Increment(ctx context.Context, code, method, host string) {
+	if a.next != nil {
+		a.next.Increment(ctx, code, method, host)
+	}
+	request := apiRequestFrom(ctx)
+	if request == nil {
+		a.metric.WithLabelValues(code, method, "", "", "", "").Inc()
+		return
+	}
+
+	a.metric.WithLabelValues(code, method, request.set.Latency, request.set.Percent, request.set.trialLabel(), request.set.phaseLabel()).Inc()
+	request.attempts++
+	retried := request.attempts > 1
+	if retried {
+		clientRequestRetries.WithLabelValues(request.verb, request.set.Latency, request.set.Percent, request.set.trialLabel(), request.set.phaseLabel()).Inc()
+	}
+	clients.update(request.verb, request.set, func(stats *ClientStats) {
+		stats.Attempts++
+		if retried {
+			stats.Retries++
+		}
+		if request.attempts == 2 {
+			stats.RetriedRequests++
+		}
+	})
+}
+
+// prepareClientTable generates the table of retries and client-side throttling.
+func prepareClientTable(set MetricSetID) printer.Table {
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Verb"),
+		printer.LineAlignRight("HTTP requests"),
+		printer.LineAlignRight("Retries"),
+		printer.LineAlignRight("Retried"),
+		printer.LineAlignRight("Throttled total"),
+		printer.LineAlignRight("Throttled P99"),
+		printer.LineAlignRight("Throttled Max"),
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter("Client-side Retries and Throttling"))
+	table.SetHeaders(headerRow)
+
+	var tableRows []printer.TableRow
+	for _, verb := range constants.Verbs {
+		stats := collectVerbClientStats(verb, set)
+		tableRows = append(tableRows, printer.TableRow{
+			printer.LineAlignRight(strings.ToUpper(verb)),
+			printer.LineAlignRight(fmt.Sprint(stats.Attempts)),
+			printer.LineAlignRight(fmt.Sprint(stats.Retries)),
+			printer.LineAlignRight(fmt.Sprint(stats.RetriedRequests)),
+			printer.LineAlignRight(fmt.Sprintf("%.5f", stats.ThrottleLatencies.Sum())),
+			printer.LineAlignRight(fmt.Sprintf("%.5f", stats.ThrottleLatencies.Quantile(0.99))),
+			printer.LineAlignRight(fmt.Sprintf("%.5f", stats.ThrottleLatencies.Max())),
+		})
+	}
+	table.SetDatum(tableRows)
+
+	return *table
+}
+
+// clientRows flattens the client-side statistics of every verb into named values by a
+// stable order, which are the rows of the client-side tables of exported reports.
+func clientRows(stats map[string]*ClientStats) []namedValue {
+	var rows []namedValue
+	for _, verb := range constants.Verbs {
+		verbStats, ok := stats[verb]
+		if !ok {
+			continue
+		}
+		rows = append(rows,
+			namedValue{name: verb + " http requests", value: float64(verbStats.Attempts)},
+			namedValue{name: verb + " retries", value: float64(verbStats.Retries)},
+			namedValue{name: verb + " retried requests", value: float64(verbStats.RetriedRequests)},
+			namedValue{name: verb + " throttled total", value: verbStats.ThrottleLatencies.Sum()},
+			namedValue{name: verb + " throttled P99", value: verbStats.ThrottleLatencies.Quantile(0.99)},
+		)
+	}
+	return rows
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+)
+
+// deploymentBody is the canned body of kube-apiserver for a GET request of a deployment.
+const deploymentBody = `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"test","namespace":"default"}}`
+
+// countingResult is a `clientmetrics.ResultMetric` counting its results.
+type countingResult struct {
+	count int32
+}
+
+// Increment counts a result.
+func (r *countingResult) Increment(context.Context, string, string, string) {
+	atomic.AddInt32(&r.count, 1)
+}
+
+func TestInstallClientGoMetrics(t *testing.T) {
+	// The first HTTP request is throttled by kube-apiserver, so the API request is retried.
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"apiVersion":"v1","kind":"Status","status":"Failure","code":429}`))
+			return
+		}
+		w.Write([]byte(deploymentBody))
+	}))
+	t.Cleanup(server.Close)
+
+	// The hooks have already been registered, as controller-runtime does when linked in.
+	registered := &countingResult{}
+	clientmetrics.Register(clientmetrics.RegisterOpts{RequestResult: registered})
+	InstallClientGoMetrics()
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := MetricSetID{Latency: "client-go-test", Percent: "100"}
+	if _, err := client.AppsV1().Deployments("default").Get(WithAPIRequest(context.Background(), constants.GET, set), "test", metav1.GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, verb := range []string{constants.GET, constants.ALL} {
+		stats := clients.get(verb, set)
+		if stats.Attempts != 2 || stats.Retries != 1 || stats.RetriedRequests != 1 {
+			t.Errorf("got %v attempts, %v retries and %v retried requests of verb %v, want 2, 1 and 1", stats.Attempts, stats.Retries, stats.RetriedRequests, verb)
+		}
+		if got := stats.ThrottleLatencies.Count(); got != 2 {
+			t.Errorf("got %v rate limiter observations of verb %v, want 2", got, verb)
+		}
+	}
+	if got := atomic.LoadInt32(&registered.count); got != 2 {
+		t.Errorf("got %v results counted by the registered hook, want 2", got)
+	}
+}
@@ -1,30 +1,31 @@
 package metrics
 
 import (
-	"github.com/prometheus/client_golang/prometheus"
-	dto "github.com/prometheus/client_model/go"
+	"k8s.io/klog/v2"
 )
 
-// collectLatencyMetric gets the overall API request latencies for a metric set.
-func collectLatencyMetric(verb string, set MetricSetID) (*dto.Metric, error) {
-	metric := &dto.Metric{}
-	summary := apiRequestLatencies.WithLabelValues(verb, set.Latency, set.Percent).(prometheus.Summary)
-	if err := summary.Write(metric); err != nil {
-		return nil, err
+// collectRequestStats gets the overall API request statistics of a verb for a metric set,
+// including the API requests of the warm-up period only when they are included in reports.
+func collectRequestStats(verb string, set MetricSetID) *RequestStats {
+	requestStats := stats.get(verb, set)
+	if warmUpIncluded() {
+		set.Phase = PhaseWarmUp
+		if err := requestStats.Merge(stats.get(verb, set)); err != nil {
+			klog.Errorf("failed to include warm-up requests of %v: %v", set, err.Error())
+		}
 	}
-	return metric, nil
+	return requestStats
+}
+
+// collectWarmUpRequestStats gets the API request statistics of a verb sent during the
+// warm-up period of a metric set.
+func collectWarmUpRequestStats(verb string, set MetricSetID) *RequestStats {
+	set.Phase = PhaseWarmUp
+	return stats.get(verb, set)
 }
 
 // collectSuccessRate gets the overall API request success metrics for a metric set.
-func collectSuccessRateMetrics(verb string, set MetricSetID) (float64, float64, float64, error) {
-	metric := &dto.Metric{}
-	if err := totalAPIRequests.WithLabelValues(verb, set.Latency, set.Percent).Write(metric); err != nil {
-		return 0, 0, 0, err
-	}
-	allGets := metric.Counter.GetValue()
-	if err := successfulAPIRequests.WithLabelValues(verb, set.Latency, set.Percent).Write(metric); err != nil {
-		return 0, 0, 0, err
-	}
-	allSuccessfulGets := metric.Counter.GetValue()
-	return allGets, allSuccessfulGets, allSuccessfulGets * 100 / allGets, nil
+func collectSuccessRateMetrics(verb string, set MetricSetID) (float64, float64, float64) {
+	requestStats := collectRequestStats(verb, set)
+	return float64(requestStats.Total), float64(requestStats.Successful), requestStats.SuccessRate()
 }
@@ -0,0 +1,400 @@
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+const (
+	// progressBarWidth is the width of the progress bars of the dashboard.
+	progressBarWidth = 30
+	// defaultTerminalHeight is the number of rows assumed when the size of the terminal is unknown.
+	defaultTerminalHeight = 40
+)
+
+// Dashboard reports the progress of the run while it is running. On a terminal, a live
+// view is refreshed in place; otherwise, plain-text progress lines are logged periodically.
+// When stderr is the terminal of the live view, logs are routed through the dashboard,
+// which erases the live view before writing them so that they do not corrupt it.
+type Dashboard struct {
+	lock sync.Mutex
+
+	// out is where the dashboard is printed.
+	out *os.File
+	// interactive is `true` when `out` is a terminal.
+	interactive bool
+	// restoreLogs restores the logging settings changed to route logs through the
+	// dashboard, nil when logs are not routed.
+	restoreLogs func()
+	// interval is the interval between refreshes, or between progress lines.
+	interval time.Duration
+	// lines is the number of terminal rows the frame currently drawn takes up.
+	lines int
+	// hidden is `true` when drawing is suspended.
+	hidden bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartDashboard starts reporting the progress of the run to stdout. The live view is
+// refreshed every `refreshInterval` when stdout is a terminal, otherwise a progress line is
+// logged every `lineInterval`.
+func StartDashboard(refreshInterval, lineInterval time.Duration) *Dashboard {
+	d := &Dashboard{
+		out:         os.Stdout,
+		interactive: term.IsTerminal(int(os.Stdout.Fd())),
+		interval:    lineInterval,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if d.interactive {
+		d.interval = refreshInterval
+		if sameFile(os.Stdout, os.Stderr) {
+			d.routeLogs()
+		}
+	}
+
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.refresh()
+			}
+		}
+	}()
+	return d
+}
+
+// Stop stops reporting the progress. The last frame of the live view is left on the terminal.
+func (d *Dashboard) Stop() {
+	close(d.stop)
+	<-d.done
+
+	d.lock.Lock()
+	if d.interactive && !d.hidden {
+		d.draw()
+	}
+	d.lines = 0
+	d.lock.Unlock()
+
+	if d.restoreLogs != nil {
+		d.restoreLogs()
+		d.restoreLogs = nil
+	}
+}
+
+// routeLogs routes the logs written to stderr through the dashboard. Every log line is
+// written to the info log only, which every severity falls through to, and no severity is
+// written to stderr directly.
+func (d *Dashboard) routeLogs() {
+	flags := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	if flags.Lookup("logtostderr").Value.String() != "true" {
+		// Logs are written to files, they do not reach the terminal.
+		return
+	}
+	saved := make(map[string]string)
+	for name, value := range map[string]string{
+		"alsologtostderr": "false",
+		"one_output":      "false",
+		// Above the fatal severity.
+		"stderrthreshold": "4",
+	} {
+		saved[name] = flags.Lookup(name).Value.String()
+		_ = flags.Set(name, value)
+	}
+	klog.SetOutputBySeverity("INFO", &dashboardLogWriter{dashboard: d})
+	for _, name := range []string{"WARNING", "ERROR", "FATAL"} {
+		klog.SetOutputBySeverity(name, io.Discard)
+	}
+	klog.LogToStderr(false)
+
+	d.restoreLogs = func() {
+		klog.LogToStderr(true)
+		for name, value := range saved {
+			_ = flags.Set(name, value)
+		}
+	}
+}
+
+// dashboardLogWriter writes logs to stderr through a dashboard.
+type dashboardLogWriter struct {
+	dashboard *Dashboard
+}
+
+// Write erases the live view and writes a log to stderr, the live view is drawn again at
+// its next refresh.
+func (w *dashboardLogWriter) Write(data []byte) (int, error) {
+	d := w.dashboard
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.erase()
+	return os.Stderr.Write(data)
+}
+
+// Hide erases the live view and suspends refreshing it until `Show` is called, so that
+// other output (e.g. summaries) can be printed without being overwritten.
+func (d *Dashboard) Hide() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.erase()
+	d.hidden = true
+}
+
+// Show resumes refreshing the live view.
+func (d *Dashboard) Show() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.hidden = false
+}
+
+// refresh redraws the live view, or logs a progress line, keeping stdout for reports.
+func (d *Dashboard) refresh() {
+	if !d.interactive {
+		klog.Info(ProgressLine(CurrentProgress()))
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.hidden {
+		d.draw()
+	}
+}
+
+// draw erases the frame currently drawn and draws a new one.
+func (d *Dashboard) draw() {
+	width, height, err := term.GetSize(int(d.out.Fd()))
+	if err != nil {
+		width, height = 0, defaultTerminalHeight
+	}
+
+	// The live view is always drawn in boxes, whatever the output style of reports.
+	renderer := printer.ASCII
+	if _, ok := printer.Default.(*printer.BoxRenderer); ok {
+		renderer = printer.Default
+	}
+	var buffer bytes.Buffer
+	_ = ProgressSheet(CurrentProgress(), height).Render(&buffer, renderer)
+	frame := buffer.String()
+
+	d.erase()
+	_, _ = d.out.WriteString(frame)
+	d.lines = 0
+	for _, line := range strings.Split(strings.TrimSuffix(frame, "\n"), "\n") {
+		// Lines wider than the terminal wrap onto several rows.
+		rows := 1
+		if length := utf8.RuneCountInString(line); width > 0 && length > width {
+			rows = (length + width - 1) / width
+		}
+		d.lines += rows
+	}
+}
+
+// erase erases the frame currently drawn by moving the cursor up to its first row and
+// clearing the screen from there.
+func (d *Dashboard) erase() {
+	if d.lines > 0 {
+		_, _ = fmt.Fprintf(d.out, "\033[%dA\033[J", d.lines)
+		d.lines = 0
+	}
+}
+
+// sameFile returns whether two open files are the same file, e.g. the same terminal.
+func sameFile(a, b *os.File) bool {
+	aInfo, err := a.Stat()
+	if err != nil {
+		return false
+	}
+	bInfo, err := b.Stat()
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
+
+// ProgressLine formats the progress of the run as a single line of plain text.
+func ProgressLine(p *Progress) string {
+	items := []string{fmt.Sprintf("test %v/%v", currentTestNumber(p), p.Total)}
+	if p.Running {
+		items = append(items,
+			fmt.Sprintf("IOChaos (%v) %v", p.Set, p.ChaosStatus),
+			fmt.Sprintf("%.1f%% done", p.Ratio*100),
+		)
+		all := p.Verbs[constants.ALL]
+		if all == nil {
+			all = NewRequestStats()
+		}
+		items = append(items,
+			fmt.Sprintf("%.1f req/s", p.Throughput(constants.ALL)),
+			fmt.Sprintf("p50 %v", formatSeconds(all.Latencies.Quantile(0.5))),
+			fmt.Sprintf("p99 %v", formatSeconds(all.Latencies.Quantile(0.99))),
+		)
+	} else if len(p.ChaosStatus) > 0 {
+		items = append(items, fmt.Sprintf("IOChaos (%v) %v", p.Set, p.ChaosStatus))
+	}
+	items = append(items, "elapsed "+p.Elapsed.Round(time.Second).String(), "ETA "+formatETA(p.ETA()))
+	return "progress: " + strings.Join(items, ", ")
+}
+
+// ProgressSheet generates the sheet of the live view: the current test, the overall
+// progress and ETA, live throughputs and latencies by verb, and the progress of every
+// worker. Workers are left out, the least advanced ones first, so that the sheet fits in
+// a terminal of a given height.
+func ProgressSheet(p *Progress, height int) *printer.Sheet {
+	sheet := printer.NewSheet(0, printer.LineAlignCenter("Performance Testing Progress"))
+
+	header := []printer.Line{
+		printer.LineAlignLeft(fmt.Sprintf("Current test: %v/%v (%v)", currentTestNumber(p), p.Total, p.Set)),
+		printer.LineAlignLeft("IOChaos status: " + p.ChaosStatus),
+		printer.LineAlignLeft(fmt.Sprintf("Test progress: %v %5.1f%% (%v/%v requests, %v)", progressBar(p.Ratio), p.Ratio*100, p.Sent, p.Expected, p.TestElapsed.Round(time.Second))),
+	}
+	overall := 0.0
+	if p.Total > 0 {
+		overall = (float64(p.Finished) + p.Ratio) / float64(p.Total)
+	}
+	header = append(header,
+		printer.LineAlignLeft(fmt.Sprintf("Run progress:  %v %5.1f%% (%v/%v tests, %v)", progressBar(overall), overall*100, p.Finished, p.Total, p.Elapsed.Round(time.Second))),
+		printer.LineAlignLeft("ETA: "+formatETA(p.ETA())),
+	)
+	sheet.SetHeader(header)
+
+	tables := []printer.Table{prepareLiveRequestTable(p)}
+	// Every row of the sheet but the rows of the worker table: the sheet title and header,
+	// the request table, the worker table's own lines and the footer, keeping the last row
+	// of the terminal for the cursor.
+	rows := height - (len(header) + 4) - (len(constants.Verbs) + 6) - 7 - 4 - 1
+	if len(p.Workers) > 0 {
+		table, hidden := prepareWorkerProgressTable(p, rows)
+		tables = append(tables, table)
+		if hidden > 0 {
+			sheet.SetFooter([]printer.Line{
+				printer.LineAlignLeft(fmt.Sprintf("%v more workers not shown", hidden)),
+			})
+		}
+	}
+	sheet.SetTables(tables)
+	return sheet
+}
+
+// prepareLiveRequestTable generates the table of the throughputs and latencies by verb
+// during the rolling window.
+func prepareLiveRequestTable(p *Progress) printer.Table {
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Verb"),
+		printer.LineAlignRight("Requests/s"),
+		printer.LineAlignRight("Errors"),
+		printer.LineAlignRight("P50"),
+		printer.LineAlignRight("P99"),
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter(fmt.Sprintf("Last %v", p.Window.Round(time.Second))))
+	table.SetHeaders(headerRow)
+
+	var tableRows []printer.TableRow
+	for _, verb := range constants.Verbs {
+		stats, ok := p.Verbs[verb]
+		if !ok {
+			stats = NewRequestStats()
+		}
+		tableRows = append(tableRows, printer.TableRow{
+			printer.LineAlignRight(strings.ToUpper(verb)),
+			printer.LineAlignRight(fmt.Sprintf("%.1f", p.Throughput(verb))),
+			printer.LineAlignRight(fmt.Sprint(stats.Total - stats.Successful)),
+			printer.LineAlignRight(formatSeconds(stats.Latencies.Quantile(0.5))),
+			printer.LineAlignRight(formatSeconds(stats.Latencies.Quantile(0.99))),
+		})
+	}
+	table.SetDatum(tableRows)
+	return *table
+}
+
+// prepareWorkerProgressTable generates the table of the progress of every worker, with at
+// most a given number of rows. It returns the number of workers left out.
+func prepareWorkerProgressTable(p *Progress, rows int) (printer.Table, int) {
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Worker"),
+		printer.LineAlignLeft("Progress"),
+		printer.LineAlignRight("Requests"),
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter("Workers"))
+	table.SetHeaders(headerRow)
+
+	ids := make([]int, len(p.Workers))
+	for id := range ids {
+		ids[id] = id
+	}
+	hidden := 0
+	if rows < 1 {
+		rows = 1
+	}
+	if len(ids) > rows {
+		// Keep the least advanced workers, which the test is waiting for.
+		sort.SliceStable(ids, func(i, j int) bool {
+			return p.Workers[ids[i]] < p.Workers[ids[j]]
+		})
+		hidden = len(ids) - rows
+		ids = ids[:rows]
+		sort.Ints(ids)
+	}
+
+	var tableRows []printer.TableRow
+	for _, id := range ids {
+		ratio := 0.0
+		if p.RequestsPerWorker > 0 {
+			ratio = float64(p.Workers[id]) / float64(p.RequestsPerWorker)
+		}
+		tableRows = append(tableRows, printer.TableRow{
+			printer.LineAlignRight(fmt.Sprint(id)),
+			printer.LineAlignLeft(progressBar(ratio)),
+			printer.LineAlignRight(fmt.Sprintf("%v/%v", p.Workers[id], p.RequestsPerWorker)),
+		})
+	}
+	table.SetDatum(tableRows)
+	return *table, hidden
+}
+
+// currentTestNumber returns the 1-based number of the current test, or the number of
+// finished tests when no test is running.
+func currentTestNumber(p *Progress) int {
+	if p.Running {
+		return p.Finished + 1
+	}
+	return p.Finished
+}
+
+// progressBar draws a progress bar of a given ratio.
+func progressBar(ratio float64) string {
+	return "[" + printer.Bar(ratio, progressBarWidth) + "]"
+}
+
+// formatSeconds formats a latency in seconds as a duration.
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Microsecond * 10).String()
+}
+
+// formatETA formats an estimated duration, which is unknown when negative.
+func formatETA(eta time.Duration) string {
+	if eta < 0 {
+		return "unknown"
+	}
+	return eta.Round(time.Second).String()
+}
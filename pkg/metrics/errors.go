@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ReasonCanceled is the reason of API requests cancelled by the test flow.
+	ReasonCanceled = "Canceled"
+	// ReasonDeadlineExceeded is the reason of API requests whose context deadline exceeded.
+	ReasonDeadlineExceeded = "DeadlineExceeded"
+	// ReasonNetwork is the reason of API requests that failed with a network error, e.g. a
+	// connection reset.
+	ReasonNetwork = "Network"
+	// ReasonUnknown is the reason of API requests that failed otherwise.
+	ReasonUnknown = "Unknown"
+)
+
+// ErrorReason classifies the error of a failed API request: the reason of the status
+// returned by kube-apiserver (e.g. `Timeout`, `Conflict`), or a client-side reason when no
+// status was returned. It is empty for a nil error.
+func ErrorReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	if reason := apierrors.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ReasonCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ReasonDeadlineExceeded
+	case errors.As(err, &netErr):
+		return ReasonNetwork
+	}
+	return ReasonUnknown
+}
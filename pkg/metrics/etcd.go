@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+const (
+	// walFsyncDurationMetric is the latency of WAL fsync calls of an etcd member.
+	walFsyncDurationMetric = "etcd_disk_wal_fsync_duration_seconds"
+	// backendCommitDurationMetric is the latency of backend commits of an etcd member.
+	backendCommitDurationMetric = "etcd_disk_backend_commit_duration_seconds"
+	// leaderChangesMetric is the number of leader changes seen by an etcd member.
+	leaderChangesMetric = "etcd_server_leader_changes_seen_total"
+	// proposalsFailedMetric is the number of failed proposals of an etcd member.
+	proposalsFailedMetric = "etcd_server_proposals_failed_total"
+
+	// etcdScrapeTimeout is the timeout of scraping an etcd member.
+	etcdScrapeTimeout = 10 * time.Second
+)
+
+// EtcdScraper scrapes the `/metrics` endpoint of every etcd member.
+type EtcdScraper struct {
+	// endpoints are the client URLs of etcd members, e.g. `https://10.0.0.1:2379`.
+	endpoints []string
+	// client is the HTTP client used to talk to etcd members.
+	client *http.Client
+}
+
+// NewEtcdScraper instantiates a new scraper of etcd members. The CA file, the client
+// certificate file and the client key file are optional, the client certificate and key
+// must be provided together.
+func NewEtcdScraper(endpoints []string, caFile, certFile, keyFile string, insecureSkipVerify bool) (*EtcdScraper, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	if len(caFile) > 0 {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read etcd CA file %v: %w", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid certificate found in etcd CA file %v", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(certFile) > 0 || len(keyFile) > 0 {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load etcd client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &EtcdScraper{
+		endpoints: endpoints,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   etcdScrapeTimeout,
+		},
+	}, nil
+}
+
+// Scrape scrapes the metrics of every etcd member, keyed by endpoint. The metrics of the
+// members that have been scraped are returned even if scraping other members failed.
+func (s *EtcdScraper) Scrape(ctx context.Context) (map[string]Scrape, error) {
+	scrapes := make(map[string]Scrape)
+	var errs []error
+	for _, endpoint := range s.endpoints {
+		scrape, err := s.scrape(ctx, endpoint)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		scrapes[endpoint] = scrape
+	}
+	return scrapes, utilerrors.NewAggregate(errs)
+}
+
+// scrape scrapes the metrics of an etcd member.
+func (s *EtcdScraper) scrape(ctx context.Context, endpoint string) (Scrape, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/metrics", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape etcd member %v: %w", endpoint, err)
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape etcd member %v: %w", endpoint, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to scrape etcd member %v: unexpected status %v", endpoint, response.Status)
+	}
+
+	scrape, err := ParseScrape(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics of etcd member %v: %w", endpoint, err)
+	}
+	return scrape, nil
+}
+
+// EtcdStats holds the statistics reported by etcd members during a test, computed from
+// their metrics scraped before and after the test.
+type EtcdStats struct {
+	// Members maps the endpoints of etcd members to their statistics.
+	Members map[string]*EtcdMemberStats `json:"members"`
+}
+
+// EtcdMemberStats holds the statistics reported by an etcd member during a test.
+type EtcdMemberStats struct {
+	// WALFsyncDurations are the latencies of WAL fsync calls.
+	WALFsyncDurations *ServerHistogram `json:"walFsyncDurations"`
+	// BackendCommitDurations are the latencies of backend commits.
+	BackendCommitDurations *ServerHistogram `json:"backendCommitDurations"`
+	// LeaderChanges is the number of leader changes seen.
+	LeaderChanges float64 `json:"leaderChanges"`
+	// ProposalsFailed is the number of failed proposals.
+	ProposalsFailed float64 `json:"proposalsFailed"`
+}
+
+// DiffEtcdMetrics computes the statistics reported by etcd members during a test from their
+// metrics scraped before and after the test. Members not scraped both times are skipped.
+func DiffEtcdMetrics(before, after map[string]Scrape) *EtcdStats {
+	etcd := &EtcdStats{
+		Members: make(map[string]*EtcdMemberStats),
+	}
+	for endpoint, afterScrape := range after {
+		beforeScrape, ok := before[endpoint]
+		if !ok {
+			continue
+		}
+		etcd.Members[endpoint] = &EtcdMemberStats{
+			WALFsyncDurations:      histogramDelta(beforeScrape, afterScrape, walFsyncDurationMetric),
+			BackendCommitDurations: histogramDelta(beforeScrape, afterScrape, backendCommitDurationMetric),
+			LeaderChanges:          counterDelta(beforeScrape, afterScrape, leaderChangesMetric),
+			ProposalsFailed:        counterDelta(beforeScrape, afterScrape, proposalsFailedMetric),
+		}
+	}
+	return etcd
+}
+
+// histogramDelta computes the difference of a histogram between two scrapes, summing all
+// series.
+func histogramDelta(before, after Scrape, name string) *ServerHistogram {
+	if histogram, ok := histogramDeltas(before, after, name, "", nil)[""]; ok {
+		return histogram
+	}
+	return &ServerHistogram{}
+}
+
+// counterDelta computes the difference of a counter between two scrapes, summing all series.
+func counterDelta(before, after Scrape, name string) float64 {
+	return sampleValues(after, name, "")[""] - sampleValues(before, name, "")[""]
+}
+
+// endpoints returns the endpoints of etcd members by ascending order.
+func (s *EtcdStats) endpoints() []string {
+	endpoints := make([]string, 0, len(s.Members))
+	for endpoint := range s.Members {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// rows flattens the etcd statistics into named values by a stable order, which are the
+// rows of the etcd tables of exported reports.
+func (s *EtcdStats) rows() []namedValue {
+	var rows []namedValue
+	for _, endpoint := range s.endpoints() {
+		member := s.Members[endpoint]
+		for _, histogram := range []struct {
+			name string
+			*ServerHistogram
+		}{
+			{name: "wal fsync", ServerHistogram: member.WALFsyncDurations},
+			{name: "backend commit", ServerHistogram: member.BackendCommitDurations},
+		} {
+			name := endpoint + " " + histogram.name
+			rows = append(rows,
+				namedValue{name: name + " count", value: float64(histogram.Count)},
+				namedValue{name: name + " mean", value: histogram.Mean()},
+			)
+			for _, quantile := range serverQuantiles {
+				rows = append(rows, namedValue{name: name + " " + QuantileName(quantile), value: histogram.Quantile(quantile)})
+			}
+		}
+		rows = append(rows,
+			namedValue{name: endpoint + " leader changes", value: member.LeaderChanges},
+			namedValue{name: endpoint + " proposals failed", value: member.ProposalsFailed},
+		)
+	}
+	return rows
+}
+
+// prepareEtcdTable generates the table of the statistics reported by etcd members.
+func prepareEtcdTable(etcd *EtcdStats) printer.Table {
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Member"),
+		printer.LineAlignRight("Fsyncs"),
+	}
+	for _, quantile := range serverQuantiles {
+		headerRow.AddEntry(printer.LineAlignRight("Fsync " + QuantileName(quantile)))
+	}
+	headerRow.AddEntry(printer.LineAlignRight("Commit " + QuantileName(serverQuantiles[len(serverQuantiles)-1])))
+	headerRow.AddEntry(printer.LineAlignRight("Leader changes"))
+	headerRow.AddEntry(printer.LineAlignRight("Proposals failed"))
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter("etcd Member Disk Latency"))
+	table.SetHeaders(headerRow)
+
+	var tableRows []printer.TableRow
+	for _, endpoint := range etcd.endpoints() {
+		member := etcd.Members[endpoint]
+		row := printer.TableRow{
+			printer.LineAlignRight(endpoint),
+			printer.LineAlignRight(fmt.Sprint(member.WALFsyncDurations.Count)),
+		}
+		for _, quantile := range serverQuantiles {
+			row.AddEntry(printer.LineAlignRight(fmt.Sprintf("%.5f", member.WALFsyncDurations.Quantile(quantile))))
+		}
+		row.AddEntry(printer.LineAlignRight(fmt.Sprintf("%.5f", member.BackendCommitDurations.Quantile(serverQuantiles[len(serverQuantiles)-1]))))
+		row.AddEntry(printer.LineAlignRight(fmt.Sprint(member.LeaderChanges)))
+		row.AddEntry(printer.LineAlignRight(fmt.Sprint(member.ProposalsFailed)))
+		tableRows = append(tableRows, row)
+	}
+	table.SetDatum(tableRows)
+
+	return *table
+}
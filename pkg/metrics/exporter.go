@@ -3,8 +3,10 @@ package metrics
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 
 	"github.com/nemoremold/perftests/pkg/constants"
 	"github.com/nemoremold/perftests/pkg/options"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
 )
 
 // Exporter summarizes the final and overall performance testing result, generating
@@ -21,31 +24,79 @@ type Exporter struct {
 	latencies []string
 	// percents are percent labels.
 	percents []string
+	// trials is the number of trials of every latency-percent pair.
+	trials int
 
 	// header is the header row for each table.
 	header rowData
 	// titles are the title of tables, its item index is also the table id.
 	titles []string
-	// datum are the tables of metrics, its item index is also the table id.
-	datum []map[float64]rowData
+	// datum are the tables of metrics, its item index is also the table id. The rows of
+	// each table are the reported quantiles by ascending order, followed by the success rate.
+	// With several trials, every percent has one table per statistic in `trialStatistics`.
+	datum [][]rowData
+	// cells are the statistics of every collected latency-percent pair.
+	cells []CellStats
+	// baselines are the statistics of every collected baseline test, by the order of
+	// collection.
+	baselines []CellStats
 
-	// numberOfTables is the number of table, equal to the number of percents.
+	// numberOfTables is the number of table, equal to the number of percents times the
+	// number of tables per percent.
 	numberOfTables int
+	// numberOfTablesPerPercent is the number of tables of every percent, 1 with a single trial.
+	numberOfTablesPerPercent int
 	// numberOfColumns is the number of table columns, equal to the number of latencies plus one.
 	numberOfColumns int
-	// numberOfColumns is the number of table rows, equal to the number of summary quantiles plus one.
+	// numberOfColumns is the number of table rows, equal to the number of reported quantiles plus one.
 	numberOfDataRowsPerTable int
 }
 
+// CellStats holds the statistics of every verb collected for a latency-percent pair.
+type CellStats struct {
+	// Set identifies the latency-percent pair.
+	Set MetricSetID `json:"set"`
+	// Start is the time the test started.
+	Start time.Time `json:"start"`
+	// End is the time the test finished.
+	End time.Time `json:"end"`
+	// Verbs maps verbs to their request statistics.
+	Verbs map[string]*RequestStats `json:"verbs"`
+	// Client maps verbs to the client-side statistics of their API requests, i.e. the
+	// retries and client-side throttling hidden inside single client-go calls.
+	Client map[string]*ClientStats `json:"client,omitempty"`
+	// Workers are the statistics of every worker, they are nil when the per-worker
+	// breakdown is disabled.
+	Workers []*WorkerStats `json:"workers,omitempty"`
+	// Fairness indicates how evenly the workers have been served, it is nil when the
+	// per-worker breakdown is disabled.
+	Fairness *Fairness `json:"fairness,omitempty"`
+	// Server is the server-side statistics of the test, it is nil when kube-apiserver
+	// metrics are not scraped.
+	Server *ServerStats `json:"server,omitempty"`
+	// Etcd is the statistics reported by etcd members during the test, it is nil when etcd
+	// metrics are not scraped.
+	Etcd *EtcdStats `json:"etcd,omitempty"`
+	// Resources is the resource usage of the load generator during the test, it is nil
+	// when the resource usage is not monitored.
+	Resources *ResourceUsage `json:"resources,omitempty"`
+}
+
+// trialStatistics are the suffixes of the titles of the tables of every percent when every
+// latency-percent pair is tested several times: the mean, the standard deviation and the
+// bounds of the 95% confidence interval of the mean across trials.
+var trialStatistics = []string{"", " stddev", " 95% CI low", " 95% CI high"}
+
 // rowData is a string slice that corresponds to a line in the csv file.
 // Each item is an entry in the table.
 type rowData []string
 
 // NewExporter instantiates a new exporter instance.
-func NewExporter(latencies, percents []string) *Exporter {
+func NewExporter(latencies, percents []string, trials int) *Exporter {
 	e := &Exporter{
 		latencies: latencies,
 		percents:  percents,
+		trials:    trials,
 	}
 	e.init()
 	return e
@@ -53,9 +104,13 @@ func NewExporter(latencies, percents []string) *Exporter {
 
 // init initializes the Exporter, preparing metrics table.
 func (e *Exporter) init() {
-	e.numberOfTables = len(e.percents)
+	e.numberOfTablesPerPercent = 1
+	if e.trials > 1 {
+		e.numberOfTablesPerPercent = len(trialStatistics)
+	}
+	e.numberOfTables = len(e.percents) * e.numberOfTablesPerPercent
 	e.numberOfColumns = len(e.latencies) + 1
-	e.numberOfDataRowsPerTable = len(SummaryObjectives) + 1
+	e.numberOfDataRowsPerTable = len(SortedQuantiles) + 1
 
 	// Set table headers.
 	e.header = make(rowData, e.numberOfColumns)
@@ -68,43 +123,57 @@ func (e *Exporter) init() {
 	// Set title for each table.
 	e.titles = make([]string, e.numberOfTables)
 	for index, percent := range e.percents {
-		e.titles[index] = percent + "% sample"
+		for statistic := 0; statistic < e.numberOfTablesPerPercent; statistic++ {
+			e.titles[index*e.numberOfTablesPerPercent+statistic] = percent + "% sample" + trialStatistics[statistic]
+		}
 	}
 
 	// Set table indexes.
-	e.datum = make([]map[float64]rowData, e.numberOfTables)
+	e.datum = make([][]rowData, e.numberOfTables)
 	for index := range e.datum {
-		e.datum[index] = make(map[float64]rowData)
-		for quantile := range SummaryObjectives {
-			e.datum[index][quantile] = make(rowData, e.numberOfColumns)
-			e.datum[index][quantile][0] = fmt.Sprint(int(quantile*100)) + "%"
+		e.datum[index] = make([]rowData, e.numberOfDataRowsPerTable)
+		for row, quantile := range SortedQuantiles {
+			e.datum[index][row] = make(rowData, e.numberOfColumns)
+			e.datum[index][row][0] = QuantileName(quantile)
 		}
-		e.datum[index][0] = make(rowData, e.numberOfColumns)
-		e.datum[index][0][0] = "Success Rate"
+		e.datum[index][e.numberOfDataRowsPerTable-1] = make(rowData, e.numberOfColumns)
+		e.datum[index][e.numberOfDataRowsPerTable-1][0] = "Success Rate"
 	}
 }
 
-// WriteToCSV gathers the all-time metrics and summarizes them into an overall report,
-// exporting it to the target folder.
-func (e *Exporter) WriteToCSV(ctx context.Context, opts *options.Options, startTime time.Time) {
-	// Determine export file path.
-	// File name format: <formatted_test_start_date_time>_<number_of_workers>_<number_of_jobs_per_worker>.csv
+// ExportFilePath determines the path of an exported report with a given extension.
+// File name format: <formatted_test_start_date_time>_<number_of_workers>_<number_of_jobs_per_worker>.<extension>
+func ExportFilePath(opts *options.Options, startTime time.Time, extension string) string {
 	datetime := fmt.Sprint(startTime.Local())
 	datetime = strings.ReplaceAll(datetime, ":", "-")
 	datetime = strings.ReplaceAll(datetime, " ", "_")
 	datetime = strings.ReplaceAll(datetime, "+", "")
-	filepath := opts.ExportFolderPath + "/" + datetime + "_" + fmt.Sprint(opts.WorkerNumber) + "_" + fmt.Sprint(opts.JobsPerWorker) + ".csv"
+	return opts.ExportFolderPath + "/" + datetime + "_" + fmt.Sprint(opts.WorkerNumber) + "_" + fmt.Sprint(opts.JobsPerWorker) + "." + extension
+}
+
+// WriteToCSV gathers the all-time metrics and summarizes them into an overall report,
+// exporting it to the target folder.
+func (e *Exporter) WriteToCSV(ctx context.Context, opts *options.Options, startTime time.Time) {
+	// Determine export file path.
+	filepath := ExportFilePath(opts, startTime, "csv")
 
 	// Prepare file to export report to.
+	export := e.Export
+	if opts.CSVLayout == options.LayoutLong {
+		export = e.ExportLong
+	}
+
 	klog.V(2).Infof("writing final performance testing report to %v", filepath)
-	if err := e.Export(ctx, filepath); err != nil {
+	if err := export(ctx, filepath); err != nil {
 		klog.Errorf("failed to export to file %v: %v", filepath, err.Error())
 		return
 	}
 	klog.V(2).Infof("successfully wrote final performance testing report to %v", filepath)
 }
 
-// Export exports the report to a file.
+// Export exports the report to a file in the stacked layout: one table per percent of the
+// statistics of verb `all`, followed by one table per verb and percent and one table per
+// kind of statistics and percent, with one column per latency.
 func (e *Exporter) Export(ctx context.Context, filepath string) error {
 	file, err := os.Create(filepath)
 	if err != nil {
@@ -117,51 +186,391 @@ func (e *Exporter) Export(ctx context.Context, filepath string) error {
 		_ = file.Close()
 	}()
 
-	writer := csv.NewWriter(file)
-	for tableID := 0; tableID < e.numberOfTables; tableID++ {
-		if err := writer.Write([]string{e.titles[tableID]}); err != nil {
-			return err
-		}
-		if err := writer.Write(e.header); err != nil {
+	// Tables of the final matrix.
+	for _, table := range e.MatrixTables() {
+		if err := printer.CSV.RenderTable(file, &table); err != nil {
 			return err
 		}
+	}
 
-		for _, quantile := range SortedQuantiles {
-			if err := writer.Write(e.datum[tableID][quantile]); err != nil {
+	// Per-verb tables, followed by client-side, fairness, server-side, etcd and resource
+	// usage tables, and by slowdown tables if a baseline test has been collected.
+	var tables [][]rowData
+	for _, verb := range constants.Verbs {
+		if verb == constants.ALL {
+			continue
+		}
+		verb := verb
+		tables = append(tables, e.valueTables(verb, func(cell CellStats) []namedValue {
+			return requestRows(cell, verb)
+		})...)
+	}
+	for _, statistics := range cellStatistics {
+		tables = append(tables, e.valueTables(statistics.kind, statistics.rowsOf)...)
+	}
+	if baseline := e.Baseline(); baseline != nil {
+		tables = append(tables, e.valueTables(SlowdownGroup, func(cell CellStats) []namedValue {
+			return slowdownRows(&cell, baseline)
+		})...)
+	}
+	writer := csv.NewWriter(file)
+	for _, table := range tables {
+		for _, row := range table {
+			if err := writer.Write(row); err != nil {
 				return err
 			}
 		}
+		writer.Flush()
+	}
 
-		if err := writer.Write(e.datum[tableID][0]); err != nil {
-			return err
+	return writer.Error()
+}
+
+// namedValue is a named value of the statistics of a cell (e.g. a quantile of a verb or a
+// server-side statistic), which is a row of the tables of exported reports.
+type namedValue struct {
+	name  string
+	value float64
+}
+
+// valueTables generates one table of named values (e.g. server-side statistics) per
+// percent, including the title row and the header row, with the values of every collected
+// cell. With several trials, every value is the mean across trials. No table is generated
+// for a percent without values.
+func (e *Exporter) valueTables(kind string, rowsOf func(cell CellStats) []namedValue) [][]rowData {
+	var tables [][]rowData
+	for _, percent := range e.percents {
+		var (
+			names  []string
+			values = make(map[string][]float64)
+			counts = make(map[string][]int)
+		)
+		for latencyIndex, latency := range e.latencies {
+			for _, cell := range e.Trials(MetricSetID{Latency: latency, Percent: percent}) {
+				for _, row := range rowsOf(cell) {
+					if _, ok := values[row.name]; !ok {
+						names = append(names, row.name)
+						values[row.name] = make([]float64, len(e.latencies))
+						counts[row.name] = make([]int, len(e.latencies))
+					}
+					values[row.name][latencyIndex] += row.value
+					counts[row.name][latencyIndex]++
+				}
+			}
 		}
-		writer.Flush()
+		if len(names) == 0 {
+			continue
+		}
+
+		header := append(rowData{"Metric"}, e.header[1:]...)
+		table := []rowData{{percent + "% sample " + kind}, header}
+		for _, name := range names {
+			row := make(rowData, e.numberOfColumns)
+			row[0] = name
+			for latencyIndex := range e.latencies {
+				if counts[name][latencyIndex] > 0 {
+					row[latencyIndex+1] = strconv.FormatFloat(values[name][latencyIndex]/float64(counts[name][latencyIndex]), 'f', -1, 64)
+				}
+			}
+			table = append(table, row)
+		}
+		tables = append(tables, table)
 	}
+	return tables
+}
+
+// LongLayoutHeader is the header row of CSV reports in the long layout.
+var LongLayoutHeader = []string{"latency", "percent", "trial", "group", "verb", "metric", "value"}
 
-	return nil
+// RequestsGroup is the group of the API request statistics of every verb in CSV reports
+// in the long layout.
+const RequestsGroup = "requests"
+
+// cellStatistics are the kinds of statistics of a latency-percent pair besides its API
+// requests, by the order they are exported.
+var cellStatistics = []struct {
+	kind   string
+	rowsOf func(cell CellStats) []namedValue
+}{
+	{kind: "client-side", rowsOf: func(cell CellStats) []namedValue {
+		if cell.Client == nil {
+			return nil
+		}
+		return clientRows(cell.Client)
+	}},
+	{kind: "fairness", rowsOf: func(cell CellStats) []namedValue {
+		if cell.Fairness == nil {
+			return nil
+		}
+		return fairnessRows(*cell.Fairness)
+	}},
+	{kind: "server-side", rowsOf: func(cell CellStats) []namedValue {
+		if cell.Server == nil {
+			return nil
+		}
+		return cell.Server.rows()
+	}},
+	{kind: "etcd", rowsOf: func(cell CellStats) []namedValue {
+		if cell.Etcd == nil {
+			return nil
+		}
+		return cell.Etcd.rows()
+	}},
+	{kind: "resources", rowsOf: func(cell CellStats) []namedValue {
+		if cell.Resources == nil {
+			return nil
+		}
+		return cell.Resources.rows()
+	}},
 }
 
-// Collect collects latency quantiles and success rate for a certain
-// latency-percent pair.
-func (e *Exporter) Collect(percentIndex, latencyIndex int) error {
-	set := MetricSetID{
-		Latency: e.latencies[latencyIndex],
-		Percent: e.percents[percentIndex],
+// requestRows flattens the statistics of the API requests of a verb into named values: the
+// reported quantiles by ascending order, the success rate and the throughput in requests
+// per second.
+func requestRows(cell CellStats, verb string) []namedValue {
+	stats, ok := cell.Verbs[verb]
+	if !ok {
+		return nil
+	}
+	var rows []namedValue
+	for _, quantile := range SortedQuantiles {
+		rows = append(rows, namedValue{name: QuantileName(quantile), value: stats.Latencies.Quantile(quantile)})
 	}
+	rows = append(rows, namedValue{name: "Success Rate", value: stats.SuccessRate()})
+	var throughput float64
+	if elapsed := cell.End.Sub(cell.Start).Seconds(); elapsed > 0 {
+		throughput = float64(stats.Total) / elapsed
+	}
+	return append(rows, namedValue{name: "Throughput", value: throughput})
+}
 
-	latencyMetric, err := collectLatencyMetric(constants.ALL, set)
+// ExportLong exports the report to a file in the long layout, i.e. tidy data: one row per
+// value of every collected trial, identified by the latency, the percent, the trial, the
+// group of statistics, the verb and the metric. Values are not aggregated across trials.
+// Baseline tests come first, and every other test is followed by its slowdown factors
+// relative to the baseline.
+func (e *Exporter) ExportLong(ctx context.Context, filepath string) error {
+	file, err := os.Create(filepath)
 	if err != nil {
 		return err
 	}
-	for _, quantile := range latencyMetric.Summary.Quantile {
-		e.datum[percentIndex][*quantile.Quantile][latencyIndex+1] = fmt.Sprintf("%.10f", *quantile.Value)
+	defer file.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(LongLayoutHeader); err != nil {
+		return err
+	}
+	baseline := e.Baseline()
+	for index, cell := range append(append([]CellStats{}, e.baselines...), e.cells...) {
+		write := func(group, verb string, rows []namedValue) error {
+			for _, row := range rows {
+				if err := writer.Write([]string{
+					cell.Set.Latency,
+					cell.Set.Percent,
+					strconv.Itoa(cell.Set.Trial),
+					group,
+					verb,
+					row.name,
+					strconv.FormatFloat(row.value, 'f', -1, 64),
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, verb := range constants.Verbs {
+			if err := write(RequestsGroup, verb, requestRows(cell, verb)); err != nil {
+				return err
+			}
+		}
+		for _, statistics := range cellStatistics {
+			if err := write(statistics.kind, "", statistics.rowsOf(cell)); err != nil {
+				return err
+			}
+		}
+		if baseline != nil && index >= len(e.baselines) {
+			for _, verb := range constants.Verbs {
+				if err := write(SlowdownGroup, verb, verbSlowdownRows(&cell, baseline, verb)); err != nil {
+					return err
+				}
+			}
+		}
+		writer.Flush()
 	}
 
-	_, _, successRate, err := collectSuccessRateMetrics(constants.ALL, set)
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteHistograms exports the latency histograms of every collected latency-percent pair
+// to the target folder, so that they can be merged or compared afterwards.
+func (e *Exporter) WriteHistograms(ctx context.Context, opts *options.Options, startTime time.Time) {
+	filepath := ExportFilePath(opts, startTime, "histograms.json")
+
+	klog.V(2).Infof("writing latency histograms to %v", filepath)
+	if err := e.ExportHistograms(ctx, filepath); err != nil {
+		klog.Errorf("failed to export to file %v: %v", filepath, err.Error())
+		return
+	}
+	klog.V(2).Infof("successfully wrote latency histograms to %v", filepath)
+}
+
+// ExportHistograms exports the statistics of every collected latency-percent pair,
+// including their latency histograms, to a JSON file.
+func (e *Exporter) ExportHistograms(ctx context.Context, filepath string) error {
+	file, err := os.Create(filepath)
 	if err != nil {
 		return err
 	}
-	e.datum[percentIndex][0][latencyIndex+1] = fmt.Sprintf("%.2f", successRate) + "%"
+	defer file.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e.cells)
+}
+
+// WriteTimeSeries exports the time series of a test to the target folder, in the format
+// chosen by `opts.TimeSeriesFormat`. The time series is stopped once written, so that the
+// time series of finished tests are not kept in memory.
+func (e *Exporter) WriteTimeSeries(ctx context.Context, opts *options.Options, startTime time.Time, set MetricSetID) {
+	ts := GetTimeSeries(set)
+	if ts == nil {
+		return
+	}
+	defer StopTimeSeries(set)
+
+	name := set.Latency + "_" + set.Percent
+	if set.Trial > 0 {
+		name += fmt.Sprintf("_trial%v", set.Trial)
+	}
+	filepath := ExportFilePath(opts, startTime, name+".timeseries."+opts.TimeSeriesFormat)
+	export := ts.ExportCSV
+	if opts.TimeSeriesFormat == options.FormatJSON {
+		export = ts.ExportJSON
+	}
+
+	klog.V(2).Infof("writing time series of test (%v) to %v", set, filepath)
+	if err := export(ctx, filepath); err != nil {
+		klog.Errorf("failed to export to file %v: %v", filepath, err.Error())
+		return
+	}
+	klog.V(2).Infof("successfully wrote time series of test (%v) to %v", set, filepath)
+}
+
+// Cells returns the statistics of every collected latency-percent pair.
+func (e *Exporter) Cells() []CellStats {
+	return e.cells
+}
 
-	return nil
+// Collect collects latency quantiles and success rate for a certain trial of a
+// latency-percent pair, along with its server-side and etcd statistics if they have been
+// scraped and the resource usage of the load generator if it has been monitored, returning
+// the collected statistics. The trial is 0 when every latency-percent pair is tested only
+// once.
+func (e *Exporter) Collect(percentIndex, latencyIndex, trial int, start, end time.Time, server *ServerStats, etcd *EtcdStats, usage *ResourceUsage) (CellStats, error) {
+	set := MetricSetID{
+		Latency: e.latencies[latencyIndex],
+		Percent: e.percents[percentIndex],
+		Trial:   trial,
+	}
+
+	cell := collectCellStats(set, start, end, server, etcd, usage)
+	e.cells = append(e.cells, cell)
+
+	if e.trials <= 1 {
+		all := cell.Verbs[constants.ALL]
+		for row, quantile := range SortedQuantiles {
+			e.datum[percentIndex][row][latencyIndex+1] = fmt.Sprintf("%.10f", all.Latencies.Quantile(quantile))
+		}
+		e.datum[percentIndex][e.numberOfDataRowsPerTable-1][latencyIndex+1] = fmt.Sprintf("%.2f", all.SuccessRate()) + "%"
+		return cell, nil
+	}
+
+	// Summarize every collected trial of the latency-percent pair.
+	trials := e.Trials(set)
+	summaries := make([]TrialSummary, 0, e.numberOfDataRowsPerTable)
+	for _, quantile := range SortedQuantiles {
+		summaries = append(summaries, summarizeQuantile(trials, constants.ALL, quantile))
+	}
+	summaries = append(summaries, summarizeSuccessRate(trials, constants.ALL))
+	for row, summary := range summaries {
+		format := "%.10f"
+		if row == e.numberOfDataRowsPerTable-1 {
+			format = "%.2f%%"
+		}
+		for statistic, value := range []float64{summary.Mean, summary.StdDev, summary.CILow, summary.CIHigh} {
+			e.datum[percentIndex*e.numberOfTablesPerPercent+statistic][row][latencyIndex+1] = fmt.Sprintf(format, value)
+		}
+	}
+
+	return cell, nil
+}
+
+// collectCellStats collects the statistics of a test.
+func collectCellStats(set MetricSetID, start, end time.Time, server *ServerStats, etcd *EtcdStats, usage *ResourceUsage) CellStats {
+	cell := CellStats{
+		Set:       set,
+		Start:     start,
+		End:       end,
+		Verbs:     make(map[string]*RequestStats),
+		Client:    collectClientStats(set),
+		Workers:   collectWorkerStats(set),
+		Server:    server,
+		Etcd:      etcd,
+		Resources: usage,
+	}
+	if len(cell.Workers) > 0 {
+		fairness := ComputeFairness(cell.Workers)
+		cell.Fairness = &fairness
+	}
+	for _, verb := range constants.Verbs {
+		cell.Verbs[verb] = collectRequestStats(verb, set)
+	}
+	return cell
+}
+
+// Trials returns the statistics of every collected trial of the latency-percent pair of
+// a metric set, by the order of collection.
+func (e *Exporter) Trials(set MetricSetID) []CellStats {
+	var trials []CellStats
+	for _, cell := range e.cells {
+		if cell.Set.Cell() == set.Cell() {
+			trials = append(trials, cell)
+		}
+	}
+	return trials
+}
+
+// Merged returns the statistics of the API requests of a verb for every latency-percent
+// pair, merging the requests of every trial. The first index is the percent index, the
+// second one is the latency index; pairs that have not been collected are nil.
+func (e *Exporter) Merged(verb string) [][]*RequestStats {
+	merged := make([][]*RequestStats, len(e.percents))
+	for percentIndex, percent := range e.percents {
+		merged[percentIndex] = make([]*RequestStats, len(e.latencies))
+		for latencyIndex, latency := range e.latencies {
+			for _, cell := range e.Trials(MetricSetID{Latency: latency, Percent: percent}) {
+				stats, ok := cell.Verbs[verb]
+				if !ok {
+					continue
+				}
+				if merged[percentIndex][latencyIndex] == nil {
+					merged[percentIndex][latencyIndex] = stats.Copy()
+				} else if err := merged[percentIndex][latencyIndex].Merge(stats); err != nil {
+					klog.Errorf("failed to merge trials of %v: %v", cell.Set, err.Error())
+				}
+			}
+		}
+	}
+	return merged
 }
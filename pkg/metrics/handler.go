@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler returns an HTTP handler exposing every metric in the registry, including
+// Go runtime, process and client-go metrics, in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		Registry: registry,
+	})
+}
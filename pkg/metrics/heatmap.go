@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+// Heatmap generates the heatmap of a latency quantile of a verb across every latency-percent
+// pair, with one row per percent and one column per latency. The trials of a pair are merged,
+// pairs that have not been tested are left blank.
+func (e *Exporter) Heatmap(verb string, quantile float64) *printer.Heatmap {
+	merged := e.Merged(verb)
+	values := make([][]float64, len(e.percents))
+	for percentIndex := range e.percents {
+		values[percentIndex] = make([]float64, len(e.latencies))
+		for latencyIndex := range e.latencies {
+			values[percentIndex][latencyIndex] = math.NaN()
+			if stats := merged[percentIndex][latencyIndex]; stats != nil && stats.Latencies.Count() > 0 {
+				values[percentIndex][latencyIndex] = stats.Latencies.Quantile(quantile)
+			}
+		}
+	}
+
+	rowLabels := make([]string, 0, len(e.percents))
+	for _, percent := range e.percents {
+		rowLabels = append(rowLabels, percent+"%")
+	}
+	title := fmt.Sprintf("%v Latency(s) of %v", QuantileName(quantile), strings.ToUpper(verb))
+	heatmap := printer.NewHeatmap(printer.LineAlignCenter(title), "Percent", rowLabels, e.header[1:], values)
+	heatmap.SetFormat("%.5f")
+	if lowest, highest := heatmap.Range(); !math.IsNaN(lowest) {
+		heatmap.SetTitle(printer.LineAlignCenter(fmt.Sprintf("%v, from %.5f to %.5f", title, lowest, highest)))
+	}
+	return heatmap
+}
+
+// PrintHeatmap prints the heatmap of a latency quantile of a verb across every latency-percent
+// pair to stdout, graded by color when stdout supports it and sheets are drawn in boxes, since
+// other renderers (e.g. markdown, CSV) drop colors.
+func (e *Exporter) PrintHeatmap(verb string, quantile float64) {
+	heatmap := e.Heatmap(verb, quantile)
+	_, boxed := printer.Default.(*printer.BoxRenderer)
+	heatmap.SetColored(boxed && printer.ColorSupported(os.Stdout))
+
+	printer.PrintEmptyLine()
+	heatmap.Print()
+	printer.PrintEmptyLine()
+}
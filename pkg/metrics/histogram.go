@@ -0,0 +1,345 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	// DefaultRelativeAccuracy is the default relative accuracy of latency histograms,
+	// every reported quantile is within 1% of the exact value.
+	DefaultRelativeAccuracy = 0.01
+
+	// minIndexableValue is the smallest value (in seconds) that gets its own bucket,
+	// anything smaller is counted as zero.
+	minIndexableValue = 1e-9
+)
+
+// Histogram is a log-bucketed latency recorder. Every value is mapped to a bucket whose
+// boundaries grow geometrically, so that the value a bucket reports is within the configured
+// relative accuracy of every value it holds. Unlike Prometheus Summary metrics, it never
+// expires, supports arbitrary quantiles, and histograms with the same accuracy can be merged
+// (e.g. across workers, trials, runs or processes).
+type Histogram struct {
+	lock sync.RWMutex
+
+	// relativeAccuracy is the maximum relative error of any reported quantile.
+	relativeAccuracy float64
+	// gamma is the ratio between the upper and lower boundaries of a bucket.
+	gamma float64
+	// logGamma is the natural logarithm of `gamma`, cached for indexing.
+	logGamma float64
+
+	// buckets maps bucket indexes to the number of values in the bucket.
+	buckets map[int]uint64
+	// zeroCount is the number of values smaller than `minIndexableValue`.
+	zeroCount uint64
+	// count is the total number of recorded values.
+	count uint64
+	// sum is the sum of all recorded values.
+	sum float64
+	// min is the exact minimum of all recorded values.
+	min float64
+	// max is the exact maximum of all recorded values.
+	max float64
+}
+
+// NewHistogram instantiates a new empty histogram with a given relative accuracy,
+// which must be in range (0, 1).
+func NewHistogram(relativeAccuracy float64) (*Histogram, error) {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		return nil, fmt.Errorf("%v is not a valid relative accuracy (should be in range (0, 1))", relativeAccuracy)
+	}
+
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &Histogram{
+		relativeAccuracy: relativeAccuracy,
+		gamma:            gamma,
+		logGamma:         math.Log(gamma),
+		buckets:          make(map[int]uint64),
+	}, nil
+}
+
+// RelativeAccuracy returns the relative accuracy of the histogram.
+func (h *Histogram) RelativeAccuracy() float64 {
+	return h.relativeAccuracy
+}
+
+// Observe records a value (in seconds) in the histogram. Negative, NaN and infinite values
+// are ignored.
+func (h *Histogram) Observe(value float64) {
+	if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if value < minIndexableValue {
+		h.zeroCount++
+	} else {
+		h.buckets[h.index(value)]++
+	}
+
+	if h.count == 0 || value < h.min {
+		h.min = value
+	}
+	if h.count == 0 || value > h.max {
+		h.max = value
+	}
+	h.count++
+	h.sum += value
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() uint64 {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.count
+}
+
+// Sum returns the sum of all recorded values.
+func (h *Histogram) Sum() float64 {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.sum
+}
+
+// Mean returns the exact mean of all recorded values, or 0 if the histogram is empty.
+func (h *Histogram) Mean() float64 {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / float64(h.count)
+}
+
+// Min returns the exact minimum of all recorded values, or 0 if the histogram is empty.
+func (h *Histogram) Min() float64 {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.min
+}
+
+// Max returns the exact maximum of all recorded values, or 0 if the histogram is empty.
+func (h *Histogram) Max() float64 {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.max
+}
+
+// Quantile returns the estimated value of quantile q (in range [0, 1]). Quantile 0 and 1
+// are the exact minimum and maximum, every other quantile is within the relative accuracy
+// of the histogram. It returns 0 if the histogram is empty.
+func (h *Histogram) Quantile(q float64) float64 {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if h.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.min
+	}
+	if q >= 1 {
+		return h.max
+	}
+
+	rank := uint64(q * float64(h.count-1))
+	if rank < h.zeroCount {
+		return 0
+	}
+
+	seen := h.zeroCount
+	for _, index := range h.sortedIndexes() {
+		seen += h.buckets[index]
+		if seen > rank {
+			return h.clamp(h.value(index))
+		}
+	}
+	return h.max
+}
+
+// Bucket is a contiguous range of recorded values.
+type Bucket struct {
+	// LowerBound is the (exclusive) lower bound of the bucket in seconds.
+	LowerBound float64
+	// UpperBound is the (inclusive) upper bound of the bucket in seconds.
+	UpperBound float64
+	// Value is the value reported for every value in the bucket in seconds.
+	Value float64
+	// Count is the number of values in the bucket.
+	Count uint64
+}
+
+// Buckets returns the non-empty buckets of the histogram by ascending order, values
+// smaller than the smallest indexable value are reported in a bucket starting at 0.
+func (h *Histogram) Buckets() []Bucket {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	var buckets []Bucket
+	if h.zeroCount > 0 {
+		buckets = append(buckets, Bucket{LowerBound: 0, UpperBound: minIndexableValue, Value: 0, Count: h.zeroCount})
+	}
+	for _, index := range h.sortedIndexes() {
+		buckets = append(buckets, Bucket{
+			LowerBound: math.Pow(h.gamma, float64(index-1)),
+			UpperBound: math.Pow(h.gamma, float64(index)),
+			Value:      h.clamp(h.value(index)),
+			Count:      h.buckets[index],
+		})
+	}
+	return buckets
+}
+
+// Merge adds all values recorded in another histogram into this one. Both histograms
+// must have the same relative accuracy. Merging a histogram into itself doubles it.
+func (h *Histogram) Merge(other *Histogram) error {
+	if other == nil {
+		return nil
+	}
+	if h.relativeAccuracy != other.relativeAccuracy {
+		return fmt.Errorf("cannot merge histograms with different relative accuracies (%v and %v)", h.relativeAccuracy, other.relativeAccuracy)
+	}
+
+	// Snapshot the other histogram under its own lock before locking this one, so that
+	// concurrent merges in both directions cannot deadlock.
+	other.lock.RLock()
+	snapshot := Histogram{
+		buckets:   make(map[int]uint64, len(other.buckets)),
+		zeroCount: other.zeroCount,
+		count:     other.count,
+		sum:       other.sum,
+		min:       other.min,
+		max:       other.max,
+	}
+	for index, count := range other.buckets {
+		snapshot.buckets[index] = count
+	}
+	other.lock.RUnlock()
+	if snapshot.count == 0 {
+		return nil
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for index, count := range snapshot.buckets {
+		h.buckets[index] += count
+	}
+	if h.count == 0 || snapshot.min < h.min {
+		h.min = snapshot.min
+	}
+	if h.count == 0 || snapshot.max > h.max {
+		h.max = snapshot.max
+	}
+	h.zeroCount += snapshot.zeroCount
+	h.count += snapshot.count
+	h.sum += snapshot.sum
+	return nil
+}
+
+// Copy returns a deep copy of the histogram.
+func (h *Histogram) Copy() *Histogram {
+	copied, _ := NewHistogram(h.relativeAccuracy)
+	_ = copied.Merge(h)
+	return copied
+}
+
+// histogramJSON is the serialized form of a histogram.
+type histogramJSON struct {
+	RelativeAccuracy float64           `json:"relativeAccuracy"`
+	Count            uint64            `json:"count"`
+	Sum              float64           `json:"sum"`
+	Min              float64           `json:"min"`
+	Max              float64           `json:"max"`
+	ZeroCount        uint64            `json:"zeroCount"`
+	Buckets          map[string]uint64 `json:"buckets"`
+}
+
+// MarshalJSON serializes the histogram, keeping every bucket so that it can be merged
+// and queried again after deserialization.
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	buckets := make(map[string]uint64, len(h.buckets))
+	for index, count := range h.buckets {
+		buckets[strconv.Itoa(index)] = count
+	}
+	return json.Marshal(histogramJSON{
+		RelativeAccuracy: h.relativeAccuracy,
+		Count:            h.count,
+		Sum:              h.sum,
+		Min:              h.min,
+		Max:              h.max,
+		ZeroCount:        h.zeroCount,
+		Buckets:          buckets,
+	})
+}
+
+// UnmarshalJSON deserializes a histogram serialized by `MarshalJSON`.
+func (h *Histogram) UnmarshalJSON(data []byte) error {
+	var raw histogramJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	decoded, err := NewHistogram(raw.RelativeAccuracy)
+	if err != nil {
+		return err
+	}
+	for key, count := range raw.Buckets {
+		index, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("%v is not a valid histogram bucket index: %w", key, err)
+		}
+		decoded.buckets[index] = count
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.relativeAccuracy = decoded.relativeAccuracy
+	h.gamma = decoded.gamma
+	h.logGamma = decoded.logGamma
+	h.buckets = decoded.buckets
+	h.zeroCount = raw.ZeroCount
+	h.count = raw.Count
+	h.sum = raw.Sum
+	h.min = raw.Min
+	h.max = raw.Max
+	return nil
+}
+
+// index returns the index of the bucket a value belongs to. Bucket i holds values
+// in range (gamma^(i-1), gamma^i].
+func (h *Histogram) index(value float64) int {
+	return int(math.Ceil(math.Log(value) / h.logGamma))
+}
+
+// value returns the representative value of a bucket, which is within the relative
+// accuracy of both of its boundaries.
+func (h *Histogram) value(index int) float64 {
+	return 2 * math.Pow(h.gamma, float64(index)) / (h.gamma + 1)
+}
+
+// clamp restricts an estimated value to the range of the recorded values.
+func (h *Histogram) clamp(value float64) float64 {
+	return math.Max(h.min, math.Min(h.max, value))
+}
+
+// sortedIndexes returns the indexes of all non-empty buckets by ascending order.
+// The caller must hold the lock.
+func (h *Histogram) sortedIndexes() []int {
+	indexes := make([]int, 0, len(h.buckets))
+	for index := range h.buckets {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newFilledHistogram instantiates a histogram of the default accuracy holding the given values.
+func newFilledHistogram(t *testing.T, values ...float64) *Histogram {
+	histogram, err := NewHistogram(DefaultRelativeAccuracy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range values {
+		histogram.Observe(value)
+	}
+	return histogram
+}
+
+// randomLatencies returns n log-normally distributed latencies around 10ms.
+func randomLatencies(rng *rand.Rand, n int) []float64 {
+	values := make([]float64, n)
+	for index := range values {
+		values[index] = 0.01 * math.Exp(rng.NormFloat64())
+	}
+	return values
+}
+
+func TestHistogramQuantileAccuracy(t *testing.T) {
+	values := randomLatencies(rand.New(rand.NewSource(1)), 10000)
+	histogram := newFilledHistogram(t, values...)
+	sort.Float64s(values)
+
+	for _, quantile := range []float64{0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		want := values[int(quantile*float64(len(values)-1))]
+		got := histogram.Quantile(quantile)
+		if math.Abs(got-want) > want*DefaultRelativeAccuracy {
+			t.Errorf("got quantile %v %v, want within %v of %v", quantile, got, DefaultRelativeAccuracy, want)
+		}
+	}
+	if got, want := histogram.Quantile(0), values[0]; got != want {
+		t.Errorf("got quantile 0 %v, want the exact minimum %v", got, want)
+	}
+	if got, want := histogram.Quantile(1), values[len(values)-1]; got != want {
+		t.Errorf("got quantile 1 %v, want the exact maximum %v", got, want)
+	}
+}
+
+func TestHistogramMergeAssociativity(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	a, b, c := randomLatencies(rng, 100), randomLatencies(rng, 200), randomLatencies(rng, 300)
+
+	// (a + b) + c
+	left := newFilledHistogram(t, a...)
+	if err := left.Merge(newFilledHistogram(t, b...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := left.Merge(newFilledHistogram(t, c...)); err != nil {
+		t.Fatal(err)
+	}
+	// a + (b + c)
+	right := newFilledHistogram(t, b...)
+	if err := right.Merge(newFilledHistogram(t, c...)); err != nil {
+		t.Fatal(err)
+	}
+	merged := newFilledHistogram(t, a...)
+	if err := merged.Merge(right); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(left.Buckets(), merged.Buckets()) || left.Count() != merged.Count() || left.Min() != merged.Min() || left.Max() != merged.Max() {
+		t.Errorf("got (a+b)+c %v, want a+(b+c) %v", left.Buckets(), merged.Buckets())
+	}
+	if math.Abs(left.Sum()-merged.Sum()) > 1e-9 {
+		t.Errorf("got sum of (a+b)+c %v, want sum of a+(b+c) %v", left.Sum(), merged.Sum())
+	}
+
+	other, err := NewHistogram(0.05)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := left.Merge(other); err == nil {
+		t.Error("got no error merging histograms of different accuracies, want one")
+	}
+}
+
+func TestHistogramMergeItself(t *testing.T) {
+	histogram := newFilledHistogram(t, 0.001, 0.002, 0.003)
+	if err := histogram.Merge(histogram); err != nil {
+		t.Fatal(err)
+	}
+	if got := histogram.Count(); got != 6 {
+		t.Errorf("got count %v, want 6", got)
+	}
+	if got := histogram.Sum(); math.Abs(got-0.012) > 1e-12 {
+		t.Errorf("got sum %v, want 0.012", got)
+	}
+}
+
+func TestHistogramJSONRoundTrip(t *testing.T) {
+	histogram := newFilledHistogram(t, append(randomLatencies(rand.New(rand.NewSource(1)), 100), 0)...)
+	data, err := json.Marshal(histogram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Histogram{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := decoded.RelativeAccuracy(), histogram.RelativeAccuracy(); got != want {
+		t.Errorf("got relative accuracy %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(decoded.Buckets(), histogram.Buckets()) {
+		t.Errorf("got buckets %v, want %v", decoded.Buckets(), histogram.Buckets())
+	}
+	for _, quantile := range []float64{0, 0.5, 0.99, 1} {
+		if got, want := decoded.Quantile(quantile), histogram.Quantile(quantile); got != want {
+			t.Errorf("got quantile %v %v, want %v", quantile, got, want)
+		}
+	}
+	if decoded.Count() != histogram.Count() || decoded.Sum() != histogram.Sum() {
+		t.Errorf("got count %v and sum %v, want %v and %v", decoded.Count(), decoded.Sum(), histogram.Count(), histogram.Sum())
+	}
+	// The decoded histogram can be merged with histograms of the same accuracy.
+	if err := decoded.Merge(histogram); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHistogramDropsInvalidValues(t *testing.T) {
+	histogram := newFilledHistogram(t, math.NaN(), math.Inf(1), math.Inf(-1), -0.001, 0.001)
+	if got := histogram.Count(); got != 1 {
+		t.Errorf("got count %v, want 1", got)
+	}
+	if got := histogram.Min(); got != 0.001 {
+		t.Errorf("got minimum %v, want 0.001", got)
+	}
+	if got := histogram.Sum(); got != 0.001 {
+		t.Errorf("got sum %v, want 0.001", got)
+	}
+}
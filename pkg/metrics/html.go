@@ -0,0 +1,396 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/options"
+)
+
+const (
+	// chartWidth is the width of line charts in pixels.
+	chartWidth = 640
+	// chartHeight is the height of line charts in pixels.
+	chartHeight = 360
+	// chartMargin is the margin around the plot area of line charts in pixels, which holds
+	// the axes labels.
+	chartMargin = 56
+	// heatmapCellWidth is the width of a heatmap cell in pixels.
+	heatmapCellWidth = 72
+	// heatmapCellHeight is the height of a heatmap cell in pixels.
+	heatmapCellHeight = 32
+)
+
+// chartColors is the palette of chart series, reused in order when there are more series.
+var chartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// htmlTemplate is the template of the HTML report. Every chart is inline SVG, so that the
+// report is a single file that can be viewed offline.
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Performance Testing Report {{.Run.ID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1, h2 { font-weight: normal; }
+table.metadata td { padding: 2px 12px 2px 0; }
+table.metadata td:first-child { color: #666; }
+.charts { display: flex; flex-wrap: wrap; gap: 16px; }
+svg { background: #fff; border: 1px solid #ddd; }
+svg text { font-size: 12px; fill: #222; }
+table.sensitivity { border-collapse: collapse; }
+table.sensitivity th, table.sensitivity td { border: 1px solid #ddd; padding: 2px 8px; text-align: right; }
+</style>
+</head>
+<body>
+<h1>Performance Testing Report</h1>
+<table class="metadata">
+{{- range .Metadata}}
+<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{- end}}
+</table>
+<h2>Latency Quantiles against Injected Latency</h2>
+<div class="charts">
+{{- range .LatencyCharts}}
+{{.}}
+{{- end}}
+</div>
+<h2>{{.HeatmapTitle}}</h2>
+<div class="charts">
+{{.Heatmap}}
+</div>
+<h2>Success Rate against Injected Latency</h2>
+<div class="charts">
+{{.SuccessRateChart}}
+</div>
+{{- if .SensitivityRows}}
+<h2>Latency Sensitivity to Injected Latency</h2>
+<table class="sensitivity">
+<tr><th>Verb</th><th>Percent</th><th>Quantile</th><th>Slope (ms/ms)</th><th>Intercept (ms)</th><th>R&sup2;</th><th>Knee</th></tr>
+{{- range .SensitivityRows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// htmlReport is the content of the HTML report.
+type htmlReport struct {
+	// Run is the metadata of the run.
+	Run RunMetadata
+	// Metadata are the name-value pairs shown at the top of the report.
+	Metadata [][2]string
+	// LatencyCharts are the charts of latency quantiles against injected latency, one per percent.
+	LatencyCharts []template.HTML
+	// HeatmapTitle is the title of the heatmap.
+	HeatmapTitle string
+	// Heatmap is the heatmap of a latency quantile over the latency-percent matrix.
+	Heatmap template.HTML
+	// SuccessRateChart is the chart of success rates against injected latency, one series per percent.
+	SuccessRateChart template.HTML
+	// SensitivityRows are the rows of the table of the fits of latency quantiles against
+	// injected latency.
+	SensitivityRows [][]string
+}
+
+// chartSeries is a named series of values of a line chart, NaN values are not plotted.
+type chartSeries struct {
+	name   string
+	values []float64
+}
+
+// WriteHTML exports the HTML report of the run to the target folder.
+func (e *Exporter) WriteHTML(ctx context.Context, opts *options.Options, startTime, endTime time.Time) {
+	filepath := ExportFilePath(opts, startTime, "html")
+
+	klog.V(2).Infof("writing html report to %v", filepath)
+	if err := e.ExportHTML(ctx, opts, startTime, endTime, filepath); err != nil {
+		klog.Errorf("failed to export to file %v: %v", filepath, err.Error())
+		return
+	}
+	klog.V(2).Infof("successfully wrote html report to %v", filepath)
+}
+
+// ExportHTML exports a self-contained HTML report to a file: the run metadata, charts of
+// the latency quantiles of verb `all` against injected latency per percent, the heatmap of
+// the latency quantile of the verb chosen by `opts.HeatmapQuantile` and `opts.HeatmapVerb`
+// over the latency-percent matrix, the chart of success rates and the fits of latency
+// quantiles against injected latency. Trials are merged.
+func (e *Exporter) ExportHTML(ctx context.Context, opts *options.Options, startTime, endTime time.Time, filepath string) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+
+	result := e.Result(opts, startTime, endTime)
+	run, runOptions := result.Run, result.Options
+	percents := make([]string, 0, len(runOptions.Percents))
+	for _, percent := range runOptions.Percents {
+		percents = append(percents, fmt.Sprint(percent))
+	}
+	report := htmlReport{
+		Run: run,
+		Metadata: [][2]string{
+			{"Run ID", run.ID},
+			{"Start time", run.Start.Local().String()},
+			{"End time", run.End.Local().String()},
+			{"Duration", run.End.Sub(run.Start).Round(time.Second).String()},
+			{"Host", run.Hostname},
+			{"Workers", fmt.Sprint(runOptions.Workers)},
+			{"Jobs per worker", fmt.Sprint(runOptions.JobsPerWorker)},
+			{"Trials", fmt.Sprint(runOptions.Trials)},
+			{"Latencies", strings.Join(runOptions.Latencies, ", ")},
+			{"Percents", strings.Join(percents, ", ")},
+		},
+		HeatmapTitle: fmt.Sprintf("%v Latency (ms) of %v over Injected Latency and Percent", QuantileName(opts.HeatmapQuantile), strings.ToUpper(opts.HeatmapVerb)),
+	}
+
+	merged, heatmapMerged := e.Merged(constants.ALL), e.Merged(opts.HeatmapVerb)
+	xValues, xLabels := e.latencyAxis()
+	successRates := make([]chartSeries, 0, len(e.percents))
+	heatmap := make([][]float64, len(e.percents))
+	for percentIndex, percent := range e.percents {
+		series := make([]chartSeries, 0, len(SortedQuantiles))
+		for _, quantile := range SortedQuantiles {
+			series = append(series, chartSeries{
+				name: QuantileName(quantile),
+				values: statsValues(merged[percentIndex], func(stats *RequestStats) float64 {
+					return stats.Latencies.Quantile(quantile) * 1000
+				}),
+			})
+		}
+		report.LatencyCharts = append(report.LatencyCharts, lineChart(percent+"% sample", "Injected latency", "Latency (ms)", xValues, xLabels, series))
+
+		successRates = append(successRates, chartSeries{
+			name: percent + "%",
+			values: statsValues(merged[percentIndex], func(stats *RequestStats) float64 {
+				return stats.SuccessRate()
+			}),
+		})
+		heatmap[percentIndex] = statsValues(heatmapMerged[percentIndex], func(stats *RequestStats) float64 {
+			return stats.Latencies.Quantile(opts.HeatmapQuantile) * 1000
+		})
+	}
+	report.Heatmap = heatmapChart(xLabels, e.percentLabels(), heatmap)
+	report.SuccessRateChart = lineChart("Success rate", "Injected latency", "Success rate (%)", xValues, xLabels, successRates)
+	for _, sensitivity := range result.Sensitivity {
+		knee := "-"
+		if len(sensitivity.Knee) > 0 {
+			knee = fmt.Sprintf("%v (%.3f → %.3f)", sensitivity.Knee, sensitivity.SlopeBeforeKnee, sensitivity.SlopeAfterKnee)
+		}
+		report.SensitivityRows = append(report.SensitivityRows, []string{
+			strings.ToUpper(sensitivity.Verb),
+			sensitivity.Percent + "%",
+			QuantileName(sensitivity.Quantile),
+			fmt.Sprintf("%.3f", sensitivity.Slope),
+			fmt.Sprintf("%.3f", sensitivity.Intercept*1000),
+			fmt.Sprintf("%.3f", sensitivity.RSquared),
+			knee,
+		})
+	}
+
+	return htmlTemplate.Execute(file, report)
+}
+
+// latencyAxis returns the positions and the labels of the latencies on the x axis of
+// charts. Positions are the injected latencies in milliseconds, or the indexes of the
+// latencies if any of them is not a duration.
+func (e *Exporter) latencyAxis() ([]float64, []string) {
+	values := make([]float64, len(e.latencies))
+	for index, latency := range e.latencies {
+		duration, err := time.ParseDuration(latency)
+		if err != nil {
+			for index := range values {
+				values[index] = float64(index)
+			}
+			break
+		}
+		values[index] = float64(duration) / float64(time.Millisecond)
+	}
+	return values, e.latencies
+}
+
+// percentLabels returns the labels of the percents.
+func (e *Exporter) percentLabels() []string {
+	labels := make([]string, 0, len(e.percents))
+	for _, percent := range e.percents {
+		labels = append(labels, percent+"%")
+	}
+	return labels
+}
+
+// statsValues maps statistics to values, nil statistics being NaN.
+func statsValues(stats []*RequestStats, value func(stats *RequestStats) float64) []float64 {
+	values := make([]float64, len(stats))
+	for index, item := range stats {
+		values[index] = math.NaN()
+		if item != nil {
+			values[index] = value(item)
+		}
+	}
+	return values
+}
+
+// formatChartValue formats a value shown on a chart.
+func formatChartValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', 4, 64)
+}
+
+// lineChart renders a line chart as inline SVG, with one line per series and one point per
+// x value. The y axis starts at 0.
+func lineChart(title, xTitle, yTitle string, xValues []float64, xLabels []string, series []chartSeries) template.HTML {
+	var (
+		builder    strings.Builder
+		plotWidth  = float64(chartWidth - 2*chartMargin)
+		plotHeight = float64(chartHeight - 2*chartMargin)
+		legendX    = float64(chartWidth - chartMargin + 8)
+	)
+	xMin, xMax := math.Inf(1), math.Inf(-1)
+	for _, x := range xValues {
+		xMin, xMax = math.Min(xMin, x), math.Max(xMax, x)
+	}
+	yMax := 0.0
+	for _, s := range series {
+		for _, y := range s.values {
+			if !math.IsNaN(y) {
+				yMax = math.Max(yMax, y)
+			}
+		}
+	}
+	if yMax == 0 {
+		yMax = 1
+	}
+	yMax *= 1.1
+	scaleX := func(x float64) float64 {
+		if xMax == xMin {
+			return chartMargin + plotWidth/2
+		}
+		return chartMargin + (x-xMin)/(xMax-xMin)*plotWidth
+	}
+	scaleY := func(y float64) float64 {
+		return chartMargin + plotHeight - y/yMax*plotHeight
+	}
+
+	fmt.Fprintf(&builder, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v">`, chartWidth+96, chartHeight, chartWidth+96, chartHeight)
+	fmt.Fprintf(&builder, `<text x="%v" y="24" text-anchor="middle" style="font-size:14px">%v</text>`, chartWidth/2, html.EscapeString(title))
+
+	// Axes, grid lines and ticks.
+	fmt.Fprintf(&builder, `<line x1="%v" y1="%v" x2="%v" y2="%v" stroke="#222"/>`, chartMargin, chartMargin+plotHeight, chartMargin+plotWidth, chartMargin+plotHeight)
+	fmt.Fprintf(&builder, `<line x1="%v" y1="%v" x2="%v" y2="%v" stroke="#222"/>`, chartMargin, chartMargin, chartMargin, chartMargin+plotHeight)
+	for tick := 0; tick <= 5; tick++ {
+		y := yMax * float64(tick) / 5
+		fmt.Fprintf(&builder, `<line x1="%v" y1="%.1f" x2="%v" y2="%.1f" stroke="#eee"/>`, chartMargin+1, scaleY(y), chartMargin+plotWidth, scaleY(y))
+		fmt.Fprintf(&builder, `<text x="%v" y="%.1f" text-anchor="end" dominant-baseline="middle">%v</text>`, chartMargin-6, scaleY(y), formatChartValue(y))
+	}
+	for index, x := range xValues {
+		fmt.Fprintf(&builder, `<text x="%.1f" y="%v" text-anchor="middle">%v</text>`, scaleX(x), chartMargin+plotHeight+18, html.EscapeString(xLabels[index]))
+	}
+	fmt.Fprintf(&builder, `<text x="%v" y="%v" text-anchor="middle">%v</text>`, chartMargin+plotWidth/2, chartHeight-12, html.EscapeString(xTitle))
+	fmt.Fprintf(&builder, `<text x="16" y="%v" text-anchor="middle" transform="rotate(-90 16 %v)">%v</text>`, chartMargin+plotHeight/2, chartMargin+plotHeight/2, html.EscapeString(yTitle))
+
+	// Series and legend, the points of every line are joined by ascending x, whatever the
+	// order of the latencies.
+	order := make([]int, len(xValues))
+	for index := range order {
+		order[index] = index
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return xValues[order[i]] < xValues[order[j]]
+	})
+	for index, s := range series {
+		color := chartColors[index%len(chartColors)]
+		var points []string
+		for _, pointIndex := range order {
+			y := s.values[pointIndex]
+			if math.IsNaN(y) {
+				continue
+			}
+			x := scaleX(xValues[pointIndex])
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, scaleY(y)))
+			fmt.Fprintf(&builder, `<circle cx="%.1f" cy="%.1f" r="3" fill="%v"><title>%v %v: %v</title></circle>`, x, scaleY(y), color, html.EscapeString(s.name), html.EscapeString(xLabels[pointIndex]), formatChartValue(y))
+		}
+		fmt.Fprintf(&builder, `<polyline points="%v" fill="none" stroke="%v" stroke-width="2"/>`, strings.Join(points, " "), color)
+		legendY := chartMargin + index*18
+		fmt.Fprintf(&builder, `<rect x="%v" y="%v" width="12" height="12" fill="%v"/>`, legendX, legendY, color)
+		fmt.Fprintf(&builder, `<text x="%v" y="%v" dominant-baseline="middle">%v</text>`, legendX+18, legendY+6, html.EscapeString(s.name))
+	}
+	builder.WriteString(`</svg>`)
+
+	return template.HTML(builder.String())
+}
+
+// heatmapChart renders a heatmap as inline SVG, with one column per latency and one row
+// per percent. Colors range from pale yellow for the smallest value to dark red for the
+// greatest one, NaN values are left blank.
+func heatmapChart(columns, rows []string, values [][]float64) template.HTML {
+	var builder strings.Builder
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, row := range values {
+		for _, value := range row {
+			if !math.IsNaN(value) {
+				minValue, maxValue = math.Min(minValue, value), math.Max(maxValue, value)
+			}
+		}
+	}
+
+	width, height := chartMargin+len(columns)*heatmapCellWidth+8, chartMargin+len(rows)*heatmapCellHeight+8
+	fmt.Fprintf(&builder, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v">`, width, height, width, height)
+	for columnIndex, column := range columns {
+		fmt.Fprintf(&builder, `<text x="%v" y="%v" text-anchor="middle">%v</text>`, chartMargin+columnIndex*heatmapCellWidth+heatmapCellWidth/2, chartMargin-10, html.EscapeString(column))
+	}
+	for rowIndex, row := range rows {
+		y := chartMargin + rowIndex*heatmapCellHeight
+		fmt.Fprintf(&builder, `<text x="%v" y="%v" text-anchor="end" dominant-baseline="middle">%v</text>`, chartMargin-8, y+heatmapCellHeight/2, html.EscapeString(row))
+		for columnIndex, value := range values[rowIndex] {
+			if math.IsNaN(value) {
+				continue
+			}
+			ratio := 0.0
+			if maxValue > minValue {
+				ratio = (value - minValue) / (maxValue - minValue)
+			}
+			x := chartMargin + columnIndex*heatmapCellWidth
+			fmt.Fprintf(&builder, `<rect x="%v" y="%v" width="%v" height="%v" fill="%v" stroke="#fff"><title>%v, %v: %v</title></rect>`,
+				x, y, heatmapCellWidth, heatmapCellHeight, heatColor(ratio), html.EscapeString(row), html.EscapeString(columns[columnIndex]), formatChartValue(value))
+			textColor := "#222"
+			if ratio > 0.6 {
+				textColor = "#fff"
+			}
+			fmt.Fprintf(&builder, `<text x="%v" y="%v" text-anchor="middle" dominant-baseline="middle" style="fill:%v">%v</text>`, x+heatmapCellWidth/2, y+heatmapCellHeight/2, textColor, formatChartValue(value))
+		}
+	}
+	builder.WriteString(`</svg>`)
+
+	return template.HTML(builder.String())
+}
+
+// heatColor returns the color of a heatmap cell, interpolating from pale yellow (ratio 0)
+// to dark red (ratio 1).
+func heatColor(ratio float64) string {
+	from, to := [3]float64{255, 247, 188}, [3]float64{165, 15, 21}
+	var rgb [3]int
+	for index := range rgb {
+		rgb[index] = int(math.Round(from[index] + (to[index]-from[index])*ratio))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
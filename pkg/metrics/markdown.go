@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/options"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+// MatrixTables generates the tables of the final matrix, the same tables `Export` writes
+// first: one table per percent (and per statistic across trials) of the latency quantiles
+// and the success rate of verb `all`, with one column per latency.
+func (e *Exporter) MatrixTables() []printer.Table {
+	tables := make([]printer.Table, 0, e.numberOfTables)
+	for tableID := 0; tableID < e.numberOfTables; tableID++ {
+		headerRow := printer.TableRow{
+			printer.LineAlignRight(e.header[0]),
+		}
+		for _, column := range e.header[1:] {
+			headerRow.AddEntry(printer.LineAlignRight(column))
+		}
+		table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter(e.titles[tableID]))
+		table.SetHeaders(headerRow)
+
+		var tableRows []printer.TableRow
+		for _, row := range e.datum[tableID] {
+			tableRow := make(printer.TableRow, 0, len(row))
+			for _, entry := range row {
+				tableRow.AddEntry(printer.LineAlignRight(entry))
+			}
+			tableRows = append(tableRows, tableRow)
+		}
+		table.SetDatum(tableRows)
+		tables = append(tables, *table)
+	}
+	return tables
+}
+
+// WriteMarkdown exports the Markdown report of the run to the target folder.
+func (e *Exporter) WriteMarkdown(ctx context.Context, opts *options.Options, startTime, endTime time.Time) {
+	filepath := ExportFilePath(opts, startTime, "md")
+
+	klog.V(2).Infof("writing markdown report to %v", filepath)
+	if err := e.ExportMarkdown(ctx, opts, startTime, endTime, filepath); err != nil {
+		klog.Errorf("failed to export to file %v: %v", filepath, err.Error())
+		return
+	}
+	klog.V(2).Infof("successfully wrote markdown report to %v", filepath)
+}
+
+// ExportMarkdown exports a Markdown report to a file, which renders cleanly in code reviews:
+// the run metadata, the final matrix and the summary of every test.
+func (e *Exporter) ExportMarkdown(ctx context.Context, opts *options.Options, startTime, endTime time.Time, filepath string) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+
+	return e.RenderMarkdown(file, opts, startTime, endTime)
+}
+
+// RenderMarkdown renders the report of the run in Markdown to a writer.
+func (e *Exporter) RenderMarkdown(w io.Writer, opts *options.Options, startTime, endTime time.Time) error {
+	var builder strings.Builder
+	builder.WriteString("# Performance Testing Report\n\n")
+	for _, item := range [][2]string{
+		{"Run ID", opts.RunID},
+		{"Start time", startTime.Local().String()},
+		{"End time", endTime.Local().String()},
+		{"Duration", endTime.Sub(startTime).Round(time.Second).String()},
+		{"Total number of workers", fmt.Sprint(opts.WorkerNumber)},
+		{"Jobs done per worker", fmt.Sprint(opts.JobsPerWorker)},
+		{"Trials", fmt.Sprint(e.trials)},
+	} {
+		builder.WriteString("- " + item[0] + ": " + item[1] + "\n")
+	}
+	builder.WriteString("\n## Latency-Percent Matrix\n\n")
+	if _, err := io.WriteString(w, builder.String()); err != nil {
+		return err
+	}
+
+	for _, table := range e.MatrixTables() {
+		if err := table.Render(w, printer.Markdown); err != nil {
+			return err
+		}
+	}
+
+	if sensitivities := e.Sensitivities(opts.SensitivityVerbs); len(sensitivities) > 0 {
+		if _, err := io.WriteString(w, "## Sensitivity to Injected Latency\n\n"); err != nil {
+			return err
+		}
+		if err := SensitivitySheet(sensitivities).Render(w, printer.Markdown); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.WriteString(w, "## Tests\n\n"); err != nil {
+		return err
+	}
+	for _, cell := range e.baselines {
+		sheet := SummarySheet(cell, opts.WorkerNumber, opts.JobsPerWorker, nil)
+		sheet.SetTitle(printer.LineAlignCenter("Performance Testing Summary (baseline, trial " + fmt.Sprint(cell.Set.Trial) + ")"))
+		if err := sheet.Render(w, printer.Markdown); err != nil {
+			return err
+		}
+	}
+	for _, cell := range e.cells {
+		sheet := SummarySheet(cell, opts.WorkerNumber, opts.JobsPerWorker, e.Baseline())
+		sheet.SetTitle(printer.LineAlignCenter("Performance Testing Summary (" + cell.Set.String() + ")"))
+		if err := sheet.Render(w, printer.Markdown); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/options"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+const (
+	// plotBuckets is the number of log-scaled buckets of latency plots.
+	plotBuckets = 16
+	// plotBarWidth is the width of the bars of latency plots.
+	plotBarWidth = 40
+)
+
+// summaryPlot is the kind of latency plot appended to summaries, either `none`,
+// `histogram` or `cdf`.
+var summaryPlot = options.PlotNone
+
+// ConfigureSummaryPlot configures the kind of latency plot appended to summaries for
+// every verb, either `none`, `histogram` or `cdf`.
+func ConfigureSummaryPlot(kind string) {
+	summaryPlot = kind
+}
+
+// preparePlotTables generates the latency plots of every verb that has sent API requests
+// in a metric set, if plots are enabled.
+func preparePlotTables(set MetricSetID) []printer.Table {
+	if summaryPlot != options.PlotHistogram && summaryPlot != options.PlotCDF {
+		return nil
+	}
+	var tables []printer.Table
+	for _, verb := range constants.Verbs {
+		if latencies := collectRequestStats(verb, set).Latencies; latencies.Count() > 0 {
+			tables = append(tables, preparePlotTable(verb, latencies, summaryPlot == options.PlotCDF))
+		}
+	}
+	return tables
+}
+
+// preparePlotTable generates the latency histogram of a verb, or its CDF (the cumulative
+// percentage of API requests at most as slow as every bucket), in log-scaled buckets.
+func preparePlotTable(verb string, latencies *Histogram, cumulative bool) printer.Table {
+	kind, percentage := "Histogram", "Percent"
+	if cumulative {
+		kind, percentage = "CDF", "Cumulative"
+	}
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Latency(s)"),
+		printer.LineAlignRight("Requests"),
+		printer.LineAlignRight(percentage),
+		printer.LineAlignLeft("Distribution"),
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter(strings.ToUpper(verb)+" Latency "+kind))
+	table.SetHeaders(headerRow)
+
+	bounds, counts := logBuckets(latencies, plotBuckets)
+	var highest, seen uint64
+	for _, count := range counts {
+		if count > highest {
+			highest = count
+		}
+	}
+	total := latencies.Count()
+
+	var tableRows []printer.TableRow
+	for index, count := range counts {
+		seen += count
+		ratio := float64(count) / float64(highest)
+		if cumulative {
+			ratio = float64(seen) / float64(total)
+		}
+		shown := count
+		if cumulative {
+			shown = seen
+		}
+		tableRows = append(tableRows, printer.TableRow{
+			printer.LineAlignRight(fmt.Sprintf("<= %.5f", bounds[index])),
+			printer.LineAlignRight(fmt.Sprint(shown)),
+			printer.LineAlignRight(fmt.Sprintf("%.2f", float64(shown)/float64(total)*100)),
+			printer.LineAlignLeft(printer.Bar(ratio, plotBarWidth)),
+		})
+	}
+	table.SetDatum(tableRows)
+	return *table
+}
+
+// logBuckets regroups the values of a latency histogram into at most a given number of
+// buckets, whose boundaries grow geometrically from the minimum to the maximum latency.
+// It returns the upper bound and the number of values of every bucket.
+func logBuckets(latencies *Histogram, count int) ([]float64, []uint64) {
+	lowest, highest := math.Max(latencies.Min(), minIndexableValue), latencies.Max()
+	if highest <= lowest {
+		return []float64{highest}, []uint64{latencies.Count()}
+	}
+
+	bounds := make([]float64, count)
+	ratio := math.Pow(highest/lowest, 1/float64(count))
+	for index := range bounds {
+		bounds[index] = lowest * math.Pow(ratio, float64(index+1))
+	}
+	bounds[count-1] = highest
+
+	counts := make([]uint64, count)
+	for _, bucket := range latencies.Buckets() {
+		index := 0
+		if bucket.Value > lowest {
+			index = int(math.Log(bucket.Value/lowest) / math.Log(ratio))
+		}
+		if index >= count {
+			index = count - 1
+		}
+		counts[index] += bucket.Count
+	}
+	return bounds, counts
+}
@@ -0,0 +1,334 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+)
+
+var (
+	// progress tracks the test currently running.
+	progress = &progressTracker{}
+
+	currentTest = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "current_test",
+			Help: "Set to 1 for the latency-percent pair currently being tested",
+		},
+		[]string{"latency", "percent"},
+	)
+
+	finishedTests = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "finished_tests",
+			Help: "Number of latency-percent pairs that have finished testing",
+		},
+	)
+
+	totalTests = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "total_tests",
+			Help: "Number of latency-percent pairs to be tested",
+		},
+	)
+
+	currentTestProgress = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "current_test_progress_ratio",
+			Help: "Ratio of API requests sent to API requests expected in the test currently running",
+		},
+		func() float64 {
+			return progress.ratio()
+		},
+	)
+)
+
+func init() {
+	registry.MustRegister(currentTest)
+	registry.MustRegister(finishedTests)
+	registry.MustRegister(totalTests)
+	registry.MustRegister(currentTestProgress)
+}
+
+const (
+	// ChaosStatusCleaningUp is the IOChaos status while the environment is cleaned up before a test.
+	ChaosStatusCleaningUp = "cleaning up"
+	// ChaosStatusInjecting is the IOChaos status while the IOChaos is created and being injected.
+	ChaosStatusInjecting = "injecting"
+	// ChaosStatusInjected is the IOChaos status while the IOChaos is injected.
+	ChaosStatusInjected = "injected"
+	// ChaosStatusRemoving is the IOChaos status while the IOChaos is being deleted.
+	ChaosStatusRemoving = "removing"
+	// ChaosStatusRemoved is the IOChaos status once the IOChaos has been deleted.
+	ChaosStatusRemoved = "removed"
+	// ChaosStatusBaseline is the IOChaos status during a baseline test, which runs without any IOChaos.
+	ChaosStatusBaseline = "none (baseline)"
+
+	// progressWindow is the length of the rolling window live throughputs and latencies are
+	// computed over.
+	progressWindow = 10 * time.Second
+)
+
+// progressTracker tracks the progress of the run and of the test currently running.
+type progressTracker struct {
+	lock sync.Mutex
+	// set is the metric set of the test currently running.
+	set MetricSetID
+	// running is `true` when a test is running.
+	running bool
+	// runStart is the time the first test started.
+	runStart time.Time
+	// testStart is the time the test currently running started.
+	testStart time.Time
+	// finished is the number of tests that have finished.
+	finished int
+	// total is the number of tests to be run.
+	total int
+	// expected is the number of API requests the test is expected to send.
+	expected uint64
+	// sent is the number of API requests the test has sent.
+	sent uint64
+	// workers are the number of API requests every worker has sent, the item index is
+	// the worker ID.
+	workers []uint64
+	// chaos is the status of the IOChaos of the test currently running.
+	chaos string
+	// window holds the API requests sent during the last seconds.
+	window *rollingWindow
+}
+
+// count counts an API request if it belongs to the test currently running.
+func (p *progressTracker) count(verb string, success bool, seconds float64, set MetricSetID, workerID int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.running && p.set == set {
+		p.sent++
+		if workerID >= 0 && workerID < len(p.workers) {
+			p.workers[workerID]++
+		}
+		now := time.Now()
+		p.window.record(verb, success, seconds, now)
+		p.window.record(constants.ALL, success, seconds, now)
+	}
+}
+
+// ratio returns the progress of the test currently running.
+func (p *progressTracker) ratio() float64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.ratioLocked()
+}
+
+// ratioLocked returns the progress of the test currently running, the lock must be held.
+func (p *progressTracker) ratioLocked() float64 {
+	if !p.running || p.expected == 0 {
+		return 0
+	}
+	return math.Min(float64(p.sent)/float64(p.expected), 1)
+}
+
+// StartTest marks a test as running. `finished` and `total` are the number of tests that
+// have finished and that are to be run, every one of the `workers` is expected to send
+// `requestsPerWorker` API requests during the test.
+func StartTest(set MetricSetID, finished, total, workers int, requestsPerWorker uint64) {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+
+	currentTest.Reset()
+	currentTest.WithLabelValues(set.Latency, set.Percent).Set(1)
+	finishedTests.Set(float64(finished))
+	totalTests.Set(float64(total))
+
+	now := time.Now()
+	if progress.runStart.IsZero() {
+		progress.runStart = now
+	}
+	progress.set = set
+	progress.running = true
+	progress.testStart = now
+	progress.finished = finished
+	progress.total = total
+	progress.expected = uint64(workers) * requestsPerWorker
+	progress.sent = 0
+	progress.workers = make([]uint64, workers)
+	progress.window = newRollingWindow(progressWindow, time.Second)
+}
+
+// FinishTest marks the test currently running as finished.
+func FinishTest() {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+
+	currentTest.Reset()
+	finishedTests.Inc()
+	progress.running = false
+	progress.finished++
+}
+
+// SetChaosStatus sets the status of the IOChaos of the current test (e.g. `injecting`),
+// which is reported by the progress.
+func SetChaosStatus(status string) {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+	progress.chaos = status
+}
+
+// Progress is a snapshot of the progress of the run.
+type Progress struct {
+	// Set is the metric set of the current test, or of the last test if none is running.
+	Set MetricSetID
+	// Running is `true` when a test is running.
+	Running bool
+	// Finished is the number of tests that have finished.
+	Finished int
+	// Total is the number of tests to be run.
+	Total int
+	// Elapsed is the time elapsed since the first test started.
+	Elapsed time.Duration
+	// TestElapsed is the time elapsed since the current test started.
+	TestElapsed time.Duration
+	// Ratio is the progress of the current test.
+	Ratio float64
+	// Sent is the number of API requests the current test has sent.
+	Sent uint64
+	// Expected is the number of API requests the current test is expected to send.
+	Expected uint64
+	// RequestsPerWorker is the number of API requests every worker is expected to send.
+	RequestsPerWorker uint64
+	// Workers are the number of API requests every worker has sent in the current test,
+	// the item index is the worker ID.
+	Workers []uint64
+	// ChaosStatus is the status of the IOChaos of the current test.
+	ChaosStatus string
+	// Window is the length of the rolling window of `Verbs`.
+	Window time.Duration
+	// Verbs maps verbs to the API requests sent during the rolling window.
+	Verbs map[string]*RequestStats
+}
+
+// CurrentProgress returns a snapshot of the progress of the run.
+func CurrentProgress() *Progress {
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+
+	now := time.Now()
+	snapshot := &Progress{
+		Set:         progress.set,
+		Running:     progress.running,
+		Finished:    progress.finished,
+		Total:       progress.total,
+		Ratio:       progress.ratioLocked(),
+		Sent:        progress.sent,
+		Expected:    progress.expected,
+		Workers:     append([]uint64(nil), progress.workers...),
+		ChaosStatus: progress.chaos,
+	}
+	if !progress.runStart.IsZero() {
+		snapshot.Elapsed = now.Sub(progress.runStart)
+	}
+	if !progress.testStart.IsZero() {
+		snapshot.TestElapsed = now.Sub(progress.testStart)
+	}
+	if len(progress.workers) > 0 {
+		snapshot.RequestsPerWorker = progress.expected / uint64(len(progress.workers))
+	}
+	if progress.window != nil {
+		snapshot.Verbs = progress.window.merged(now)
+		// The window is shorter than its full length at the beginning of a test.
+		snapshot.Window = progress.window.length
+		if progress.running && snapshot.TestElapsed < snapshot.Window {
+			snapshot.Window = snapshot.TestElapsed
+		}
+	}
+	return snapshot
+}
+
+// ETA estimates the time left before every test has finished, assuming the remaining tests
+// take as long as the finished ones. It returns a negative duration when it cannot be
+// estimated yet.
+func (p *Progress) ETA() time.Duration {
+	done := float64(p.Finished)
+	if p.Running {
+		done += p.Ratio
+	}
+	if done <= 0 || p.Total == 0 {
+		return -1
+	}
+	return time.Duration(float64(p.Elapsed) * (float64(p.Total) - done) / done)
+}
+
+// Throughput returns the number of API requests of a verb sent per second during the
+// rolling window.
+func (p *Progress) Throughput(verb string) float64 {
+	stats, ok := p.Verbs[verb]
+	if !ok || p.Window <= 0 {
+		return 0
+	}
+	return float64(stats.Total) / p.Window.Seconds()
+}
+
+// rollingWindow holds API requests by verb in fixed-length buckets, covering a rolling
+// window that ends now.
+type rollingWindow struct {
+	// length is the length of the window.
+	length time.Duration
+	// width is the length of every bucket.
+	width time.Duration
+	// buckets are used round-robin, `indexes` holding the absolute index of the interval
+	// every bucket currently holds.
+	buckets []map[string]*RequestStats
+	indexes []int64
+}
+
+// newRollingWindow creates a rolling window of a given length and bucket width.
+func newRollingWindow(length, width time.Duration) *rollingWindow {
+	count := int(length / width)
+	return &rollingWindow{
+		length:  time.Duration(count) * width,
+		width:   width,
+		buckets: make([]map[string]*RequestStats, count),
+		indexes: make([]int64, count),
+	}
+}
+
+// record records an API request that finishes at a given time.
+func (w *rollingWindow) record(verb string, success bool, seconds float64, at time.Time) {
+	index := at.UnixNano() / int64(w.width)
+	slot := int(index % int64(len(w.buckets)))
+	if w.buckets[slot] == nil || w.indexes[slot] != index {
+		w.buckets[slot] = make(map[string]*RequestStats)
+		w.indexes[slot] = index
+	}
+	stats, ok := w.buckets[slot][verb]
+	if !ok {
+		stats = NewRequestStats()
+		w.buckets[slot][verb] = stats
+	}
+	stats.Total++
+	if success {
+		stats.Successful++
+	}
+	stats.Latencies.Observe(seconds)
+}
+
+// merged merges the API requests of the buckets that are still in the window at a given time.
+func (w *rollingWindow) merged(at time.Time) map[string]*RequestStats {
+	oldest := at.UnixNano()/int64(w.width) - int64(len(w.buckets)) + 1
+	merged := make(map[string]*RequestStats)
+	for slot, bucket := range w.buckets {
+		if bucket == nil || w.indexes[slot] < oldest {
+			continue
+		}
+		for verb, stats := range bucket {
+			if _, ok := merged[verb]; !ok {
+				merged[verb] = NewRequestStats()
+			}
+			_ = merged[verb].Merge(stats)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+)
+
+// Pusher pushes the results of every test to a Pushgateway-compatible HTTP endpoint,
+// so that they survive short-lived test runner pods. Metrics are grouped by run ID,
+// and per-test metrics are further grouped by latency and percent.
+type Pusher struct {
+	// url is the URL of the Pushgateway.
+	url string
+	// job is the value of the `job` grouping label.
+	job string
+	// runID is the value of the `run_id` grouping label.
+	runID string
+	// backoff is the backoff used to retry failed pushes.
+	backoff wait.Backoff
+	// client is the HTTP client used to push metrics.
+	client push.HTTPDoer
+}
+
+// NewPusher instantiates a new Pusher that retries failed pushes up to `retries` times.
+// `client` is used to send the pushes, `http.DefaultClient` is used when it is nil.
+func NewPusher(url, job, runID string, retries int, client push.HTTPDoer) *Pusher {
+	return &Pusher{
+		url:   url,
+		job:   job,
+		runID: runID,
+		backoff: wait.Backoff{
+			Steps:    retries + 1,
+			Duration: time.Second,
+			Factor:   2,
+			Jitter:   0.1,
+		},
+		client: client,
+	}
+}
+
+// PushTest pushes the statistics of a single test.
+func (p *Pusher) PushTest(ctx context.Context, cell CellStats) {
+	reg := prometheus.NewRegistry()
+
+	requests := newPushedGaugeVec(reg, "perftests_test_requests", "API requests sent during the test", "verb")
+	successful := newPushedGaugeVec(reg, "perftests_test_successful_requests", "API requests that did not get error response during the test", "verb")
+	successRate := newPushedGaugeVec(reg, "perftests_test_success_rate_percent", "Percentage of successful API requests during the test", "verb")
+	latencies := newPushedGaugeVec(reg, "perftests_test_latency_seconds", "Quantiles of API request latencies during the test", "verb", "quantile")
+	duration := newPushedGaugeVec(reg, "perftests_test_duration_seconds", "Duration of the test")
+	for _, verb := range constants.Verbs {
+		stats, ok := cell.Verbs[verb]
+		if !ok {
+			continue
+		}
+		requests.WithLabelValues(verb).Set(float64(stats.Total))
+		successful.WithLabelValues(verb).Set(float64(stats.Successful))
+		successRate.WithLabelValues(verb).Set(stats.SuccessRate())
+		for _, quantile := range SortedQuantiles {
+			latencies.WithLabelValues(verb, fmt.Sprint(quantile)).Set(stats.Latencies.Quantile(quantile))
+		}
+	}
+	duration.WithLabelValues().Set(cell.End.Sub(cell.Start).Seconds())
+
+	grouping := map[string]string{
+		"latency": cell.Set.Latency,
+		"percent": cell.Set.Percent,
+	}
+	if cell.Set.Trial > 0 {
+		grouping["trial"] = fmt.Sprint(cell.Set.Trial)
+	}
+	p.push(ctx, reg, fmt.Sprintf("test (%v)", cell.Set), grouping)
+}
+
+// PushRun pushes the statistics of every finished test again, followed by the overall
+// progress of the run, so that the Pushgateway holds the complete results even if some
+// pushes failed during the run. `totalTests` is the number of latency-percent pairs to be
+// tested, baseline tests excluded.
+func (p *Pusher) PushRun(ctx context.Context, cells []CellStats, totalTests int, start, end time.Time) {
+	for _, cell := range cells {
+		p.PushTest(ctx, cell)
+	}
+
+	reg := prometheus.NewRegistry()
+	newPushedGaugeVec(reg, "perftests_run_finished_tests", "Latency-percent pairs that have finished testing").WithLabelValues().Set(float64(len(cells)))
+	newPushedGaugeVec(reg, "perftests_run_total_tests", "Latency-percent pairs to be tested").WithLabelValues().Set(float64(totalTests))
+	newPushedGaugeVec(reg, "perftests_run_start_timestamp_seconds", "Time the run started").WithLabelValues().Set(float64(start.Unix()))
+	newPushedGaugeVec(reg, "perftests_run_duration_seconds", "Duration of the run").WithLabelValues().Set(end.Sub(start).Seconds())
+
+	p.push(ctx, reg, "run", nil)
+}
+
+// push pushes all metrics in a registry with the given grouping labels in addition to
+// the run ID, retrying with backoff on failure.
+func (p *Pusher) push(ctx context.Context, gatherer prometheus.Gatherer, description string, groupings map[string]string) {
+	pusher := push.New(p.url, p.job).Gatherer(gatherer).Grouping("run_id", p.runID)
+	for name, value := range groupings {
+		pusher = pusher.Grouping(name, value)
+	}
+	if p.client != nil {
+		pusher = pusher.Client(p.client)
+	}
+
+	klog.V(4).Infof("pushing metrics of %v to %v", description, p.url)
+	backoff := p.backoff
+	for attempts := 1; ; attempts++ {
+		err := pusher.PushContext(ctx)
+		if err == nil {
+			klog.V(4).Infof("successfully pushed metrics of %v", description)
+			return
+		}
+		klog.V(4).Infof("failed to push metrics of %v (attempt %v): %v", description, attempts, err.Error())
+
+		// Stop retrying once every attempt has been made, or once the context is done so
+		// that an unreachable Pushgateway does not hold up the shutdown.
+		if backoff.Steps <= 1 {
+			klog.Errorf("failed to push metrics of %v to %v after %v attempts: %v", description, p.url, attempts, err.Error())
+			return
+		}
+		select {
+		case <-ctx.Done():
+			klog.Errorf("failed to push metrics of %v to %v after %v attempts, giving up: %v", description, p.url, attempts, err.Error())
+			return
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+// newPushedGaugeVec creates a gauge vector and registers it in a registry.
+func newPushedGaugeVec(reg *prometheus.Registry, name, help string, labels ...string) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+	reg.MustRegister(gauge)
+	return gauge
+}
@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+)
+
+// pushedRequest is a push received by a Pushgateway stand-in.
+type pushedRequest struct {
+	method   string
+	path     string
+	families map[string]*dto.MetricFamily
+}
+
+// pushgateway is a Pushgateway stand-in recording every push, and answering with a status.
+type pushgateway struct {
+	lock     sync.Mutex
+	status   int
+	requests []pushedRequest
+}
+
+func (g *pushgateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request := pushedRequest{method: r.Method, path: r.URL.Path, families: make(map[string]*dto.MetricFamily)}
+	decoder := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+	for {
+		family := &dto.MetricFamily{}
+		if err := decoder.Decode(family); err != nil {
+			break
+		}
+		request.families[family.GetName()] = family
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.requests = append(g.requests, request)
+	w.WriteHeader(g.status)
+}
+
+func (g *pushgateway) pushes() []pushedRequest {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return append([]pushedRequest(nil), g.requests...)
+}
+
+// newTestPusher instantiates a pusher to a Pushgateway stand-in, retrying without delay.
+func newTestPusher(t *testing.T, status, retries int) (*Pusher, *pushgateway) {
+	gateway := &pushgateway{status: status}
+	server := httptest.NewServer(gateway)
+	t.Cleanup(server.Close)
+
+	pusher := NewPusher(server.URL, "perftests", "nightly", retries, nil)
+	pusher.backoff.Duration = time.Millisecond
+	return pusher, gateway
+}
+
+// newTestCell instantiates the statistics of a test of verb `create` with given latencies.
+func newTestCell(set MetricSetID, latencies ...float64) CellStats {
+	create, all := NewRequestStats(), NewRequestStats()
+	for _, stats := range []*RequestStats{create, all} {
+		for _, latency := range latencies {
+			stats.Total++
+			stats.Successful++
+			stats.Latencies.Observe(latency)
+		}
+	}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	return CellStats{
+		Set:   set,
+		Start: start,
+		End:   start.Add(10 * time.Second),
+		Verbs: map[string]*RequestStats{constants.CREATE: create, constants.ALL: all},
+	}
+}
+
+// groupingKey parses the grouping key of a push from its URL path, e.g.
+// `/metrics/job/perftests/run_id/nightly`.
+func groupingKey(t *testing.T, path string) map[string]string {
+	segments := strings.Split(strings.TrimPrefix(path, "/metrics/"), "/")
+	if len(segments)%2 != 0 {
+		t.Fatalf("%v is not a valid grouping key path", path)
+	}
+	key := make(map[string]string, len(segments)/2)
+	for index := 0; index < len(segments); index += 2 {
+		key[segments[index]] = segments[index+1]
+	}
+	return key
+}
+
+// captureLogs redirects klog to a buffer until the test finishes.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	_ = fs.Set("logtostderr", "false")
+	_ = fs.Set("alsologtostderr", "false")
+	_ = fs.Set("stderrthreshold", "FATAL")
+
+	var buffer bytes.Buffer
+	klog.SetOutput(&buffer)
+	t.Cleanup(func() {
+		klog.Flush()
+		_ = fs.Set("logtostderr", "true")
+	})
+	return &buffer
+}
+
+func TestPushTestGroupingKeyAndBody(t *testing.T) {
+	pusher, gateway := newTestPusher(t, http.StatusOK, 0)
+	pusher.PushTest(context.Background(), newTestCell(MetricSetID{Latency: "100ms", Percent: "50", Trial: 2}, 0.1, 0.2, 0.3))
+
+	pushes := gateway.pushes()
+	if len(pushes) != 1 {
+		t.Fatalf("got %v pushes, want 1", len(pushes))
+	}
+	push := pushes[0]
+	if push.method != http.MethodPut {
+		t.Errorf("got method %v, want %v", push.method, http.MethodPut)
+	}
+	want := map[string]string{"job": "perftests", "run_id": "nightly", "latency": "100ms", "percent": "50", "trial": "2"}
+	if got := groupingKey(t, push.path); !reflect.DeepEqual(got, want) {
+		t.Errorf("got grouping key %v (path %v), want %v", got, push.path, want)
+	}
+
+	requests, ok := push.families["perftests_test_requests"]
+	if !ok {
+		t.Fatalf("pushed body lacks perftests_test_requests, got %v", push.families)
+	}
+	values := make(map[string]float64)
+	for _, metric := range requests.GetMetric() {
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == "verb" {
+				values[label.GetValue()] = metric.GetGauge().GetValue()
+			}
+		}
+	}
+	if values[constants.CREATE] != 3 || values[constants.ALL] != 3 {
+		t.Errorf("got pushed requests %v, want 3 for verbs create and all", values)
+	}
+	duration, ok := push.families["perftests_test_duration_seconds"]
+	if !ok || len(duration.GetMetric()) != 1 || duration.GetMetric()[0].GetGauge().GetValue() != 10 {
+		t.Errorf("got pushed duration %v, want 10", duration)
+	}
+	if _, ok := push.families["perftests_test_latency_seconds"]; !ok {
+		t.Errorf("pushed body lacks perftests_test_latency_seconds")
+	}
+}
+
+func TestPushRunGroupingKey(t *testing.T) {
+	pusher, gateway := newTestPusher(t, http.StatusOK, 0)
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	pusher.PushRun(context.Background(), []CellStats{newTestCell(MetricSetID{Latency: "10ms", Percent: "20"}, 0.1)}, 4, start, start.Add(time.Minute))
+
+	pushes := gateway.pushes()
+	if len(pushes) != 2 {
+		t.Fatalf("got %v pushes, want 2", len(pushes))
+	}
+	want := map[string]string{"job": "perftests", "run_id": "nightly", "latency": "10ms", "percent": "20"}
+	if got := groupingKey(t, pushes[0].path); !reflect.DeepEqual(got, want) {
+		t.Errorf("got test grouping key %v, want %v", got, want)
+	}
+	want = map[string]string{"job": "perftests", "run_id": "nightly"}
+	if got := groupingKey(t, pushes[1].path); !reflect.DeepEqual(got, want) {
+		t.Errorf("got run grouping key %v, want %v", got, want)
+	}
+	total, ok := pushes[1].families["perftests_run_total_tests"]
+	if !ok || total.GetMetric()[0].GetGauge().GetValue() != 4 {
+		t.Errorf("got pushed total tests %v, want 4", total)
+	}
+}
+
+func TestPushRetriesServerErrors(t *testing.T) {
+	logs := captureLogs(t)
+	pusher, gateway := newTestPusher(t, http.StatusInternalServerError, 2)
+	pusher.PushTest(context.Background(), newTestCell(MetricSetID{Latency: "100ms", Percent: "50"}, 0.1))
+
+	if pushes := gateway.pushes(); len(pushes) != 3 {
+		t.Errorf("got %v attempts, want 3 (1 push and 2 retries)", len(pushes))
+	}
+	klog.Flush()
+	if !strings.Contains(logs.String(), "failed to push metrics of test") || !strings.Contains(logs.String(), "after 3 attempts") {
+		t.Errorf("failure not logged, got logs %q", logs.String())
+	}
+}
+
+func TestPushStopsRetryingWhenCancelled(t *testing.T) {
+	logs := captureLogs(t)
+	pusher, gateway := newTestPusher(t, http.StatusServiceUnavailable, 10)
+	pusher.backoff.Duration = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pusher.PushTest(ctx, newTestCell(MetricSetID{Latency: "100ms", Percent: "50"}, 0.1))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("push kept retrying after the context was cancelled")
+	}
+	if pushes := gateway.pushes(); len(pushes) != 1 {
+		t.Errorf("got %v attempts, want 1", len(pushes))
+	}
+	klog.Flush()
+	if !strings.Contains(logs.String(), "giving up") {
+		t.Errorf("failure not logged, got logs %q", logs.String())
+	}
+}
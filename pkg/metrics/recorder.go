@@ -2,22 +2,42 @@ package metrics
 
 import (
 	"time"
+
+	"github.com/nemoremold/perftests/pkg/constants"
 )
 
-// RecordAPIRequest receives a API request report and stores it in the Prometheus registry.
-// In addition to storing with the original verb, it all stores it with verb `all`.
-func RecordAPIRequest(verb string, success bool, duration time.Duration, set MetricSetID) {
-	recordAPIRequest(verb, success, duration, set)
-	recordAPIRequest("all", success, duration, set)
+// RecordAPIRequest receives a API request report of a worker and stores it in the Prometheus
+// registry. In addition to storing with the original verb, it all stores it with verb `all`.
+// API requests sent during the warm-up period of the test are stored under phase `warmup`.
+// A request is successful if its error is nil, otherwise its error is classified by reason.
+func RecordAPIRequest(verb string, err error, duration time.Duration, set MetricSetID, workerID int) {
+	success, reason := err == nil, ErrorReason(err)
+	phase := PhaseMeasured
+	if warmUp.admit(set) {
+		phase = PhaseWarmUp
+	}
+	workers.record(workerID, success, duration.Seconds(), set, phase)
+	recordAPIRequest(verb, success, reason, duration, set, phase)
+	recordAPIRequest(constants.ALL, success, reason, duration, set, phase)
+	progress.count(verb, success, duration.Seconds(), set, workerID)
 }
 
-// recordAPIRequest receives a API request report and stores it in the Prometheus registry.
-func recordAPIRequest(verb string, success bool, duration time.Duration, set MetricSetID) {
-	totalAPIRequests.WithLabelValues(verb, set.Latency, set.Percent).Inc()
+// recordAPIRequest receives a API request report and stores it in the Prometheus registry,
+// the statistics store and the time series of its test. The reason is empty for successful
+// API requests.
+func recordAPIRequest(verb string, success bool, reason string, duration time.Duration, set MetricSetID, phase string) {
+	totalAPIRequests.WithLabelValues(verb, set.Latency, set.Percent, phase).Inc()
 
 	if success {
-		successfulAPIRequests.WithLabelValues(verb, set.Latency, set.Percent).Inc()
+		successfulAPIRequests.WithLabelValues(verb, set.Latency, set.Percent, phase).Inc()
+	} else {
+		failedAPIRequests.WithLabelValues(verb, set.Latency, set.Percent, phase, reason).Inc()
 	}
 
-	apiRequestLatencies.WithLabelValues(verb, set.Latency, set.Percent).Observe(duration.Seconds())
+	apiRequestLatencies.WithLabelValues(verb, set.Latency, set.Percent, phase).Observe(duration.Seconds())
+	recordInTimeSeries(verb, success, duration.Seconds(), set)
+	if phase == PhaseWarmUp {
+		set.Phase = PhaseWarmUp
+	}
+	stats.record(verb, success, reason, duration.Seconds(), set)
 }
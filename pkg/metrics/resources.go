@@ -0,0 +1,259 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	runtimemetrics "runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+const (
+	// processCPUMetric is the user and system CPU time spent by the process.
+	processCPUMetric = "process_cpu_seconds_total"
+	// processRSSMetric is the resident memory size of the process.
+	processRSSMetric = "process_resident_memory_bytes"
+	// schedLatenciesMetric is the runtime metric of the time goroutines spent runnable
+	// before actually running.
+	schedLatenciesMetric = "/sched/latencies:seconds"
+
+	// saturationCPURatio is the ratio of the CPU cores used to `GOMAXPROCS` above which
+	// the process is likely CPU-bound.
+	saturationCPURatio = 0.9
+	// saturationSchedLatency is the P99 scheduling latency above which goroutines likely
+	// wait too long to run, delaying the observation of API request latencies.
+	saturationSchedLatency = 10 * time.Millisecond
+)
+
+var (
+	// processRegistry only holds the process collector, so that sampling the resource usage
+	// of the process does not gather every metric of the registry.
+	processRegistry = prometheus.NewRegistry()
+)
+
+func init() {
+	processRegistry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// ResourceUsage holds the resource usage of the perftests process itself during a test,
+// which tells whether the load generator was saturated. CPU and memory usage are 0 on
+// platforms where the process metrics are not available.
+type ResourceUsage struct {
+	// GOMAXPROCS is the number of CPU cores the process may use simultaneously.
+	GOMAXPROCS int `json:"gomaxprocs"`
+	// Samples is the number of samples taken.
+	Samples int `json:"samples"`
+	// CPUSeconds is the user and system CPU time spent.
+	CPUSeconds float64 `json:"cpuSeconds"`
+	// MeanCPUCores is the mean number of CPU cores used, i.e. the CPU time spent per second.
+	MeanCPUCores float64 `json:"meanCpuCores"`
+	// MaxCPUCores is the greatest number of CPU cores used between two samples.
+	MaxCPUCores float64 `json:"maxCpuCores"`
+	// MaxRSSBytes is the greatest resident memory size sampled.
+	MaxRSSBytes float64 `json:"maxRssBytes"`
+	// MaxGoroutines is the greatest number of goroutines sampled.
+	MaxGoroutines int `json:"maxGoroutines"`
+	// GCCycles is the number of completed GC cycles.
+	GCCycles uint32 `json:"gcCycles"`
+	// GCPauseSeconds is the total time the world was stopped for GC.
+	GCPauseSeconds float64 `json:"gcPauseSeconds"`
+	// SchedLatencyP99 is the P99 time goroutines spent runnable before actually running.
+	SchedLatencyP99 float64 `json:"schedLatencyP99"`
+}
+
+// SaturationWarnings returns the reasons why the load generator was likely saturated
+// during the test, in which case the measured latencies include client-side delays.
+func (u *ResourceUsage) SaturationWarnings() []string {
+	var warnings []string
+	if limit := saturationCPURatio * float64(u.GOMAXPROCS); u.GOMAXPROCS > 0 && u.MaxCPUCores >= limit {
+		warnings = append(warnings, fmt.Sprintf("CPU usage peaked at %.2f cores, near GOMAXPROCS %v", u.MaxCPUCores, u.GOMAXPROCS))
+	}
+	if u.SchedLatencyP99 >= saturationSchedLatency.Seconds() {
+		warnings = append(warnings, fmt.Sprintf("P99 scheduling latency is %v", time.Duration(u.SchedLatencyP99*float64(time.Second)).Round(time.Microsecond)))
+	}
+	return warnings
+}
+
+// resourceSample is the resource usage of the process at a point in time.
+type resourceSample struct {
+	// at is the time the sample was taken.
+	at time.Time
+	// cpuSeconds is the CPU time spent since the process started.
+	cpuSeconds float64
+	// rssBytes is the resident memory size.
+	rssBytes float64
+	// goroutines is the number of goroutines.
+	goroutines int
+}
+
+// sampleResources samples the resource usage of the process now.
+func sampleResources() resourceSample {
+	sample := resourceSample{
+		at:         time.Now(),
+		goroutines: runtime.NumGoroutine(),
+	}
+	families, err := processRegistry.Gather()
+	if err != nil {
+		return sample
+	}
+	scrape := make(Scrape, len(families))
+	for _, family := range families {
+		scrape[family.GetName()] = family
+	}
+	sample.cpuSeconds = sampleValues(scrape, processCPUMetric, "")[""]
+	sample.rssBytes = sampleValues(scrape, processRSSMetric, "")[""]
+	return sample
+}
+
+// readSchedLatencies reads the cumulative histogram of scheduling latencies, nil if the
+// runtime does not support it.
+func readSchedLatencies() *runtimemetrics.Float64Histogram {
+	samples := []runtimemetrics.Sample{{Name: schedLatenciesMetric}}
+	runtimemetrics.Read(samples)
+	if samples[0].Value.Kind() != runtimemetrics.KindFloat64Histogram {
+		return nil
+	}
+	return samples[0].Value.Float64Histogram()
+}
+
+// schedLatencyDelta computes the scheduling latencies observed between two reads as a
+// histogram, so that its quantiles can be estimated.
+func schedLatencyDelta(before, after *runtimemetrics.Float64Histogram) *ServerHistogram {
+	histogram := &ServerHistogram{}
+	if before == nil || after == nil || len(before.Counts) != len(after.Counts) {
+		return histogram
+	}
+	// `Buckets` holds the boundaries of `Counts`, bucket `i` ranging from `Buckets[i]` to
+	// `Buckets[i+1]`.
+	for index, count := range after.Counts {
+		histogram.Count += count - before.Counts[index]
+		if upperBound := after.Buckets[index+1]; !math.IsInf(upperBound, 1) {
+			histogram.Buckets = append(histogram.Buckets, ServerBucket{UpperBound: upperBound, Count: histogram.Count})
+		}
+	}
+	return histogram
+}
+
+// ResourceMonitor samples the resource usage of the process in fixed-length intervals
+// during a test.
+type ResourceMonitor struct {
+	lock sync.Mutex
+
+	// samples are the samples taken by chronological order.
+	samples []resourceSample
+	// memStats are the memory statistics of the runtime when the monitor started.
+	memStats runtime.MemStats
+	// schedLatencies are the scheduling latencies when the monitor started.
+	schedLatencies *runtimemetrics.Float64Histogram
+
+	// stop stops the sampling goroutine.
+	stop chan struct{}
+	// done is closed once the sampling goroutine has returned.
+	done chan struct{}
+}
+
+// StartResourceMonitor starts sampling the resource usage of the process in intervals of
+// a given length, the first sample is taken now.
+func StartResourceMonitor(interval time.Duration) *ResourceMonitor {
+	m := &ResourceMonitor{
+		samples:        []resourceSample{sampleResources()},
+		schedLatencies: readSchedLatencies(),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	runtime.ReadMemStats(&m.memStats)
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				sample := sampleResources()
+				m.lock.Lock()
+				m.samples = append(m.samples, sample)
+				m.lock.Unlock()
+			}
+		}
+	}()
+	return m
+}
+
+// Stop stops sampling, takes the last sample and returns the resource usage of the
+// process since the monitor started.
+func (m *ResourceMonitor) Stop() *ResourceUsage {
+	close(m.stop)
+	<-m.done
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	m.samples = append(m.samples, sampleResources())
+
+	usage := &ResourceUsage{
+		GOMAXPROCS:      runtime.GOMAXPROCS(0),
+		Samples:         len(m.samples),
+		GCCycles:        memStats.NumGC - m.memStats.NumGC,
+		GCPauseSeconds:  time.Duration(memStats.PauseTotalNs - m.memStats.PauseTotalNs).Seconds(),
+		SchedLatencyP99: schedLatencyDelta(m.schedLatencies, readSchedLatencies()).Quantile(0.99),
+	}
+	first, last := m.samples[0], m.samples[len(m.samples)-1]
+	usage.CPUSeconds = last.cpuSeconds - first.cpuSeconds
+	if elapsed := last.at.Sub(first.at).Seconds(); elapsed > 0 {
+		usage.MeanCPUCores = usage.CPUSeconds / elapsed
+	}
+	for index, sample := range m.samples {
+		usage.MaxRSSBytes = math.Max(usage.MaxRSSBytes, sample.rssBytes)
+		if sample.goroutines > usage.MaxGoroutines {
+			usage.MaxGoroutines = sample.goroutines
+		}
+		if index == 0 {
+			continue
+		}
+		previous := m.samples[index-1]
+		if elapsed := sample.at.Sub(previous.at).Seconds(); elapsed > 0 {
+			usage.MaxCPUCores = math.Max(usage.MaxCPUCores, (sample.cpuSeconds-previous.cpuSeconds)/elapsed)
+		}
+	}
+	return usage
+}
+
+// rows flattens the resource usage into named values by a stable order, which are the
+// rows of the resource usage tables of exported reports.
+func (u *ResourceUsage) rows() []namedValue {
+	return []namedValue{
+		{name: "cpu seconds", value: u.CPUSeconds},
+		{name: "mean cpu cores", value: u.MeanCPUCores},
+		{name: "max cpu cores", value: u.MaxCPUCores},
+		{name: "gomaxprocs", value: float64(u.GOMAXPROCS)},
+		{name: "max rss bytes", value: u.MaxRSSBytes},
+		{name: "max goroutines", value: float64(u.MaxGoroutines)},
+		{name: "gc cycles", value: float64(u.GCCycles)},
+		{name: "gc pause seconds", value: u.GCPauseSeconds},
+		{name: "sched latency P99", value: u.SchedLatencyP99},
+	}
+}
+
+// resourceUsageFooter generates the lines of the summary footer about the resource usage
+// of the load generator.
+func resourceUsageFooter(usage *ResourceUsage) []printer.Line {
+	lines := []printer.Line{
+		printer.LineAlignLeft(fmt.Sprintf("   Client CPU: %.2fs (mean %.2f, max %.2f of %v cores)", usage.CPUSeconds, usage.MeanCPUCores, usage.MaxCPUCores, usage.GOMAXPROCS)),
+		printer.LineAlignLeft(fmt.Sprintf("   Client RSS: %.1f MiB max, %v goroutines max", usage.MaxRSSBytes/(1<<20), usage.MaxGoroutines)),
+		printer.LineAlignLeft(fmt.Sprintf("    Client GC: %v cycles, %v paused, P99 scheduling latency %v", usage.GCCycles,
+			time.Duration(usage.GCPauseSeconds*float64(time.Second)).Round(time.Microsecond),
+			time.Duration(usage.SchedLatencyP99*float64(time.Second)).Round(time.Microsecond))),
+	}
+	for _, warning := range usage.SaturationWarnings() {
+		lines = append(lines, printer.LineAlignLeft("Client saturation likely: "+warning))
+	}
+	return lines
+}
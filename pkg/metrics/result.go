@@ -0,0 +1,280 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"runtime"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/options"
+)
+
+// ResultSchemaVersion is the version of the schema of result documents. It is bumped
+// whenever the shape of released result documents changes.
+const ResultSchemaVersion = 1
+
+// ResultDocument is the machine-readable result of a run, the stable format downstream
+// tools (e.g. comparisons, dashboards, trend tracking) consume.
+type ResultDocument struct {
+	// SchemaVersion is the version of the schema of the document.
+	SchemaVersion int `json:"schemaVersion"`
+	// Run is the metadata of the run.
+	Run RunMetadata `json:"run"`
+	// Options are the options that define the test matrix of the run.
+	Options RunOptions `json:"options"`
+	// Cells are the results of every test by the order they finished.
+	Cells []ResultCell `json:"cells"`
+	// Sensitivity are the fits of latency quantiles against the injected latency, by verb,
+	// percent and quantile.
+	Sensitivity []Sensitivity `json:"sensitivity,omitempty"`
+	// Baselines are the results of the baseline tests, which run without any IOChaos, by the
+	// order they finished.
+	Baselines []ResultCell `json:"baselines,omitempty"`
+	// BaselineDrift maps the names of the reported quantiles to the slowdown factors of verb
+	// `all` in the last baseline test relative to the first one, when the baseline is re-tested.
+	BaselineDrift map[string]float64 `json:"baselineDrift,omitempty"`
+}
+
+// RunMetadata is the metadata of a run.
+type RunMetadata struct {
+	// ID identifies the run.
+	ID string `json:"id"`
+	// Start is the time the run started.
+	Start time.Time `json:"start"`
+	// End is the time the run finished.
+	End time.Time `json:"end"`
+	// DurationSeconds is the duration of the run.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Cluster is the name of the cluster under test.
+	Cluster string `json:"cluster,omitempty"`
+	// Tags map the keys of the tags of the run to their values.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Hostname is the name of the host the run has been launched from.
+	Hostname string `json:"hostname,omitempty"`
+	// GoVersion is the version of Go the program has been built with.
+	GoVersion string `json:"goVersion"`
+}
+
+// RunOptions are the options that define the test matrix of a run, and how every test of
+// the matrix is measured.
+type RunOptions struct {
+	// Latencies are the latencies of the IOChaos.
+	Latencies []string `json:"latencies"`
+	// Percents are the percents of the IOChaos.
+	Percents []int `json:"percents"`
+	// Trials is the number of times every latency-percent pair is tested.
+	Trials int `json:"trials"`
+	// RandomizeTrials indicates whether trials are interleaved in a randomized order.
+	RandomizeTrials bool `json:"randomizeTrials"`
+	// Workers is the number of workers.
+	Workers int `json:"workers"`
+	// JobsPerWorker is the number of jobs done per worker.
+	JobsPerWorker int `json:"jobsPerWorker"`
+	// WarmUpRequests is the number of API requests of the warm-up period of every test.
+	WarmUpRequests int `json:"warmUpRequests"`
+	// WarmUpDurationSeconds is the length of the warm-up period of every test.
+	WarmUpDurationSeconds float64 `json:"warmUpDurationSeconds"`
+	// IncludeWarmUp indicates whether the API requests of the warm-up period are reported.
+	IncludeWarmUp bool `json:"includeWarmUp"`
+	// Baseline indicates whether the cluster is tested without any IOChaos first.
+	Baseline bool `json:"baseline"`
+	// BaselineRerun indicates whether the baseline is tested again at the end of the run.
+	BaselineRerun bool `json:"baselineRerun"`
+	// LatencyAccuracy is the relative accuracy of the recorded API request latencies.
+	LatencyAccuracy float64 `json:"latencyAccuracy"`
+	// Quantiles are the reported latency quantiles by ascending order.
+	Quantiles []float64 `json:"quantiles"`
+}
+
+// newRunOptions extracts the options that define the test matrix of a run.
+func newRunOptions(opts *options.Options) RunOptions {
+	return RunOptions{
+		Latencies:             opts.Latencies,
+		Percents:              opts.Percents,
+		Trials:                opts.Trials,
+		RandomizeTrials:       opts.RandomizeTrials,
+		Workers:               opts.WorkerNumber,
+		JobsPerWorker:         opts.JobsPerWorker,
+		WarmUpRequests:        opts.WarmUpRequests,
+		WarmUpDurationSeconds: opts.WarmUpDuration.Seconds(),
+		IncludeWarmUp:         opts.IncludeWarmUp,
+		Baseline:              opts.Baseline,
+		BaselineRerun:         opts.BaselineRerun,
+		LatencyAccuracy:       opts.LatencyAccuracy,
+		Quantiles:             SortedQuantiles,
+	}
+}
+
+// ResultCell is the result of a trial of a latency-percent pair.
+type ResultCell struct {
+	// Latency is the latency of the IOChaos.
+	Latency string `json:"latency"`
+	// Percent is the percent of the IOChaos.
+	Percent string `json:"percent"`
+	// Trial is the 1-based index of the trial, 0 when every latency-percent pair is tested once.
+	Trial int `json:"trial"`
+	// Start is the time the test started.
+	Start time.Time `json:"start"`
+	// End is the time the test finished.
+	End time.Time `json:"end"`
+	// DurationSeconds is the duration of the test.
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Verbs maps verbs to the results of their API requests.
+	Verbs map[string]ResultVerb `json:"verbs"`
+	// Client maps verbs to the client-side statistics of their API requests.
+	Client map[string]*ClientStats `json:"client,omitempty"`
+	// Workers are the statistics of every worker, when the per-worker breakdown is enabled.
+	Workers []*WorkerStats `json:"workers,omitempty"`
+	// Fairness indicates how evenly the workers have been served, when the per-worker
+	// breakdown is enabled.
+	Fairness *Fairness `json:"fairness,omitempty"`
+	// Server is the server-side statistics of the test, when kube-apiserver is scraped.
+	Server *ServerStats `json:"server,omitempty"`
+	// Etcd is the statistics reported by etcd members, when etcd members are scraped.
+	Etcd *EtcdStats `json:"etcd,omitempty"`
+	// Resources is the resource usage of the load generator, when it is monitored.
+	Resources *ResourceUsage `json:"resources,omitempty"`
+	// Slowdown maps verbs to the names of the reported quantiles to how many times slower
+	// the test is than the baseline test, when a baseline test has been run.
+	Slowdown map[string]map[string]float64 `json:"slowdown,omitempty"`
+}
+
+// ResultVerb is the result of the API requests of a verb in a test. Latencies are in seconds.
+type ResultVerb struct {
+	// Total is the number of API requests sent.
+	Total uint64 `json:"total"`
+	// Successful is the number of API requests that did not get an error response.
+	Successful uint64 `json:"successful"`
+	// Failed is the number of API requests that got an error response.
+	Failed uint64 `json:"failed"`
+	// SuccessRate is the percentage of successful API requests.
+	SuccessRate float64 `json:"successRate"`
+	// Throughput is the number of API requests sent per second.
+	Throughput float64 `json:"throughput"`
+	// Errors maps the reasons of failed API requests to their numbers.
+	Errors map[string]uint64 `json:"errors,omitempty"`
+	// Mean is the mean latency.
+	Mean float64 `json:"mean"`
+	// Min is the minimum latency.
+	Min float64 `json:"min"`
+	// Max is the maximum latency.
+	Max float64 `json:"max"`
+	// Quantiles maps the names of the reported quantiles (e.g. `P99`) to their latencies.
+	Quantiles map[string]float64 `json:"quantiles"`
+}
+
+// newResultVerb summarizes the statistics of the API requests of a verb during a test.
+func newResultVerb(stats *RequestStats, elapsed time.Duration) ResultVerb {
+	verb := ResultVerb{
+		Total:       stats.Total,
+		Successful:  stats.Successful,
+		Failed:      stats.Total - stats.Successful,
+		SuccessRate: stats.SuccessRate(),
+		Errors:      stats.Errors,
+		Mean:        stats.Latencies.Mean(),
+		Min:         stats.Latencies.Min(),
+		Max:         stats.Latencies.Max(),
+		Quantiles:   make(map[string]float64, len(SortedQuantiles)),
+	}
+	if elapsed > 0 {
+		verb.Throughput = float64(stats.Total) / elapsed.Seconds()
+	}
+	for _, quantile := range SortedQuantiles {
+		verb.Quantiles[QuantileName(quantile)] = stats.Latencies.Quantile(quantile)
+	}
+	return verb
+}
+
+// Result generates the result document of the run from every collected latency-percent pair.
+func (e *Exporter) Result(opts *options.Options, start, end time.Time) *ResultDocument {
+	hostname, _ := os.Hostname()
+	document := &ResultDocument{
+		SchemaVersion: ResultSchemaVersion,
+		Run: RunMetadata{
+			ID:              opts.RunID,
+			Start:           start,
+			End:             end,
+			DurationSeconds: end.Sub(start).Seconds(),
+			Cluster:         opts.Cluster,
+			Tags:            opts.Tags,
+			Hostname:        hostname,
+			GoVersion:       runtime.Version(),
+		},
+		Options:     newRunOptions(opts),
+		Cells:       make([]ResultCell, 0, len(e.cells)),
+		Sensitivity: e.Sensitivities(opts.SensitivityVerbs),
+	}
+	baseline := e.Baseline()
+	for _, cell := range e.baselines {
+		document.Baselines = append(document.Baselines, newResultCell(cell))
+	}
+	for _, cell := range e.cells {
+		resultCell := newResultCell(cell)
+		if baseline != nil {
+			resultCell.Slowdown = Slowdowns(&cell, baseline)
+		}
+		document.Cells = append(document.Cells, resultCell)
+	}
+	document.BaselineDrift, _ = e.Drift()
+	return document
+}
+
+// newResultCell summarizes the statistics of a test.
+func newResultCell(cell CellStats) ResultCell {
+	elapsed := cell.End.Sub(cell.Start)
+	resultCell := ResultCell{
+		Latency:         cell.Set.Latency,
+		Percent:         cell.Set.Percent,
+		Trial:           cell.Set.Trial,
+		Start:           cell.Start,
+		End:             cell.End,
+		DurationSeconds: elapsed.Seconds(),
+		Verbs:           make(map[string]ResultVerb, len(constants.Verbs)),
+		Client:          cell.Client,
+		Workers:         cell.Workers,
+		Fairness:        cell.Fairness,
+		Server:          cell.Server,
+		Etcd:            cell.Etcd,
+		Resources:       cell.Resources,
+	}
+	for _, verb := range constants.Verbs {
+		if stats, ok := cell.Verbs[verb]; ok {
+			resultCell.Verbs[verb] = newResultVerb(stats, elapsed)
+		}
+	}
+	return resultCell
+}
+
+// WriteResult exports the result document of the run to the target folder.
+func (e *Exporter) WriteResult(ctx context.Context, opts *options.Options, startTime, endTime time.Time) {
+	filepath := ExportFilePath(opts, startTime, "result.json")
+
+	klog.V(2).Infof("writing result document to %v", filepath)
+	if err := e.ExportResult(ctx, e.Result(opts, startTime, endTime), filepath); err != nil {
+		klog.Errorf("failed to export to file %v: %v", filepath, err.Error())
+		return
+	}
+	klog.V(2).Infof("successfully wrote result document to %v", filepath)
+}
+
+// ExportResult exports a result document to a JSON file.
+func (e *Exporter) ExportResult(ctx context.Context, document *ResultDocument, filepath string) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/klog/v2"
+)
+
+// Scrape is the set of metric families scraped from a Prometheus metrics endpoint, keyed
+// by metric name.
+type Scrape map[string]*dto.MetricFamily
+
+// ParseScrape parses metric families in the Prometheus text exposition format.
+func ParseScrape(reader io.Reader) (Scrape, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(reader)
+	if err != nil {
+		return nil, err
+	}
+	return families, nil
+}
+
+// ServerHistogram is the difference of a server-side Prometheus histogram between two
+// scrapes, i.e. the observations made between them.
+type ServerHistogram struct {
+	// Count is the number of observations.
+	Count uint64 `json:"count"`
+	// Sum is the sum of observations.
+	Sum float64 `json:"sum"`
+	// Buckets are the cumulative counts of observations by ascending upper bound. The
+	// `+Inf` bucket is omitted, its count is `Count`.
+	Buckets []ServerBucket `json:"buckets"`
+}
+
+// ServerBucket is a cumulative bucket of a server-side histogram.
+type ServerBucket struct {
+	// UpperBound is the inclusive upper bound of the bucket.
+	UpperBound float64 `json:"upperBound"`
+	// Count is the number of observations less than or equal to the upper bound.
+	Count uint64 `json:"count"`
+}
+
+// Mean returns the mean of observations, 0 if there is none.
+func (h *ServerHistogram) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / float64(h.Count)
+}
+
+// Quantile estimates a quantile of observations by linear interpolation within buckets,
+// the same way `histogram_quantile` of PromQL does. Quantiles beyond the greatest finite
+// upper bound are estimated as that upper bound.
+func (h *ServerHistogram) Quantile(quantile float64) float64 {
+	if h.Count == 0 || len(h.Buckets) == 0 {
+		return 0
+	}
+
+	rank := quantile * float64(h.Count)
+	lowerBound, lowerCount := 0.0, uint64(0)
+	for _, bucket := range h.Buckets {
+		if float64(bucket.Count) >= rank {
+			if bucket.Count == lowerCount {
+				return bucket.UpperBound
+			}
+			return lowerBound + (bucket.UpperBound-lowerBound)*(rank-float64(lowerCount))/float64(bucket.Count-lowerCount)
+		}
+		lowerBound, lowerCount = bucket.UpperBound, bucket.Count
+	}
+	return h.Buckets[len(h.Buckets)-1].UpperBound
+}
+
+// labelsOf returns the labels of a metric.
+func labelsOf(metric *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(metric.GetLabel()))
+	for _, pair := range metric.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	return labels
+}
+
+// histogramDeltas computes the difference of a histogram between two scrapes, summing the
+// series that match a filter by the value of a label. A nil filter matches every series.
+// Series that went backwards between the scrapes are reported, as kube-apiserver may have
+// restarted, or the scrapes may have hit different replicas of an HA control plane.
+func histogramDeltas(before, after Scrape, name, label string, filter func(labels map[string]string) bool) map[string]*ServerHistogram {
+	type accumulator struct {
+		count   float64
+		sum     float64
+		buckets map[float64]float64
+	}
+	accumulators := make(map[string]*accumulator)
+	// counts are the numbers of observations of the series in the first scrape, keyed by
+	// their labels.
+	counts := make(map[string]float64)
+	accumulate := func(scrape Scrape, sign float64) {
+		family, ok := scrape[name]
+		if !ok || family.GetType() != dto.MetricType_HISTOGRAM {
+			return
+		}
+		for _, metric := range family.GetMetric() {
+			labels := labelsOf(metric)
+			if filter != nil && !filter(labels) {
+				continue
+			}
+			acc, ok := accumulators[labels[label]]
+			if !ok {
+				acc = &accumulator{buckets: make(map[float64]float64)}
+				accumulators[labels[label]] = acc
+			}
+			histogram := metric.GetHistogram()
+			series := fmt.Sprint(labels)
+			if sign < 0 {
+				counts[series] = float64(histogram.GetSampleCount())
+			} else if count, ok := counts[series]; ok && float64(histogram.GetSampleCount()) < count {
+				klog.Warningf("Series %v of %v went backwards from %v to %v observations, kube-apiserver may have restarted or the scrapes may have hit different replicas", series, name, count, histogram.GetSampleCount())
+			}
+			acc.count += sign * float64(histogram.GetSampleCount())
+			acc.sum += sign * histogram.GetSampleSum()
+			for _, bucket := range histogram.GetBucket() {
+				if !math.IsInf(bucket.GetUpperBound(), 1) {
+					acc.buckets[bucket.GetUpperBound()] += sign * float64(bucket.GetCumulativeCount())
+				}
+			}
+		}
+	}
+	accumulate(before, -1)
+	accumulate(after, 1)
+
+	deltas := make(map[string]*ServerHistogram)
+	for group, acc := range accumulators {
+		// Skip series without new observations, and series that have been reset.
+		if acc.count <= 0 {
+			continue
+		}
+		histogram := &ServerHistogram{
+			Count: uint64(acc.count),
+			Sum:   acc.sum,
+		}
+		for upperBound, count := range acc.buckets {
+			histogram.Buckets = append(histogram.Buckets, ServerBucket{
+				UpperBound: upperBound,
+				Count:      uint64(math.Max(count, 0)),
+			})
+		}
+		sort.Slice(histogram.Buckets, func(i, j int) bool {
+			return histogram.Buckets[i].UpperBound < histogram.Buckets[j].UpperBound
+		})
+		deltas[group] = histogram
+	}
+	return deltas
+}
+
+// sampleValues returns the values of a counter or a gauge in a scrape, summing the series
+// by the value of a label.
+func sampleValues(scrape Scrape, name, label string) map[string]float64 {
+	values := make(map[string]float64)
+	family, ok := scrape[name]
+	if !ok {
+		return values
+	}
+	for _, metric := range family.GetMetric() {
+		group := labelsOf(metric)[label]
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			values[group] += metric.GetCounter().GetValue()
+		case dto.MetricType_GAUGE:
+			values[group] += metric.GetGauge().GetValue()
+		case dto.MetricType_UNTYPED:
+			values[group] += metric.GetUntyped().GetValue()
+		}
+	}
+	return values
+}
+
+// sortedGroups returns the groups of histogram deltas by ascending order.
+func sortedGroups(histograms map[string]*ServerHistogram) []string {
+	groups := make([]string, 0, len(histograms))
+	for group := range histograms {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
@@ -0,0 +1,266 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+const (
+	// kneeMinPoints is the minimum number of points on each side of a knee.
+	kneeMinPoints = 2
+	// kneeSlopeRatio is the minimum ratio of the slope after a knee to the slope before it.
+	kneeSlopeRatio = 2
+	// kneeMinImprovement is the minimum relative reduction of the squared residuals a fit
+	// broken at a knee must achieve over a single line.
+	kneeMinImprovement = 0.5
+)
+
+// Sensitivity is the linear fit of a latency quantile of a verb against the injected latency
+// at a percent, across the injected latencies that have been tested. Latencies are in seconds.
+type Sensitivity struct {
+	// Verb is the verb of the API requests.
+	Verb string `json:"verb"`
+	// Percent is the percent of the IOChaos.
+	Percent string `json:"percent"`
+	// Quantile is the latency quantile.
+	Quantile float64 `json:"quantile"`
+	// Points is the number of injected latencies the fit is based on.
+	Points int `json:"points"`
+	// Slope is the growth of the latency per unit of injected latency, e.g. 1.5 means the
+	// latency grows by 1.5ms per ms of injected latency.
+	Slope float64 `json:"slope"`
+	// Intercept is the latency without injected latency, as fitted.
+	Intercept float64 `json:"intercept"`
+	// RSquared is the coefficient of determination of the fit, 1 meaning the latency grows
+	// perfectly linearly.
+	RSquared float64 `json:"rSquared"`
+	// Knee is the injected latency from which the latency grows super-linearly, empty when
+	// no knee has been detected.
+	Knee string `json:"knee,omitempty"`
+	// SlopeBeforeKnee is the slope up to the knee.
+	SlopeBeforeKnee float64 `json:"slopeBeforeKnee,omitempty"`
+	// SlopeAfterKnee is the slope from the knee on.
+	SlopeAfterKnee float64 `json:"slopeAfterKnee,omitempty"`
+}
+
+// sensitivityPoint is a latency quantile at an injected latency, both in seconds.
+type sensitivityPoint struct {
+	delay   float64
+	latency float64
+	label   string
+}
+
+// Sensitivities fits every reported latency quantile of every given verb against the injected
+// latency, for every percent. Trials are merged, and latency-percent pairs that have not been
+// tested are left out. Nothing is fitted if any latency is not a duration.
+func (e *Exporter) Sensitivities(verbs []string) []Sensitivity {
+	delays := make([]float64, len(e.latencies))
+	for index, latency := range e.latencies {
+		duration, err := time.ParseDuration(latency)
+		if err != nil {
+			return nil
+		}
+		delays[index] = duration.Seconds()
+	}
+
+	var sensitivities []Sensitivity
+	for _, verb := range verbs {
+		merged := e.Merged(verb)
+		for percentIndex, percent := range e.percents {
+			for _, quantile := range SortedQuantiles {
+				var points []sensitivityPoint
+				for latencyIndex, stats := range merged[percentIndex] {
+					if stats == nil || stats.Latencies.Count() == 0 {
+						continue
+					}
+					points = append(points, sensitivityPoint{
+						delay:   delays[latencyIndex],
+						latency: stats.Latencies.Quantile(quantile),
+						label:   e.latencies[latencyIndex],
+					})
+				}
+				if sensitivity, ok := fitSensitivity(points); ok {
+					sensitivity.Verb, sensitivity.Percent, sensitivity.Quantile = verb, percent, quantile
+					sensitivities = append(sensitivities, sensitivity)
+				}
+			}
+		}
+	}
+	return sensitivities
+}
+
+// fitSensitivity fits the latencies of points against their injected latencies, and detects
+// the knee of the curve along ascending injected latencies, whatever the order of the points.
+// It fails when there are less than two distinct injected latencies.
+func fitSensitivity(points []sensitivityPoint) (Sensitivity, bool) {
+	sorted := append([]sensitivityPoint(nil), points...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].delay < sorted[j].delay
+	})
+	xs, ys := make([]float64, len(sorted)), make([]float64, len(sorted))
+	for index, point := range sorted {
+		xs[index], ys[index] = point.delay, point.latency
+	}
+
+	slope, intercept, rSquared, ok := linearFit(xs, ys)
+	if !ok {
+		return Sensitivity{}, false
+	}
+	sensitivity := Sensitivity{
+		Points:    len(xs),
+		Slope:     slope,
+		Intercept: intercept,
+		RSquared:  rSquared,
+	}
+	if index, before, after, ok := detectKnee(xs, ys); ok {
+		sensitivity.Knee = sorted[index].label
+		sensitivity.SlopeBeforeKnee = before
+		sensitivity.SlopeAfterKnee = after
+	}
+	return sensitivity, true
+}
+
+// linearFit fits `ys` against `xs` by ordinary least squares. It fails when there are less
+// than two distinct `xs`.
+func linearFit(xs, ys []float64) (slope, intercept, rSquared float64, ok bool) {
+	n := float64(len(xs))
+	if len(xs) < 2 {
+		return 0, 0, 0, false
+	}
+	var meanX, meanY float64
+	for index := range xs {
+		meanX += xs[index]
+		meanY += ys[index]
+	}
+	meanX, meanY = meanX/n, meanY/n
+
+	var sxx, sxy, syy float64
+	for index := range xs {
+		dx, dy := xs[index]-meanX, ys[index]-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0, 0, false
+	}
+	slope = sxy / sxx
+	intercept = meanY - slope*meanX
+	rSquared = 1
+	if syy > 0 {
+		rSquared = sxy * sxy / (sxx * syy)
+	}
+	return slope, intercept, rSquared, true
+}
+
+// squaredResiduals returns the sum of the squared residuals of a linear fit.
+func squaredResiduals(xs, ys []float64, slope, intercept float64) float64 {
+	var sum float64
+	for index := range xs {
+		residual := ys[index] - (slope*xs[index] + intercept)
+		sum += residual * residual
+	}
+	return sum
+}
+
+// detectKnee finds the point (by ascending `xs`) from which `ys` grow super-linearly: the
+// curve is broken into two lines sharing that point, the break minimizing the squared
+// residuals. It is a knee if the slope after it is at least `kneeSlopeRatio` times steeper
+// than before it, and the broken fit reduces the squared residuals of a single line by at
+// least `kneeMinImprovement`. It returns the index of the knee and the slopes on both sides.
+func detectKnee(xs, ys []float64) (int, float64, float64, bool) {
+	if len(xs) < 2*kneeMinPoints+1 {
+		return 0, 0, 0, false
+	}
+	slope, intercept, _, ok := linearFit(xs, ys)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	single := squaredResiduals(xs, ys, slope, intercept)
+
+	knee, best := -1, math.Inf(1)
+	var bestBefore, bestAfter float64
+	for index := kneeMinPoints; index < len(xs)-kneeMinPoints; index++ {
+		before, beforeIntercept, _, okBefore := linearFit(xs[:index+1], ys[:index+1])
+		after, afterIntercept, _, okAfter := linearFit(xs[index:], ys[index:])
+		if !okBefore || !okAfter {
+			continue
+		}
+		residuals := squaredResiduals(xs[:index+1], ys[:index+1], before, beforeIntercept) +
+			squaredResiduals(xs[index+1:], ys[index+1:], after, afterIntercept)
+		if residuals < best {
+			knee, best, bestBefore, bestAfter = index, residuals, before, after
+		}
+	}
+	if knee < 0 || bestAfter <= 0 || bestAfter < kneeSlopeRatio*math.Max(bestBefore, 0) {
+		return 0, 0, 0, false
+	}
+	if single == 0 || best > single*(1-kneeMinImprovement) {
+		return 0, 0, 0, false
+	}
+	return knee, bestBefore, bestAfter, true
+}
+
+// SensitivitySummary prints out how latencies grow with the injected latency.
+func SensitivitySummary(sensitivities []Sensitivity) {
+	if len(sensitivities) == 0 {
+		return
+	}
+	printer.PrintEmptyLine()
+	SensitivitySheet(sensitivities).Print()
+	printer.PrintEmptyLine()
+}
+
+// SensitivitySheet generates the sheet of how latencies grow with the injected latency, with
+// one table per verb and one row per percent and quantile.
+func SensitivitySheet(sensitivities []Sensitivity) *printer.Sheet {
+	sheet := printer.NewSheet(0, printer.LineAlignCenter("Latency Sensitivity to Injected Latency"))
+	sheet.SetHeader([]printer.Line{
+		printer.LineAlignLeft("Slope: growth of the latency per unit of injected latency (ms/ms)"),
+		printer.LineAlignLeft("Knee: injected latency from which the latency grows super-linearly"),
+	})
+
+	var (
+		tables    []printer.Table
+		verbs     []string
+		tableRows = make(map[string][]printer.TableRow)
+	)
+	for _, sensitivity := range sensitivities {
+		if _, ok := tableRows[sensitivity.Verb]; !ok {
+			verbs = append(verbs, sensitivity.Verb)
+		}
+		knee := "-"
+		if len(sensitivity.Knee) > 0 {
+			knee = fmt.Sprintf("%v (%.3f -> %.3f)", sensitivity.Knee, sensitivity.SlopeBeforeKnee, sensitivity.SlopeAfterKnee)
+		}
+		tableRows[sensitivity.Verb] = append(tableRows[sensitivity.Verb], printer.TableRow{
+			printer.LineAlignRight(sensitivity.Percent + "%"),
+			printer.LineAlignRight(QuantileName(sensitivity.Quantile)),
+			printer.LineAlignRight(fmt.Sprintf("%.3f", sensitivity.Slope)),
+			printer.LineAlignRight(fmt.Sprintf("%.5f", sensitivity.Intercept)),
+			printer.LineAlignRight(fmt.Sprintf("%.3f", sensitivity.RSquared)),
+			printer.LineAlignRight(knee),
+		})
+	}
+	for _, verb := range verbs {
+		headerRow := printer.TableRow{
+			printer.LineAlignRight("Percent"),
+			printer.LineAlignRight("Quantile"),
+			printer.LineAlignRight("Slope"),
+			printer.LineAlignRight("Intercept(s)"),
+			printer.LineAlignRight("R^2"),
+			printer.LineAlignRight("Knee"),
+		}
+		table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter(strings.ToUpper(verb)+" Latency Sensitivity"))
+		table.SetHeaders(headerRow)
+		table.SetDatum(tableRows[verb])
+		tables = append(tables, *table)
+	}
+	sheet.SetTables(tables)
+	return sheet
+}
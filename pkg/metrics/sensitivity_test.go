@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+// hockeyStick are latencies flat up to 30ms of injected latency, then growing by 10ms per
+// ms of injected latency.
+var hockeyStick = []sensitivityPoint{
+	{delay: 0, latency: 0.01, label: "0ms"},
+	{delay: 0.01, latency: 0.01, label: "10ms"},
+	{delay: 0.02, latency: 0.01, label: "20ms"},
+	{delay: 0.03, latency: 0.01, label: "30ms"},
+	{delay: 0.04, latency: 0.11, label: "40ms"},
+	{delay: 0.05, latency: 0.21, label: "50ms"},
+	{delay: 0.06, latency: 0.31, label: "60ms"},
+}
+
+// pointValues splits points into their injected latencies and their latencies.
+func pointValues(points []sensitivityPoint) ([]float64, []float64) {
+	xs, ys := make([]float64, len(points)), make([]float64, len(points))
+	for index, point := range points {
+		xs[index], ys[index] = point.delay, point.latency
+	}
+	return xs, ys
+}
+
+func TestLinearFit(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		xs, ys    []float64
+		slope     float64
+		intercept float64
+		rSquared  float64
+		ok        bool
+	}{
+		{name: "perfect line", xs: []float64{0, 1, 2, 3, 4}, ys: []float64{1, 3, 5, 7, 9}, slope: 2, intercept: 1, rSquared: 1, ok: true},
+		{name: "unsorted line", xs: []float64{3, 0, 4, 1, 2}, ys: []float64{7, 1, 9, 3, 5}, slope: 2, intercept: 1, rSquared: 1, ok: true},
+		{name: "flat", xs: []float64{0, 1, 2}, ys: []float64{5, 5, 5}, slope: 0, intercept: 5, rSquared: 1, ok: true},
+		{name: "noisy", xs: []float64{0, 1, 2, 3}, ys: []float64{0, 2, 1, 3}, slope: 0.8, intercept: 0.3, rSquared: 0.64, ok: true},
+		{name: "constant xs", xs: []float64{1, 1, 1}, ys: []float64{1, 2, 3}},
+		{name: "single point", xs: []float64{1}, ys: []float64{1}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			slope, intercept, rSquared, ok := linearFit(test.xs, test.ys)
+			if ok != test.ok {
+				t.Fatalf("got ok %v, want %v", ok, test.ok)
+			}
+			if math.Abs(slope-test.slope) > 1e-9 || math.Abs(intercept-test.intercept) > 1e-9 || math.Abs(rSquared-test.rSquared) > 1e-9 {
+				t.Errorf("got slope %v, intercept %v and R^2 %v, want %v, %v and %v", slope, intercept, rSquared, test.slope, test.intercept, test.rSquared)
+			}
+		})
+	}
+}
+
+func TestDetectKnee(t *testing.T) {
+	hockeyStickXs, hockeyStickYs := pointValues(hockeyStick)
+	for _, test := range []struct {
+		name   string
+		xs, ys []float64
+		knee   int
+		before float64
+		after  float64
+		ok     bool
+	}{
+		{name: "perfect line", xs: []float64{0, 1, 2, 3, 4, 5, 6}, ys: []float64{1, 3, 5, 7, 9, 11, 13}},
+		{name: "hockey stick", xs: hockeyStickXs, ys: hockeyStickYs, knee: 3, before: 0, after: 10, ok: true},
+		{name: "too few points", xs: []float64{0, 1, 2, 3}, ys: []float64{0, 0, 10, 20}},
+		{name: "constant xs", xs: []float64{1, 1, 1, 1, 1}, ys: []float64{0, 0, 0, 10, 20}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			knee, before, after, ok := detectKnee(test.xs, test.ys)
+			if ok != test.ok {
+				t.Fatalf("got ok %v, want %v", ok, test.ok)
+			}
+			if knee != test.knee || math.Abs(before-test.before) > 1e-9 || math.Abs(after-test.after) > 1e-9 {
+				t.Errorf("got knee %v with slopes %v and %v, want %v with %v and %v", knee, before, after, test.knee, test.before, test.after)
+			}
+		})
+	}
+}
+
+func TestFitSensitivityUnsortedPoints(t *testing.T) {
+	shuffled := []sensitivityPoint{hockeyStick[5], hockeyStick[0], hockeyStick[3], hockeyStick[6], hockeyStick[1], hockeyStick[4], hockeyStick[2]}
+	want, ok := fitSensitivity(hockeyStick)
+	if !ok {
+		t.Fatal("got no fit of sorted points, want one")
+	}
+	got, ok := fitSensitivity(shuffled)
+	if !ok {
+		t.Fatal("got no fit of unsorted points, want one")
+	}
+	if got.Knee != "30ms" || math.Abs(got.Slope-want.Slope) > 1e-9 || math.Abs(got.SlopeAfterKnee-want.SlopeAfterKnee) > 1e-9 {
+		t.Errorf("got fit %+v of unsorted points, want %+v with knee 30ms", got, want)
+	}
+}
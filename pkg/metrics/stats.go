@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"sync"
+)
+
+// RequestStats is the mergeable record of the API requests of a verb in a metric set.
+type RequestStats struct {
+	// Total is the number of API requests sent.
+	Total uint64 `json:"total"`
+	// Successful is the number of API requests that did not get an error response.
+	Successful uint64 `json:"successful"`
+	// Latencies is the latency histogram of all API requests.
+	Latencies *Histogram `json:"latencies"`
+	// Errors maps the reasons of failed API requests (e.g. `Timeout`) to their numbers.
+	Errors map[string]uint64 `json:"errors,omitempty"`
+}
+
+// NewRequestStats instantiates an empty RequestStats using the configured histogram accuracy.
+func NewRequestStats() *RequestStats {
+	latencies, _ := NewHistogram(relativeAccuracy)
+	return &RequestStats{
+		Latencies: latencies,
+	}
+}
+
+// SuccessRate returns the percentage of successful API requests, or 0 if no request was sent.
+func (s *RequestStats) SuccessRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Successful) * 100 / float64(s.Total)
+}
+
+// Merge adds all API requests recorded in another RequestStats into this one.
+func (s *RequestStats) Merge(other *RequestStats) error {
+	if other == nil {
+		return nil
+	}
+	if err := s.Latencies.Merge(other.Latencies); err != nil {
+		return err
+	}
+	s.Total += other.Total
+	s.Successful += other.Successful
+	for reason, count := range other.Errors {
+		s.countError(reason, count)
+	}
+	return nil
+}
+
+// Copy returns a deep copy of the RequestStats.
+func (s *RequestStats) Copy() *RequestStats {
+	stats := &RequestStats{
+		Total:      s.Total,
+		Successful: s.Successful,
+		Latencies:  s.Latencies.Copy(),
+	}
+	for reason, count := range s.Errors {
+		stats.countError(reason, count)
+	}
+	return stats
+}
+
+// countError adds failed API requests of a reason.
+func (s *RequestStats) countError(reason string, count uint64) {
+	if s.Errors == nil {
+		s.Errors = make(map[string]uint64)
+	}
+	s.Errors[reason] += count
+}
+
+// statsKey identifies the RequestStats of a verb in a metric set.
+type statsKey struct {
+	verb string
+	set  MetricSetID
+}
+
+// statsStore holds the RequestStats of every verb in every metric set. Unlike the
+// Prometheus registry, its contents never expire.
+type statsStore struct {
+	lock  sync.Mutex
+	stats map[statsKey]*RequestStats
+}
+
+// record stores a single API request in the store, the reason is the reason of its failure.
+func (s *statsStore) record(verb string, success bool, reason string, seconds float64, set MetricSetID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := statsKey{verb: verb, set: set}
+	stats, ok := s.stats[key]
+	if !ok {
+		stats = NewRequestStats()
+		s.stats[key] = stats
+	}
+	stats.Total++
+	if success {
+		stats.Successful++
+	} else {
+		stats.countError(reason, 1)
+	}
+	stats.Latencies.Observe(seconds)
+}
+
+// get returns a copy of the RequestStats of a verb in a metric set, which is
+// empty if no API request has been recorded for them.
+func (s *statsStore) get(verb string, set MetricSetID) *RequestStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats, ok := s.stats[statsKey{verb: verb, set: set}]
+	if !ok {
+		return NewRequestStats()
+	}
+	return stats.Copy()
+}
@@ -3,14 +3,27 @@ package metrics
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/nemoremold/perftests/pkg/constants"
 	"github.com/nemoremold/perftests/pkg/utils/printer"
 )
 
-// Summary prints out the analyzed result of the performance testing.
-func Summary(set MetricSetID, numberOfWorkers, numberOfJobs int, start, end time.Time) {
+// Summary prints out the analyzed result of the performance testing, along with the
+// server-side and etcd statistics if they have been scraped, the resource usage of the
+// load generator if it has been monitored, and the slowdown relative to a baseline test
+// if one is given.
+func Summary(cell CellStats, numberOfWorkers, numberOfJobs int, baseline *CellStats) {
+	sheet := SummarySheet(cell, numberOfWorkers, numberOfJobs, baseline)
+
+	// Print summary sheet.
+	printer.PrintEmptyLine()
+	sheet.Print()
+	printer.PrintEmptyLine()
+}
+
+// SummarySheet generates the summary sheet of a test, which `Summary` prints out.
+func SummarySheet(cell CellStats, numberOfWorkers, numberOfJobs int, baseline *CellStats) *printer.Sheet {
+	set, start, end := cell.Set, cell.Start, cell.End
 	// Prepare summary sheet.
 	sheet := printer.NewSheet(0, printer.LineAlignCenter("Performance Testing Summary"))
 
@@ -21,30 +34,61 @@ func Summary(set MetricSetID, numberOfWorkers, numberOfJobs int, start, end time
 			aligner = candidate
 		}
 	}
-	sheet.SetHeader([]printer.Line{
+	header := []printer.Line{
 		printer.LineAlignRight("Latency: " + fmt.Sprintf("%*v", aligner, set.Latency)),
 		printer.LineAlignRight("Percent: " + fmt.Sprintf("%*v", aligner, set.Percent)),
-		printer.LineAlignRight("Total number of workers: " + fmt.Sprintf("%*v", aligner, numberOfWorkers)),
-		printer.LineAlignRight("Jobs done per worker: " + fmt.Sprintf("%*v", aligner, numberOfJobs)),
-	})
+	}
+	if set.Trial > 0 {
+		header = append(header, printer.LineAlignRight("Trial: "+fmt.Sprintf("%*v", aligner, set.Trial)))
+	}
+	header = append(header,
+		printer.LineAlignRight("Total number of workers: "+fmt.Sprintf("%*v", aligner, numberOfWorkers)),
+		printer.LineAlignRight("Jobs done per worker: "+fmt.Sprintf("%*v", aligner, numberOfJobs)),
+	)
+	sheet.SetHeader(header)
 
 	// Prepare sheet footer.
-	sheet.SetFooter([]printer.Line{
+	footer := []printer.Line{
 		printer.LineAlignLeft("   Start time: " + start.Local().String()),
 		printer.LineAlignLeft("     End time: " + end.Local().String()),
 		printer.LineAlignLeft("Test duration: " + end.Sub(start).String()),
-	})
+	}
+	if warmUpRequests := collectWarmUpRequestStats(constants.ALL, set).Total; warmUpRequests > 0 {
+		treatment := "excluded from"
+		if warmUpIncluded() {
+			treatment = "included in"
+		}
+		footer = append(footer, printer.LineAlignLeft(fmt.Sprintf("Warm-up requests: %v (%v the tables)", warmUpRequests, treatment)))
+	}
+	if cell.Resources != nil {
+		footer = append(footer, resourceUsageFooter(cell.Resources)...)
+	}
+	sheet.SetFooter(footer)
 
 	// Prepare tables.
-	sheet.SetTables([]printer.Table{
+	tables := []printer.Table{
 		prepareSuccessRateTable(set),
 		prepareLatencyTable(set),
-	})
+	}
+	tables = append(tables, preparePlotTables(set)...)
+	if baseline != nil {
+		tables = append(tables, prepareSlowdownTable(&cell, baseline))
+	}
+	if workerStats := collectWorkerStats(set); len(workerStats) > 0 {
+		tables = append(tables, prepareWorkerTable(workerStats), prepareFairnessTable(ComputeFairness(workerStats)))
+	}
+	if clients.get(constants.ALL, set).Attempts > 0 {
+		tables = append(tables, prepareClientTable(set))
+	}
+	if cell.Etcd != nil {
+		tables = append(tables, prepareEtcdTable(cell.Etcd))
+	}
+	if cell.Server != nil {
+		tables = append(tables, prepareServerTables(cell.Server)...)
+	}
+	sheet.SetTables(tables)
 
-	// Print summary sheet.
-	printer.PrintEmptyLine()
-	sheet.Print()
-	printer.PrintEmptyLine()
+	return sheet
 }
 
 // prepareSuccessRateTable generates the success rate table.
@@ -74,7 +118,7 @@ func prepareSuccessRateTable(set MetricSetID) printer.Table {
 // prepareSuccessRateTableRow collects success rate related metrics from a specific
 // metric set and insert its values to a table row.
 func prepareSuccessRateTableRow(verb string, set MetricSetID) printer.TableRow {
-	allGets, allSuccessfulGets, percentage, _ := collectSuccessRateMetrics(verb, set)
+	allGets, allSuccessfulGets, percentage := collectSuccessRateMetrics(verb, set)
 
 	return printer.TableRow{
 		// Row index.
@@ -93,7 +137,7 @@ func prepareLatencyTable(set MetricSetID) printer.Table {
 		printer.LineAlignRight("Verb"),
 	}
 	for _, quantile := range SortedQuantiles {
-		headerRow.AddEntry(printer.LineAlignRight("P" + fmt.Sprint(int(quantile*100))))
+		headerRow.AddEntry(printer.LineAlignRight(QuantileName(quantile)))
 	}
 	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter("API Request Latency"))
 
@@ -113,11 +157,7 @@ func prepareLatencyTable(set MetricSetID) printer.Table {
 // prepareLatencyTableRow collects latency metrics from a specific metric set and
 // insert its values to a table row.
 func prepareLatencyTableRow(verb string, set MetricSetID) printer.TableRow {
-	metric, _ := collectLatencyMetric(verb, set)
-	quantileMap := make(map[float64]float64)
-	for _, quantile := range metric.Summary.GetQuantile() {
-		quantileMap[*quantile.Quantile] = *quantile.Value
-	}
+	latencies := collectRequestStats(verb, set).Latencies
 
 	// Set row index.
 	row := printer.TableRow{
@@ -125,7 +165,7 @@ func prepareLatencyTableRow(verb string, set MetricSetID) printer.TableRow {
 	}
 	// Set row values.
 	for _, quantile := range SortedQuantiles {
-		row.AddEntry(printer.LineAlignRight(fmt.Sprintf("%.5f", quantileMap[quantile])))
+		row.AddEntry(printer.LineAlignRight(fmt.Sprintf("%.5f", latencies.Quantile(quantile))))
 	}
 	return row
 }
@@ -0,0 +1,354 @@
+package metrics
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+)
+
+const (
+	// EventChaosInjected marks the time the IOChaos of a test has been injected.
+	EventChaosInjected = "iochaos-injected"
+	// EventChaosRemoved marks the time the IOChaos of a test has been removed.
+	EventChaosRemoved = "iochaos-removed"
+	// EventTestStarted marks the time the workers started sending API requests.
+	EventTestStarted = "test-started"
+	// EventTestFinished marks the time the workers finished sending API requests.
+	EventTestFinished = "test-finished"
+	// EventWarmUpFinished marks the time the warm-up period of a test ended.
+	EventWarmUpFinished = "warmup-finished"
+)
+
+var (
+	// series keeps the time series of every test.
+	series = &timeSeriesStore{
+		series: make(map[MetricSetID]*TimeSeries),
+	}
+)
+
+// TimeSeries records API requests of a test in fixed-length intervals, along with the
+// events (e.g. IOChaos injection and removal) that happened during the test.
+type TimeSeries struct {
+	lock sync.Mutex
+
+	// set identifies the test.
+	set MetricSetID
+	// start is the beginning of the first interval.
+	start time.Time
+	// interval is the length of every interval.
+	interval time.Duration
+	// points are the API requests recorded in every interval, the item index is the
+	// interval index.
+	points []map[string]*RequestStats
+	// events are the events that happened during the test by chronological order.
+	events []Event
+}
+
+// Event is something that happened at a point of the timeline.
+type Event struct {
+	// Name is the name of the event.
+	Name string `json:"name"`
+	// Time is when the event happened.
+	Time time.Time `json:"time"`
+}
+
+// timeSeriesStore holds the time series of every test.
+type timeSeriesStore struct {
+	lock   sync.Mutex
+	series map[MetricSetID]*TimeSeries
+}
+
+// StartTimeSeries starts recording the API requests of a test in intervals of a given
+// length, the timeline starts now.
+func StartTimeSeries(set MetricSetID, interval time.Duration) *TimeSeries {
+	ts := &TimeSeries{
+		set:      set,
+		start:    time.Now(),
+		interval: interval,
+	}
+
+	series.lock.Lock()
+	defer series.lock.Unlock()
+	series.series[set] = ts
+	return ts
+}
+
+// GetTimeSeries returns the time series of a test, or nil if it has not been started.
+func GetTimeSeries(set MetricSetID) *TimeSeries {
+	series.lock.Lock()
+	defer series.lock.Unlock()
+	return series.series[set]
+}
+
+// StopTimeSeries stops recording the API requests of a test, dropping its time series.
+func StopTimeSeries(set MetricSetID) {
+	series.lock.Lock()
+	defer series.lock.Unlock()
+	delete(series.series, set)
+}
+
+// MarkEvent marks an event that happens now on the timeline of a test. It does nothing
+// if the time series of the test has not been started.
+func MarkEvent(set MetricSetID, name string) {
+	if ts := GetTimeSeries(set); ts != nil {
+		ts.lock.Lock()
+		defer ts.lock.Unlock()
+		ts.events = append(ts.events, Event{Name: name, Time: time.Now()})
+	}
+}
+
+// recordInTimeSeries records an API request that finishes now in the time series of its
+// test, if the time series has been started.
+func recordInTimeSeries(verb string, success bool, seconds float64, set MetricSetID) {
+	if ts := GetTimeSeries(set); ts != nil {
+		ts.record(verb, success, seconds, time.Now())
+	}
+}
+
+// record records an API request that finishes at a given time.
+func (ts *TimeSeries) record(verb string, success bool, seconds float64, at time.Time) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	index := ts.indexOf(at)
+	for len(ts.points) <= index {
+		ts.points = append(ts.points, make(map[string]*RequestStats))
+	}
+	stats, ok := ts.points[index][verb]
+	if !ok {
+		stats = NewRequestStats()
+		ts.points[index][verb] = stats
+	}
+	stats.Total++
+	if success {
+		stats.Successful++
+	}
+	stats.Latencies.Observe(seconds)
+}
+
+// indexOf returns the index of the interval a given time falls into.
+func (ts *TimeSeries) indexOf(at time.Time) int {
+	if at.Before(ts.start) {
+		return 0
+	}
+	return int(at.Sub(ts.start) / ts.interval)
+}
+
+// Start returns the beginning of the timeline.
+func (ts *TimeSeries) Start() time.Time {
+	return ts.start
+}
+
+// Interval returns the length of every interval.
+func (ts *TimeSeries) Interval() time.Duration {
+	return ts.interval
+}
+
+// Events returns the events that happened during the test by chronological order.
+func (ts *TimeSeries) Events() []Event {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	return append([]Event(nil), ts.events...)
+}
+
+// Points returns a copy of the API requests recorded in every interval. The timeline
+// spans until the last recorded API request or event, whichever comes later.
+func (ts *TimeSeries) Points() []map[string]*RequestStats {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	length := len(ts.points)
+	for _, event := range ts.events {
+		if index := ts.indexOf(event.Time); index >= length {
+			length = index + 1
+		}
+	}
+
+	points := make([]map[string]*RequestStats, length)
+	for index := range points {
+		points[index] = make(map[string]*RequestStats)
+		if index < len(ts.points) {
+			for verb, stats := range ts.points[index] {
+				points[index][verb] = stats.Copy()
+			}
+		}
+	}
+	return points
+}
+
+// eventsByInterval groups the names of events by the index of the interval they happened in.
+func (ts *TimeSeries) eventsByInterval() map[int][]string {
+	grouped := make(map[int][]string)
+	for _, event := range ts.Events() {
+		index := ts.indexOf(event.Time)
+		grouped[index] = append(grouped[index], event.Name)
+	}
+	return grouped
+}
+
+// recordedVerbs returns the verbs that have been recorded in any of the points, ordered
+// as in `constants.Verbs`.
+func recordedVerbs(points []map[string]*RequestStats) []string {
+	var verbs []string
+	for _, verb := range constants.Verbs {
+		for _, point := range points {
+			if _, ok := point[verb]; ok {
+				verbs = append(verbs, verb)
+				break
+			}
+		}
+	}
+	return verbs
+}
+
+// ExportCSV exports the time series to a CSV file, with one row per interval and verb.
+// Events are listed in the row of every verb of the interval they happened in.
+func (ts *TimeSeries) ExportCSV(ctx context.Context, filepath string) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+
+	writer := csv.NewWriter(file)
+	header := rowData{"Time", "Offset(s)", "Verb", "Requests", "Errors", "Throughput(req/s)"}
+	for _, quantile := range SortedQuantiles {
+		header = append(header, QuantileName(quantile))
+	}
+	header = append(header, "Events")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	points := ts.Points()
+	verbs := recordedVerbs(points)
+	events := ts.eventsByInterval()
+	for index, point := range points {
+		intervalStart := ts.start.Add(time.Duration(index) * ts.interval)
+		rowVerbs := verbs
+		if len(rowVerbs) == 0 {
+			// Keep the events of intervals without any API request on the timeline.
+			rowVerbs = []string{constants.ALL}
+		}
+		for _, verb := range rowVerbs {
+			stats, ok := point[verb]
+			if !ok {
+				stats = NewRequestStats()
+			}
+			row := rowData{
+				intervalStart.Local().Format(time.RFC3339Nano),
+				fmt.Sprintf("%.3f", intervalStart.Sub(ts.start).Seconds()),
+				verb,
+				fmt.Sprint(stats.Total),
+				fmt.Sprint(stats.Total - stats.Successful),
+				fmt.Sprintf("%.2f", float64(stats.Total)/ts.interval.Seconds()),
+			}
+			for _, quantile := range SortedQuantiles {
+				row = append(row, fmt.Sprintf("%.10f", stats.Latencies.Quantile(quantile)))
+			}
+			row = append(row, strings.Join(events[index], ";"))
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// timeSeriesJSON is the serialized form of a time series.
+type timeSeriesJSON struct {
+	Set             MetricSetID           `json:"set"`
+	Start           time.Time             `json:"start"`
+	IntervalSeconds float64               `json:"intervalSeconds"`
+	Events          []timeSeriesEventJSON `json:"events"`
+	Points          []timeSeriesPointJSON `json:"points"`
+}
+
+// timeSeriesEventJSON is the serialized form of an event on the timeline.
+type timeSeriesEventJSON struct {
+	Event
+	OffsetSeconds float64 `json:"offsetSeconds"`
+}
+
+// timeSeriesPointJSON is the serialized form of an interval of the time series.
+type timeSeriesPointJSON struct {
+	OffsetSeconds float64                       `json:"offsetSeconds"`
+	Verbs         map[string]timeSeriesVerbJSON `json:"verbs"`
+}
+
+// timeSeriesVerbJSON is the serialized form of the API requests of a verb in an interval.
+// The latency histogram is kept so that intervals can be merged again (e.g. by comparisons).
+type timeSeriesVerbJSON struct {
+	Requests   uint64             `json:"requests"`
+	Errors     uint64             `json:"errors"`
+	Throughput float64            `json:"throughput"`
+	Quantiles  map[string]float64 `json:"quantiles"`
+	Histogram  *Histogram         `json:"histogram"`
+}
+
+// ExportJSON exports the time series to a JSON file.
+func (ts *TimeSeries) ExportJSON(ctx context.Context, filepath string) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+
+	document := timeSeriesJSON{
+		Set:             ts.set,
+		Start:           ts.start,
+		IntervalSeconds: ts.interval.Seconds(),
+	}
+	for _, event := range ts.Events() {
+		document.Events = append(document.Events, timeSeriesEventJSON{
+			Event:         event,
+			OffsetSeconds: event.Time.Sub(ts.start).Seconds(),
+		})
+	}
+	sort.SliceStable(document.Events, func(i, j int) bool {
+		return document.Events[i].Time.Before(document.Events[j].Time)
+	})
+	for index, point := range ts.Points() {
+		serialized := timeSeriesPointJSON{
+			OffsetSeconds: (time.Duration(index) * ts.interval).Seconds(),
+			Verbs:         make(map[string]timeSeriesVerbJSON),
+		}
+		for verb, stats := range point {
+			quantiles := make(map[string]float64)
+			for _, quantile := range SortedQuantiles {
+				quantiles[QuantileName(quantile)] = stats.Latencies.Quantile(quantile)
+			}
+			serialized.Verbs[verb] = timeSeriesVerbJSON{
+				Requests:   stats.Total,
+				Errors:     stats.Total - stats.Successful,
+				Throughput: float64(stats.Total) / ts.interval.Seconds(),
+				Quantiles:  quantiles,
+				Histogram:  stats.Latencies,
+			}
+		}
+		document.Points = append(document.Points, serialized)
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
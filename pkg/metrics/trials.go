@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+var (
+	// tQuantiles are the 0.975 quantiles of Student's t-distribution by degrees of freedom
+	// (item index plus one), used for 95% confidence intervals of small samples.
+	tQuantiles = []float64{
+		12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+	}
+)
+
+// TrialSummary summarizes the values of a metric across trials.
+type TrialSummary struct {
+	// Trials is the number of trials.
+	Trials int `json:"trials"`
+	// Mean is the mean of the values.
+	Mean float64 `json:"mean"`
+	// StdDev is the sample standard deviation of the values.
+	StdDev float64 `json:"stddev"`
+	// CILow is the lower bound of the 95% confidence interval of the mean.
+	CILow float64 `json:"ciLow"`
+	// CIHigh is the upper bound of the 95% confidence interval of the mean.
+	CIHigh float64 `json:"ciHigh"`
+}
+
+// SummarizeTrials computes the mean, standard deviation and 95% confidence interval of
+// the mean of a set of values. The standard deviation and the confidence interval are
+// 0 for a single value.
+func SummarizeTrials(values []float64) TrialSummary {
+	summary := TrialSummary{Trials: len(values)}
+	if len(values) == 0 {
+		return summary
+	}
+
+	for _, value := range values {
+		summary.Mean += value
+	}
+	summary.Mean /= float64(len(values))
+	summary.CILow, summary.CIHigh = summary.Mean, summary.Mean
+	if len(values) == 1 {
+		return summary
+	}
+
+	for _, value := range values {
+		summary.StdDev += (value - summary.Mean) * (value - summary.Mean)
+	}
+	summary.StdDev = math.Sqrt(summary.StdDev / float64(len(values)-1))
+
+	t := 1.96
+	if degrees := len(values) - 1; degrees <= len(tQuantiles) {
+		t = tQuantiles[degrees-1]
+	}
+	halfWidth := t * summary.StdDev / math.Sqrt(float64(len(values)))
+	summary.CILow, summary.CIHigh = summary.Mean-halfWidth, summary.Mean+halfWidth
+	return summary
+}
+
+// summarizeQuantile summarizes a latency quantile of a verb across trials.
+func summarizeQuantile(trials []CellStats, verb string, quantile float64) TrialSummary {
+	var values []float64
+	for _, trial := range trials {
+		if stats, ok := trial.Verbs[verb]; ok {
+			values = append(values, stats.Latencies.Quantile(quantile))
+		}
+	}
+	return SummarizeTrials(values)
+}
+
+// summarizeSuccessRate summarizes the success rate of a verb across trials.
+func summarizeSuccessRate(trials []CellStats, verb string) TrialSummary {
+	var values []float64
+	for _, trial := range trials {
+		if stats, ok := trial.Verbs[verb]; ok {
+			values = append(values, stats.SuccessRate())
+		}
+	}
+	return SummarizeTrials(values)
+}
+
+// TrialsSummary prints out the analyzed result of every trial of a latency-percent pair,
+// reporting the mean and the 95% confidence interval of every metric across trials.
+func TrialsSummary(trials []CellStats, numberOfWorkers, numberOfJobs int) {
+	if len(trials) == 0 {
+		return
+	}
+	set := trials[0].Set
+
+	sheet := printer.NewSheet(0, printer.LineAlignCenter("Performance Testing Summary Across Trials"))
+	sheet.SetHeader([]printer.Line{
+		printer.LineAlignRight("Latency: " + set.Latency),
+		printer.LineAlignRight("Percent: " + set.Percent),
+		printer.LineAlignRight(fmt.Sprintf("Trials: %v", len(trials))),
+		printer.LineAlignRight(fmt.Sprintf("Total number of workers: %v", numberOfWorkers)),
+		printer.LineAlignRight(fmt.Sprintf("Jobs done per worker: %v", numberOfJobs)),
+	})
+
+	var (
+		start, end    time.Time
+		totalDuration time.Duration
+	)
+	for _, trial := range trials {
+		if start.IsZero() || trial.Start.Before(start) {
+			start = trial.Start
+		}
+		if trial.End.After(end) {
+			end = trial.End
+		}
+		totalDuration += trial.End.Sub(trial.Start)
+	}
+	sheet.SetFooter([]printer.Line{
+		printer.LineAlignLeft("         First trial started: " + start.Local().String()),
+		printer.LineAlignLeft("         Last trial finished: " + end.Local().String()),
+		printer.LineAlignLeft("Mean test duration per trial: " + (totalDuration / time.Duration(len(trials))).String()),
+	})
+
+	// Success rate table.
+	successRateHeader := printer.TableRow{
+		printer.LineAlignRight("Verb"),
+		printer.LineAlignRight("Mean"),
+		printer.LineAlignRight("StdDev"),
+		printer.LineAlignRight("95% CI"),
+	}
+	successRateTable := printer.NewTable(0, successRateHeader.ColumnsCount(), printer.LineAlignCenter("API Request Success Rate"))
+	successRateTable.SetHeaders(successRateHeader)
+	var successRateRows []printer.TableRow
+	for _, verb := range constants.Verbs {
+		summary := summarizeSuccessRate(trials, verb)
+		successRateRows = append(successRateRows, printer.TableRow{
+			printer.LineAlignRight(strings.ToUpper(verb)),
+			printer.LineAlignRight(fmt.Sprintf("%.2f", summary.Mean)),
+			printer.LineAlignRight(fmt.Sprintf("%.2f", summary.StdDev)),
+			printer.LineAlignRight(fmt.Sprintf("[%.2f, %.2f]", summary.CILow, summary.CIHigh)),
+		})
+	}
+	successRateTable.SetDatum(successRateRows)
+
+	// Latency table.
+	latencyHeader := printer.TableRow{
+		printer.LineAlignRight("Verb"),
+	}
+	for _, quantile := range SortedQuantiles {
+		latencyHeader.AddEntry(printer.LineAlignRight(QuantileName(quantile)))
+	}
+	latencyTable := printer.NewTable(0, latencyHeader.ColumnsCount(), printer.LineAlignCenter("API Request Latency (mean +/- 95% CI half-width, stddev)"))
+	latencyTable.SetHeaders(latencyHeader)
+	var latencyRows []printer.TableRow
+	for _, verb := range constants.Verbs {
+		row := printer.TableRow{
+			printer.LineAlignRight(strings.ToUpper(verb)),
+		}
+		for _, quantile := range SortedQuantiles {
+			summary := summarizeQuantile(trials, verb, quantile)
+			row.AddEntry(printer.LineAlignRight(fmt.Sprintf("%.5f+/-%.5f, %.5f", summary.Mean, summary.CIHigh-summary.Mean, summary.StdDev)))
+		}
+		latencyRows = append(latencyRows, row)
+	}
+	latencyTable.SetDatum(latencyRows)
+
+	sheet.SetTables([]printer.Table{
+		*successRateTable,
+		*latencyTable,
+	})
+
+	printer.PrintEmptyLine()
+	sheet.Print()
+	printer.PrintEmptyLine()
+}
@@ -1,36 +1,40 @@
 package metrics
 
 import (
+	"fmt"
+	"math"
 	"sort"
-	"time"
+	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 var (
-	// SummaryObjectives defines the quantiles and their respective
-	// absolute error.
-	SummaryObjectives = map[float64]float64{
-		0.1:  0.001,
-		0.25: 0.001,
-		0.5:  0.001,
-		0.75: 0.001,
-		0.9:  0.001,
-		0.95: 0.001,
-		0.99: 0.001,
-	}
+	// DefaultQuantiles are the quantiles reported by default, quantile 1 being the maximum.
+	DefaultQuantiles = []float64{0.1, 0.25, 0.5, 0.75, 0.9, 0.95, 0.99, 0.999, 1}
 
-	// SortedQuantiles is the sorted array of summary objectives.
+	// SortedQuantiles is the sorted array of reported quantiles.
 	SortedQuantiles []float64
 
-	registry *prometheus.Registry
+	// relativeAccuracy is the relative accuracy of the latency histograms.
+	relativeAccuracy = DefaultRelativeAccuracy
+
+	// stats keeps the never-expiring statistics the reports are generated from.
+	stats = &statsStore{
+		stats: make(map[statsKey]*RequestStats),
+	}
+
+	// registry is initialized along with package variables, so that the metrics defined
+	// in every file can be registered in their `init` functions.
+	registry = prometheus.NewRegistry()
 
 	totalAPIRequests = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "total_api_requests",
 			Help: "Total API requests sent from workers to kube-apiserver during performance testing",
 		},
-		[]string{"verb", "latency", "percent"},
+		[]string{"verb", "latency", "percent", "phase"},
 	)
 
 	successfulAPIRequests = prometheus.NewCounterVec(
@@ -38,38 +42,115 @@ var (
 			Name: "successful_api_requests",
 			Help: "API requests sent from workers to kube-apiserver during performance testing that does not get error response",
 		},
-		[]string{"verb", "latency", "percent"},
+		[]string{"verb", "latency", "percent", "phase"},
+	)
+
+	failedAPIRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "failed_api_requests",
+			Help: "API requests sent from workers to kube-apiserver during performance testing that get error response, partitioned by reason",
+		},
+		[]string{"verb", "latency", "percent", "phase", "reason"},
 	)
 
-	apiRequestLatencies = prometheus.NewSummaryVec(
-		prometheus.SummaryOpts{
-			Name:       "api_request_latencies",
-			Help:       "The latency of API requests sent from workers to kube-apiserver during performance testing",
-			Objectives: SummaryObjectives,
-			MaxAge:     60 * time.Minute, // Set a longer MaxAge because some test cases may take longer to finish.
+	apiRequestLatencies = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "api_request_latencies",
+			Help:    "The latency of API requests sent from workers to kube-apiserver during performance testing",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 16), // 1ms to ~32s.
 		},
-		[]string{"verb", "latency", "percent"},
+		[]string{"verb", "latency", "percent", "phase"},
 	)
 )
 
 func init() {
-	registry = prometheus.NewRegistry()
-
+	registry.MustRegister(collectors.NewGoCollector())
+	registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 	registry.MustRegister(totalAPIRequests)
 	registry.MustRegister(successfulAPIRequests)
+	registry.MustRegister(failedAPIRequests)
 	registry.MustRegister(apiRequestLatencies)
 
-	SortedQuantiles = make([]float64, 0)
-	for quantile := range SummaryObjectives {
-		SortedQuantiles = append(SortedQuantiles, quantile)
+	SortedQuantiles = append([]float64(nil), DefaultQuantiles...)
+}
+
+// Configure sets the relative accuracy of the latency histograms and the quantiles to
+// be reported. It must be called before any API request is recorded.
+func Configure(accuracy float64, quantiles []float64) error {
+	if _, err := NewHistogram(accuracy); err != nil {
+		return err
+	}
+	for _, quantile := range quantiles {
+		if quantile < 0 || quantile > 1 {
+			return fmt.Errorf("%v is not a valid quantile (should be in range [0, 1])", quantile)
+		}
+	}
+
+	relativeAccuracy = accuracy
+	if len(quantiles) > 0 {
+		SortedQuantiles = append([]float64(nil), quantiles...)
+		sort.Float64s(SortedQuantiles)
 	}
-	sort.Float64s(SortedQuantiles)
+	return nil
+}
+
+// QuantileName returns the display name of a quantile, e.g. `P99.9` for 0.999 and
+// `Max` for 1.
+func QuantileName(quantile float64) string {
+	if quantile >= 1 {
+		return "Max"
+	}
+	return "P" + strconv.FormatFloat(math.Round(quantile*1e6)/1e4, 'f', -1, 64)
 }
 
 // MetricSetID groups the metrics by latency label and percent label.
 type MetricSetID struct {
 	// Latency is the value of latency label.
-	Latency string
+	Latency string `json:"latency"`
 	// Percent is the value of percent label.
-	Percent string
+	Percent string `json:"percent"`
+	// Trial is the 1-based index of the trial when every latency-percent pair is tested
+	// several times, 0 otherwise.
+	Trial int `json:"trial,omitempty"`
+	// Phase is `PhaseWarmUp` for the API requests sent during the warm-up period of the
+	// test, empty otherwise.
+	Phase string `json:"phase,omitempty"`
+}
+
+// Cell returns the identity of the latency-percent pair, regardless of the trial and
+// the phase.
+func (s MetricSetID) Cell() MetricSetID {
+	return MetricSetID{
+		Latency: s.Latency,
+		Percent: s.Percent,
+	}
+}
+
+// String returns a human-readable description of the metric set.
+func (s MetricSetID) String() string {
+	description := fmt.Sprintf("latency: %v, percent: %v", s.Latency, s.Percent)
+	if s.Trial > 0 {
+		description += fmt.Sprintf(", trial: %v", s.Trial)
+	}
+	if len(s.Phase) > 0 {
+		description += fmt.Sprintf(", phase: %v", s.Phase)
+	}
+	return description
+}
+
+// phaseLabel returns the value of the phase label of the API requests of the metric set.
+func (s MetricSetID) phaseLabel() string {
+	if s.Phase == PhaseWarmUp {
+		return PhaseWarmUp
+	}
+	return PhaseMeasured
+}
+
+// trialLabel returns the value of the trial label of the metric set, empty when every
+// latency-percent pair is tested once.
+func (s MetricSetID) trialLabel() string {
+	if s.Trial == 0 {
+		return ""
+	}
+	return strconv.Itoa(s.Trial)
 }
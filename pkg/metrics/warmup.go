@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// PhaseWarmUp is the phase of API requests sent during the warm-up period of a test.
+	PhaseWarmUp = "warmup"
+	// PhaseMeasured is the phase of API requests sent after the warm-up period of a test.
+	PhaseMeasured = "measured"
+)
+
+var (
+	// warmUp decides which API requests of the test currently running belong to its
+	// warm-up period.
+	warmUp = &warmUpGate{}
+)
+
+// warmUpGate tracks the warm-up period of the test currently running. The warm-up period
+// lasts until both the configured number of API requests have been sent and the configured
+// duration has elapsed.
+type warmUpGate struct {
+	lock sync.Mutex
+
+	// requests is the number of API requests of the warm-up period of every test.
+	requests uint64
+	// duration is the length of the warm-up period of every test.
+	duration time.Duration
+	// included is `true` when warm-up requests are included in reports.
+	included bool
+
+	// set is the metric set of the test currently warming up.
+	set MetricSetID
+	// active is `true` while the test is warming up.
+	active bool
+	// sent is the number of API requests the test has sent while warming up.
+	sent uint64
+	// deadline is the time the warm-up duration elapses.
+	deadline time.Time
+	// finished is the time the warm-up period of the test ended, zero until it has ended.
+	finished time.Time
+}
+
+// ConfigureWarmUp sets the warm-up period of every test, by number of API requests and by
+// duration. The first API requests of a test are recorded as warm-up requests until both
+// have been reached, and are excluded from reports unless `included` is `true`.
+func ConfigureWarmUp(requests uint64, duration time.Duration, included bool) {
+	warmUp.lock.Lock()
+	defer warmUp.lock.Unlock()
+	warmUp.requests = requests
+	warmUp.duration = duration
+	warmUp.included = included
+}
+
+// StartWarmUp starts the warm-up period of a test, it does nothing if no warm-up period
+// is configured.
+func StartWarmUp(set MetricSetID) {
+	warmUp.lock.Lock()
+	defer warmUp.lock.Unlock()
+	warmUp.set = set
+	warmUp.active = warmUp.requests > 0 || warmUp.duration > 0
+	warmUp.sent = 0
+	warmUp.deadline = time.Now().Add(warmUp.duration)
+	warmUp.finished = time.Time{}
+}
+
+// admit returns `true` if an API request of a metric set belongs to the warm-up period of
+// its test, ending the warm-up period once it is over.
+func (g *warmUpGate) admit(set MetricSetID) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if !g.active || g.set != set {
+		return false
+	}
+
+	if g.sent >= g.requests && !time.Now().Before(g.deadline) {
+		g.active = false
+		g.finished = time.Now()
+		MarkEvent(set, EventWarmUpFinished)
+		return false
+	}
+	g.sent++
+	return true
+}
+
+// warmingUp returns `true` if the test of a metric set is in its warm-up period, without
+// counting an API request. An API request sent while the test is warming up may still be
+// the one ending its warm-up period when it is recorded.
+func (g *warmUpGate) warmingUp(set MetricSetID) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.active && g.set == set
+}
+
+// measuredSince returns the time the measured period of the test of a metric set started
+// for a worker which started the test at `start`: the end of the warm-up period unless
+// warm-up requests are included in reports.
+func (g *warmUpGate) measuredSince(set MetricSetID, start time.Time) time.Time {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.included || g.set != set || g.finished.Before(start) {
+		return start
+	}
+	return g.finished
+}
+
+// warmUpIncluded returns `true` when warm-up requests are included in reports.
+func warmUpIncluded() bool {
+	warmUp.lock.Lock()
+	defer warmUp.lock.Unlock()
+	return warmUp.included
+}
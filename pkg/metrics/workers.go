@@ -0,0 +1,304 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog/v2"
+
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+var (
+	// workers keeps the statistics of every worker when the per-worker breakdown is enabled.
+	workers = &workerStatsStore{
+		stats: make(map[workerKey]*WorkerStats),
+	}
+
+	workerAPIRequestLatencies = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "worker_api_request_latencies",
+			Help:    "The latency of API requests sent by every worker during performance testing, only recorded when the per-worker breakdown is enabled",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 16), // 1ms to ~32s.
+		},
+		[]string{"worker", "latency", "percent", "trial", "phase"},
+	)
+)
+
+func init() {
+	registry.MustRegister(workerAPIRequestLatencies)
+}
+
+// WorkerStats holds the statistics of the API requests a worker sent during a test.
+type WorkerStats struct {
+	// ID is the identity number of the worker.
+	ID int `json:"id"`
+	// Requests is the statistics of the API requests of every verb.
+	Requests *RequestStats `json:"requests"`
+	// Start is the time the worker started the test, or the time the warm-up period of the
+	// test ended unless warm-up requests are included.
+	Start time.Time `json:"start"`
+	// End is the time the worker finished the test.
+	End time.Time `json:"end"`
+}
+
+// Elapsed returns the time the worker spent on the test.
+func (s *WorkerStats) Elapsed() time.Duration {
+	if s.Start.IsZero() || s.End.Before(s.Start) {
+		return 0
+	}
+	return s.End.Sub(s.Start)
+}
+
+// Throughput returns the number of API requests the worker completed per second.
+func (s *WorkerStats) Throughput() float64 {
+	elapsed := s.Elapsed().Seconds()
+	if elapsed == 0 {
+		return 0
+	}
+	return float64(s.Requests.Total) / elapsed
+}
+
+// Copy returns a deep copy of the WorkerStats.
+func (s *WorkerStats) Copy() *WorkerStats {
+	return &WorkerStats{
+		ID:       s.ID,
+		Requests: s.Requests.Copy(),
+		Start:    s.Start,
+		End:      s.End,
+	}
+}
+
+// merge adds the API requests of another WorkerStats of the same worker to the WorkerStats,
+// keeping its run times unless it has none.
+func (s *WorkerStats) merge(other *WorkerStats) error {
+	if err := s.Requests.Merge(other.Requests); err != nil {
+		return err
+	}
+	if s.Start.IsZero() {
+		s.Start, s.End = other.Start, other.End
+	}
+	return nil
+}
+
+// Fairness indicates how evenly the workers of a test have been served.
+type Fairness struct {
+	// JainIndex is Jain's fairness index of the worker throughputs, from 1/n (one worker got
+	// all the service) to 1 (every worker got the same service).
+	JainIndex float64 `json:"jainIndex"`
+	// ElapsedRatio is the ratio of the longest worker elapsed time to the shortest one.
+	ElapsedRatio float64 `json:"elapsedRatio"`
+	// P99Ratio is the ratio of the greatest worker P99 latency to the smallest one.
+	P99Ratio float64 `json:"p99Ratio"`
+}
+
+// ComputeFairness computes the fairness indicators of the workers of a test. A ratio is
+// 0 when its denominator is 0.
+func ComputeFairness(stats []*WorkerStats) Fairness {
+	var (
+		fairness               Fairness
+		sum, sumOfSquares      float64
+		minElapsed, maxElapsed time.Duration
+		minP99, maxP99         float64
+	)
+	for index, worker := range stats {
+		throughput := worker.Throughput()
+		sum += throughput
+		sumOfSquares += throughput * throughput
+
+		elapsed, p99 := worker.Elapsed(), worker.Requests.Latencies.Quantile(0.99)
+		if index == 0 || elapsed < minElapsed {
+			minElapsed = elapsed
+		}
+		if elapsed > maxElapsed {
+			maxElapsed = elapsed
+		}
+		if index == 0 || p99 < minP99 {
+			minP99 = p99
+		}
+		if p99 > maxP99 {
+			maxP99 = p99
+		}
+	}
+
+	if sumOfSquares > 0 {
+		fairness.JainIndex = sum * sum / (float64(len(stats)) * sumOfSquares)
+	}
+	if minElapsed > 0 {
+		fairness.ElapsedRatio = float64(maxElapsed) / float64(minElapsed)
+	}
+	if minP99 > 0 {
+		fairness.P99Ratio = maxP99 / minP99
+	}
+	return fairness
+}
+
+// workerKey identifies the WorkerStats of a worker in a metric set.
+type workerKey struct {
+	id  int
+	set MetricSetID
+}
+
+// workerStatsStore holds the WorkerStats of every worker in every metric set.
+type workerStatsStore struct {
+	lock    sync.Mutex
+	enabled bool
+	stats   map[workerKey]*WorkerStats
+}
+
+// ConfigurePerWorker enables or disables the per-worker breakdown of API requests.
+func ConfigurePerWorker(enabled bool) {
+	workers.lock.Lock()
+	defer workers.lock.Unlock()
+	workers.enabled = enabled
+}
+
+// getOrCreate returns the WorkerStats of a worker in a metric set, creating it if it does
+// not exist. The lock must be held.
+func (s *workerStatsStore) getOrCreate(id int, set MetricSetID) *WorkerStats {
+	key := workerKey{id: id, set: set}
+	stats, ok := s.stats[key]
+	if !ok {
+		stats = &WorkerStats{
+			ID:       id,
+			Requests: NewRequestStats(),
+		}
+		s.stats[key] = stats
+	}
+	return stats
+}
+
+// record stores a single API request of a worker sent in a phase of its test, if the
+// per-worker breakdown is enabled.
+func (s *workerStatsStore) record(id int, success bool, seconds float64, set MetricSetID, phase string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.enabled {
+		return
+	}
+
+	workerAPIRequestLatencies.WithLabelValues(fmt.Sprint(id), set.Latency, set.Percent, set.trialLabel(), phase).Observe(seconds)
+	if phase == PhaseWarmUp {
+		set.Phase = PhaseWarmUp
+	}
+	stats := s.getOrCreate(id, set)
+	stats.Requests.Total++
+	if success {
+		stats.Requests.Successful++
+	}
+	stats.Requests.Latencies.Observe(seconds)
+}
+
+// RecordWorkerRun records the time a worker started and finished a test, if the per-worker
+// breakdown is enabled. Unless warm-up requests are included, the worker is deemed to have
+// started the test when its warm-up period ended.
+func RecordWorkerRun(id int, set MetricSetID, start, end time.Time) {
+	start = warmUp.measuredSince(set, start)
+	workers.lock.Lock()
+	defer workers.lock.Unlock()
+	if !workers.enabled {
+		return
+	}
+
+	stats := workers.getOrCreate(id, set)
+	stats.Start, stats.End = start, end
+}
+
+// collectWorkerStats gets the statistics of every worker for a metric set by ascending
+// worker ID, including the API requests of the warm-up period only when they are included
+// in reports, nil if the per-worker breakdown is disabled.
+func collectWorkerStats(set MetricSetID) []*WorkerStats {
+	warmUpSet := set
+	warmUpSet.Phase = PhaseWarmUp
+	included := warmUpIncluded()
+
+	workers.lock.Lock()
+	defer workers.lock.Unlock()
+	if !workers.enabled {
+		return nil
+	}
+
+	byID := make(map[int]*WorkerStats)
+	for key, worker := range workers.stats {
+		if key.set != set && (!included || key.set != warmUpSet) {
+			continue
+		}
+		stats, ok := byID[key.id]
+		if !ok {
+			byID[key.id] = worker.Copy()
+			continue
+		}
+		if err := stats.merge(worker); err != nil {
+			klog.Errorf("failed to include warm-up requests of worker %v in %v: %v", key.id, set, err.Error())
+		}
+	}
+	stats := make([]*WorkerStats, 0, len(byID))
+	for _, worker := range byID {
+		stats = append(stats, worker)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].ID < stats[j].ID
+	})
+	return stats
+}
+
+// prepareWorkerTable generates the per-worker breakdown table.
+func prepareWorkerTable(stats []*WorkerStats) printer.Table {
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Worker"),
+		printer.LineAlignRight("Requests"),
+		printer.LineAlignRight("Errors"),
+		printer.LineAlignRight("P50"),
+		printer.LineAlignRight("P99"),
+		printer.LineAlignRight("Elapsed"),
+		printer.LineAlignRight("Requests/s"),
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter("Per-worker Breakdown"))
+	table.SetHeaders(headerRow)
+
+	var tableRows []printer.TableRow
+	for _, worker := range stats {
+		tableRows = append(tableRows, printer.TableRow{
+			printer.LineAlignRight(fmt.Sprint(worker.ID)),
+			printer.LineAlignRight(fmt.Sprint(worker.Requests.Total)),
+			printer.LineAlignRight(fmt.Sprint(worker.Requests.Total - worker.Requests.Successful)),
+			printer.LineAlignRight(fmt.Sprintf("%.5f", worker.Requests.Latencies.Quantile(0.5))),
+			printer.LineAlignRight(fmt.Sprintf("%.5f", worker.Requests.Latencies.Quantile(0.99))),
+			printer.LineAlignRight(worker.Elapsed().Round(time.Millisecond).String()),
+			printer.LineAlignRight(fmt.Sprintf("%.2f", worker.Throughput())),
+		})
+	}
+	table.SetDatum(tableRows)
+
+	return *table
+}
+
+// prepareFairnessTable generates the table of fairness indicators across workers.
+func prepareFairnessTable(fairness Fairness) printer.Table {
+	headerRow := printer.TableRow{
+		printer.LineAlignLeft("Indicator"),
+		printer.LineAlignRight("Value"),
+	}
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter("Worker Fairness"))
+	table.SetHeaders(headerRow)
+	table.SetDatum([]printer.TableRow{
+		{printer.LineAlignLeft("Jain's index of throughputs"), printer.LineAlignRight(fmt.Sprintf("%.4f", fairness.JainIndex))},
+		{printer.LineAlignLeft("Max/min elapsed time ratio"), printer.LineAlignRight(fmt.Sprintf("%.4f", fairness.ElapsedRatio))},
+		{printer.LineAlignLeft("Max/min P99 ratio"), printer.LineAlignRight(fmt.Sprintf("%.4f", fairness.P99Ratio))},
+	})
+
+	return *table
+}
+
+// fairnessRows flattens the fairness indicators into named values, which are the rows of
+// the fairness tables of exported reports.
+func fairnessRows(fairness Fairness) []namedValue {
+	return []namedValue{
+		{name: "jain index", value: fairness.JainIndex},
+		{name: "elapsed ratio", value: fairness.ElapsedRatio},
+		{name: "P99 ratio", value: fairness.P99Ratio},
+	}
+}
@@ -7,33 +7,174 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+const (
+	// FormatCSV is the CSV export format.
+	FormatCSV = "csv"
+	// FormatJSON is the JSON export format.
+	FormatJSON = "json"
+
+	// LayoutStacked is the CSV report layout of one table per percent and statistic, with
+	// one column per latency.
+	LayoutStacked = "stacked"
+	// LayoutLong is the tidy CSV report layout of one row per value.
+	LayoutLong = "long"
+
+	// PlotNone appends no latency plot to summaries.
+	PlotNone = "none"
+	// PlotHistogram appends latency histograms to summaries.
+	PlotHistogram = "histogram"
+	// PlotCDF appends latency CDFs to summaries.
+	PlotCDF = "cdf"
 )
 
 // Options is the configuration of the perftests program.
 type Options struct {
+	// ArchiveFolderPath is the path to the results archive folder the result document of the run
+	// is appended to, keyed by run ID, cluster and tags. Archiving is disabled when it is empty.
+	ArchiveFolderPath string
+	// Baseline when set to true, tests the cluster without any IOChaos once before the first
+	// latency-percent pair (and once more after the last one when `BaselineRerun` is set), and
+	// reports the latency quantiles of every pair as slowdown factors relative to it.
+	Baseline bool
+	// BaselineRerun when set to true, tests the cluster without any IOChaos again once every
+	// latency-percent pair has been tested, to detect whether the cluster has drifted during the run.
+	BaselineRerun bool
 	// ChaosAgentPollIntervalInSeconds is the interval between polls when waiting for IOChaos status change.
 	ChaosAgentPollIntervalInSeconds int
 	// ChaosAgentPollTimeoutInSeconds is the timeout between polls when waiting for IOChaos status change.
 	ChaosAgentPollTimeoutInSeconds int
 	// ChaosAgentIOChaosTemplateFilePath is the path to the template IOChaos file.
 	ChaosAgentIOChaosTemplateFilePath string
+	// Cluster is the name of the cluster under test, which identifies the run in the results archive.
+	Cluster string
+	// CSVLayout is the layout of the exported CSV report, either `stacked` or `long`.
+	CSVLayout string
+	// Dashboard when set to true, shows a live progress dashboard refreshed in place when stdout
+	// is a terminal, and logs periodic plain-text progress lines otherwise.
+	Dashboard bool
+	// DashboardLineInterval is the interval between plain-text progress lines, when the live
+	// progress dashboard is not drawn.
+	DashboardLineInterval time.Duration
+	// DashboardRefreshInterval is the interval between refreshes of the live progress dashboard.
+	DashboardRefreshInterval time.Duration
+	// EtcdCAFilePath is the path to the CA file used to verify etcd members when scraping their metrics.
+	EtcdCAFilePath string
+	// EtcdCertFilePath is the path to the client certificate file used to scrape the metrics of etcd members.
+	EtcdCertFilePath string
+	// EtcdInsecureSkipVerify when set to true, skips verifying the certificates of etcd members when
+	// scraping their metrics.
+	EtcdInsecureSkipVerify bool
+	// EtcdKeyFilePath is the path to the client key file used to scrape the metrics of etcd members.
+	EtcdKeyFilePath string
+	// EtcdMetricsEndpoints are the client URLs of etcd members whose metrics are scraped before and
+	// after every test, scraping is disabled when it is empty.
+	EtcdMetricsEndpoints []string
 	// ExportFolderPath is the path to the folder where exported reports will be saved,
-	// only valid when `WriteToCSV` is set to `true`.
+	// only valid when `WriteToCSV`, `ExportHTML`, `ExportHistograms`, `ExportJUnit`, `ExportMarkdown`,
+	// `ExportResult` or `ExportTimeSeries` is set to `true`.
 	ExportFolderPath string
+	// ExportHTML when set to true, exports a self-contained HTML report with charts to a html file.
+	ExportHTML bool
+	// ExportHistograms when set to true, exports the latency histograms of every test to a json file.
+	ExportHistograms bool
+	// ExportJUnit when set to true, exports the SLO evaluations to a JUnit XML file.
+	ExportJUnit bool
+	// ExportMarkdown when set to true, exports the final matrix and the summary of every test to a
+	// markdown file.
+	ExportMarkdown bool
+	// ExportResult when set to true, exports the versioned result document of the run to a json file.
+	ExportResult bool
+	// ExportTimeSeries when set to true, exports the time series of every test to a file.
+	ExportTimeSeries bool
+	// Heatmap when set to true, prints the heatmap of a latency quantile of a verb across every
+	// latency-percent pair once every test has finished.
+	Heatmap bool
+	// HeatmapQuantile is the latency quantile the printed heatmap and the heatmap of the HTML
+	// report show.
+	HeatmapQuantile float64
+	// HeatmapVerb is the verb the printed heatmap and the heatmap of the HTML report show the
+	// latencies of.
+	HeatmapVerb string
 	// IOChaosKubeconfigFilePath is the the path to the kubeconfig file used by chaos agent.
 	IOChaosKubeconfigFilePath string
+	// IncludeWarmUp when set to true, includes the API requests of the warm-up period of every
+	// test in reports.
+	IncludeWarmUp bool
 	// JobsPerWorker is the number of jobs to be done per worker.
 	JobsPerWorker int
 	// KubeconfigFilePath is the path to the kubeconfig file.
 	KubeconfigFilePath string
 	// Latencies are a list of latencies to be applied to IOChaos.
 	Latencies []string
+	// LatencyAccuracy is the relative accuracy of the recorded API request latencies.
+	LatencyAccuracy float64
+	// MetricsAddress is the address the Prometheus metrics endpoint listens on, the
+	// endpoint is disabled when it is empty.
+	MetricsAddress string
+	// OutputStyle is the style summaries and tables are printed to the console in, one of the
+	// built-in renderers of the printer package (e.g. `ascii`, `unicode` or `markdown`).
+	OutputStyle string
 	// PercentsStr are a list of percents in string format, should be converted in to integers before use.
 	PercentsStr []string
+	// PerWorker when set to true, breaks down the API requests of every test by worker and reports
+	// how fairly the workers have been served.
+	PerWorker bool
+	// PushgatewayURL is the URL of the Pushgateway the results of every test are pushed to,
+	// pushing is disabled when it is empty.
+	PushgatewayURL string
+	// PushJobName is the value of the `job` label of pushed metrics.
+	PushJobName string
+	// PushRetries is the number of times a failed push is retried.
+	PushRetries int
+	// RandomizeTrials when set to true, runs the trials of every latency-percent pair interleaved
+	// in a randomized order instead of back to back.
+	RandomizeTrials bool
+	// QuantilesStr are a list of reported latency quantiles in string format, should be converted
+	// into floats before use.
+	QuantilesStr []string
+	// ResourceSampleInterval is the interval between samples of the resource usage of the
+	// load generator during every test, monitoring is disabled when it is 0.
+	ResourceSampleInterval time.Duration
+	// RunID identifies the run in pushed metrics and exported reports, it defaults to the
+	// time the options are parsed.
+	RunID string
+	// ScrapeAPIServer when set to true, scrapes the metrics of kube-apiserver before and after
+	// every test and reports their server-side differences.
+	ScrapeAPIServer bool
+	// TimeSeriesFormat is the format of exported time series, either `csv` or `json`.
+	TimeSeriesFormat string
+	// TimeSeriesInterval is the length of every interval of the time series.
+	TimeSeriesInterval time.Duration
+	// SLOs are SLO assertion expressions evaluated for every test.
+	SLOs []string
+	// SLOFilePath is the path to a file of SLO assertions evaluated for every test.
+	SLOFilePath string
+	// SensitivityVerbs are the verbs whose latency quantiles are fitted against the injected
+	// latency once every test has finished, no fit is done when it is empty.
+	SensitivityVerbs []string
 	// SleepTimeInSeconds is the length of time before cleanup is carried out after performance testing finishes.
 	SleepTimeInSeconds int
 	// Summarize when set to true, prints the report of each test in stdout.
 	Summarize bool
+	// SummaryPlot is the kind of per-verb latency plot appended to the report of each test,
+	// either `none`, `histogram` or `cdf`.
+	SummaryPlot string
+	// TagsStr are a list of `key=value` tags in string format, which identify the run in the
+	// results archive, should be converted into a map before use.
+	TagsStr []string
+	// Trials is the number of times every latency-percent pair is tested.
+	Trials int
+	// WarmUpDuration is the length of the warm-up period at the beginning of every test.
+	WarmUpDuration time.Duration
+	// WarmUpRequests is the number of API requests of the warm-up period at the beginning of
+	// every test.
+	WarmUpRequests int
 	// WorkerNumber is the number of workers.
 	WorkerNumber int
 	// WriteToCSV when set to true, exports the final report to a csv file.
@@ -41,22 +182,71 @@ type Options struct {
 
 	// Percents are a list of percents to be applied to IOChaos.
 	Percents []int
+	// Quantiles are a list of reported latency quantiles.
+	Quantiles []float64
+	// Tags map the keys of the tags of the run to their values.
+	Tags map[string]string
 }
 
 // NewOptions instantiates a new Options object with default values.
 func NewOptions() *Options {
 	return &Options{
+		ArchiveFolderPath:                 "",
+		Baseline:                          false,
+		BaselineRerun:                     false,
 		ChaosAgentPollIntervalInSeconds:   2,
 		ChaosAgentPollTimeoutInSeconds:    60,
 		ChaosAgentIOChaosTemplateFilePath: "",
+		Cluster:                           "",
+		CSVLayout:                         LayoutStacked,
+		Dashboard:                         false,
+		DashboardLineInterval:             30 * time.Second,
+		DashboardRefreshInterval:          time.Second,
+		EtcdCAFilePath:                    "",
+		EtcdCertFilePath:                  "",
+		EtcdInsecureSkipVerify:            false,
+		EtcdKeyFilePath:                   "",
+		EtcdMetricsEndpoints:              nil,
 		ExportFolderPath:                  "",
+		ExportHTML:                        false,
+		ExportHistograms:                  false,
+		ExportMarkdown:                    false,
+		ExportResult:                      false,
+		ExportJUnit:                       false,
+		ExportTimeSeries:                  false,
+		Heatmap:                           true,
+		HeatmapQuantile:                   0.99,
+		HeatmapVerb:                       constants.ALL,
 		IOChaosKubeconfigFilePath:         "",
+		IncludeWarmUp:                     false,
 		JobsPerWorker:                     100,
 		KubeconfigFilePath:                "kubeconfig",
 		Latencies:                         []string{"0ms", "10ms", "20ms", "30ms", "40ms", "50ms", "60ms", "70ms", "100ms", "200ms", "300ms"},
+		LatencyAccuracy:                   0.01,
+		MetricsAddress:                    "",
+		OutputStyle:                       "ascii",
 		PercentsStr:                       []string{"10", "20", "30", "40", "50", "60", "70"},
+		PerWorker:                         false,
+		PushgatewayURL:                    "",
+		PushJobName:                       "perftests",
+		PushRetries:                       3,
+		RandomizeTrials:                   false,
+		QuantilesStr:                      []string{"0.1", "0.25", "0.5", "0.75", "0.9", "0.95", "0.99", "0.999", "1"},
+		ResourceSampleInterval:            time.Second,
+		RunID:                             "",
+		ScrapeAPIServer:                   false,
+		SLOs:                              nil,
+		SLOFilePath:                       "",
+		SensitivityVerbs:                  []string{constants.ALL},
 		SleepTimeInSeconds:                60,
 		Summarize:                         true,
+		SummaryPlot:                       PlotNone,
+		TimeSeriesFormat:                  FormatCSV,
+		TimeSeriesInterval:                time.Second,
+		TagsStr:                           []string{},
+		Trials:                            1,
+		WarmUpDuration:                    0,
+		WarmUpRequests:                    0,
 		WorkerNumber:                      30,
 		WriteToCSV:                        false,
 	}
@@ -107,8 +297,125 @@ func (o *Options) Parse() error {
 		o.Latencies[index] = fmt.Sprint(latencyInt) + "ms"
 	}
 
+	// Convert quantile strings to floats.
+	for _, quantileStr := range o.QuantilesStr {
+		quantile, err := strconv.ParseFloat(quantileStr, 64)
+		if err != nil {
+			return err
+		}
+
+		if quantile < 0 || quantile > 1 {
+			return fmt.Errorf("%v is not a valid quantile (should be in range [0, 1])", quantileStr)
+		}
+		o.Quantiles = append(o.Quantiles, quantile)
+	}
+	sort.Float64s(o.Quantiles)
+
+	// Ensure the baseline is valid.
+	if o.BaselineRerun && !o.Baseline {
+		return fmt.Errorf("the baseline cannot be re-tested when it is not tested")
+	}
+
+	// Ensure the latency accuracy is a valid relative error.
+	if o.LatencyAccuracy <= 0 || o.LatencyAccuracy >= 1 {
+		return fmt.Errorf("%v is not a valid latency accuracy (should be in range (0, 1))", o.LatencyAccuracy)
+	}
+
+	// Ensure the resource sample interval is valid.
+	if o.ResourceSampleInterval < 0 {
+		return fmt.Errorf("%v is not a valid resource sample interval (should be non-negative)", o.ResourceSampleInterval)
+	}
+
+	// Ensure the progress dashboard intervals are valid.
+	if o.DashboardLineInterval <= 0 {
+		return fmt.Errorf("%v is not a valid dashboard line interval (should be positive)", o.DashboardLineInterval)
+	}
+	if o.DashboardRefreshInterval <= 0 {
+		return fmt.Errorf("%v is not a valid dashboard refresh interval (should be positive)", o.DashboardRefreshInterval)
+	}
+
+	// Ensure the heatmap shows a valid verb and quantile.
+	if o.HeatmapQuantile < 0 || o.HeatmapQuantile > 1 {
+		return fmt.Errorf("%v is not a valid heatmap quantile (should be in range [0, 1])", o.HeatmapQuantile)
+	}
+	if !constants.IsVerb(o.HeatmapVerb) {
+		return fmt.Errorf("%v is not a valid heatmap verb (should be one of %v)", o.HeatmapVerb, strings.Join(constants.Verbs, ", "))
+	}
+
+	// Ensure sensitivities are fitted for valid verbs.
+	for _, verb := range o.SensitivityVerbs {
+		if !constants.IsVerb(verb) {
+			return fmt.Errorf("%v is not a valid sensitivity verb (should be one of %v)", verb, strings.Join(constants.Verbs, ", "))
+		}
+	}
+
+	// Ensure the output style is valid.
+	if _, ok := printer.Renderers[o.OutputStyle]; !ok {
+		return fmt.Errorf("%v is not a valid output style (should be one of %v)", o.OutputStyle, strings.Join(printer.RendererNames(), ", "))
+	}
+
+	// Ensure the summary plot is valid.
+	if o.SummaryPlot != PlotNone && o.SummaryPlot != PlotHistogram && o.SummaryPlot != PlotCDF {
+		return fmt.Errorf("%v is not a valid summary plot (should be %v, %v or %v)", o.SummaryPlot, PlotNone, PlotHistogram, PlotCDF)
+	}
+
+	// Ensure the csv layout is valid.
+	if o.CSVLayout != LayoutStacked && o.CSVLayout != LayoutLong {
+		return fmt.Errorf("%v is not a valid csv layout (should be %v or %v)", o.CSVLayout, LayoutStacked, LayoutLong)
+	}
+
+	// Ensure time series are valid.
+	if o.TimeSeriesInterval <= 0 {
+		return fmt.Errorf("%v is not a valid time series interval (should be positive)", o.TimeSeriesInterval)
+	}
+	if o.TimeSeriesFormat != FormatCSV && o.TimeSeriesFormat != FormatJSON {
+		return fmt.Errorf("%v is not a valid time series format (should be %v or %v)", o.TimeSeriesFormat, FormatCSV, FormatJSON)
+	}
+
+	// Ensure pushes are valid.
+	if o.PushRetries < 0 {
+		return fmt.Errorf("%v is not a valid number of push retries (should not be negative)", o.PushRetries)
+	}
+
+	// Ensure every latency-percent pair is tested at least once.
+	if o.Trials < 1 {
+		return fmt.Errorf("%v is not a valid number of trials (should be at least 1)", o.Trials)
+	}
+
+	// Ensure the warm-up period is valid.
+	if o.WarmUpDuration < 0 {
+		return fmt.Errorf("%v is not a valid warm-up duration (should not be negative)", o.WarmUpDuration)
+	}
+	if o.WarmUpRequests < 0 {
+		return fmt.Errorf("%v is not a valid number of warm-up requests (should not be negative)", o.WarmUpRequests)
+	}
+
+	// Ensure the etcd client certificate and key are provided together.
+	if (len(o.EtcdCertFilePath) > 0) != (len(o.EtcdKeyFilePath) > 0) {
+		return fmt.Errorf("etcd client certificate and key should be provided together")
+	}
+
+	// Generate a run ID if none is provided.
+	if len(o.RunID) == 0 {
+		o.RunID = time.Now().Format("20060102-150405")
+	}
+
+	// Convert tag strings to a map.
+	tags, err := ParseTags(o.TagsStr)
+	if err != nil {
+		return err
+	}
+	o.Tags = tags
+
+	// Ensure `ArchiveFolderPath` is a folder if it exists, it is created otherwise.
+	if len(o.ArchiveFolderPath) > 0 {
+		if info, err := os.Stat(o.ArchiveFolderPath); err == nil && !info.IsDir() {
+			return fmt.Errorf("archive destination %v is not a valid directory", o.ArchiveFolderPath)
+		}
+	}
+
 	// Ensure `ExportFolderPath` is a folder.
-	if o.WriteToCSV && len(o.ExportFolderPath) > 0 {
+	if (o.WriteToCSV || o.ExportHTML || o.ExportHistograms || o.ExportJUnit || o.ExportMarkdown || o.ExportResult || o.ExportTimeSeries) && len(o.ExportFolderPath) > 0 {
 		info, err := os.Stat(o.ExportFolderPath)
 		if err != nil {
 			return err
@@ -120,3 +427,16 @@ func (o *Options) Parse() error {
 
 	return nil
 }
+
+// ParseTags converts `key=value` tag strings to a map of the keys of the tags to their values.
+func ParseTags(tagsStr []string) (map[string]string, error) {
+	tags := make(map[string]string, len(tagsStr))
+	for _, tagStr := range tagsStr {
+		key, value, ok := strings.Cut(tagStr, "=")
+		if !ok || len(key) == 0 {
+			return nil, fmt.Errorf("%v is not a valid tag (should be key=value)", tagStr)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
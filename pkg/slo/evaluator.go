@@ -0,0 +1,153 @@
+package slo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nemoremold/perftests/pkg/metrics"
+	"github.com/nemoremold/perftests/pkg/utils/printer"
+)
+
+// ErrViolated is returned when any SLO assertion fails.
+var ErrViolated = errors.New("SLO assertions failed")
+
+// Result is the evaluation of an assertion in a latency-percent pair.
+type Result struct {
+	// Assertion is the evaluated assertion.
+	Assertion Assertion
+	// Set identifies the latency-percent pair.
+	Set metrics.MetricSetID
+	// Observed is the observed value of the asserted metric.
+	Observed float64
+	// Passed is `true` when the observed value meets the threshold.
+	Passed bool
+	// Missing is `true` when no API request of the asserted verb has been recorded,
+	// which fails the assertion.
+	Missing bool
+}
+
+// Evaluator evaluates SLO assertions for every latency-percent pair and keeps the results.
+type Evaluator struct {
+	// assertions are the evaluated assertions.
+	assertions []Assertion
+	// results are the results of every evaluation by the order of evaluation.
+	results []Result
+}
+
+// NewEvaluator instantiates a new Evaluator of a set of assertions.
+func NewEvaluator(assertions []Assertion) *Evaluator {
+	return &Evaluator{
+		assertions: assertions,
+	}
+}
+
+// Evaluate evaluates every applicable assertion against the statistics of a
+// latency-percent pair, returning the results of this evaluation.
+func (e *Evaluator) Evaluate(cell metrics.CellStats) []Result {
+	var results []Result
+	for _, assertion := range e.assertions {
+		if !assertion.Applies(cell.Set) {
+			continue
+		}
+
+		result := Result{
+			Assertion: assertion,
+			Set:       cell.Set,
+		}
+		stats, ok := cell.Verbs[assertion.Verb]
+		if !ok || stats.Total == 0 {
+			result.Missing = true
+		} else {
+			result.Observed = assertion.Observe(stats)
+			result.Passed = assertion.Check(result.Observed)
+		}
+		results = append(results, result)
+	}
+	e.results = append(e.results, results...)
+	return results
+}
+
+// Results returns the results of every evaluation.
+func (e *Evaluator) Results() []Result {
+	return e.results
+}
+
+// Failures returns the number of failed evaluations.
+func (e *Evaluator) Failures() int {
+	failures := 0
+	for _, result := range e.results {
+		if !result.Passed {
+			failures++
+		}
+	}
+	return failures
+}
+
+// Err returns `ErrViolated` when any evaluation failed, nil otherwise.
+func (e *Evaluator) Err() error {
+	if failures := e.Failures(); failures > 0 {
+		return fmt.Errorf("%w: %v of %v evaluations failed", ErrViolated, failures, len(e.results))
+	}
+	return nil
+}
+
+// Summary prints the pass/fail table of every evaluation.
+func (e *Evaluator) Summary() {
+	sheet := printer.NewSheet(0, printer.LineAlignCenter("SLO Evaluation"))
+
+	passed := len(e.results) - e.Failures()
+	sheet.SetHeader([]printer.Line{
+		printer.LineAlignRight(fmt.Sprintf("Assertions: %v", len(e.assertions))),
+		printer.LineAlignRight(fmt.Sprintf("Passed evaluations: %v/%v", passed, len(e.results))),
+	})
+
+	// Show the trial column only when latency-percent pairs are tested several times.
+	withTrials := false
+	for _, result := range e.results {
+		if result.Set.Trial > 0 {
+			withTrials = true
+			break
+		}
+	}
+
+	headerRow := printer.TableRow{
+		printer.LineAlignRight("Latency"),
+		printer.LineAlignRight("Percent"),
+	}
+	if withTrials {
+		headerRow.AddEntry(printer.LineAlignRight("Trial"))
+	}
+	headerRow.AddEntry(printer.LineAlignLeft("Assertion"))
+	headerRow.AddEntry(printer.LineAlignRight("Observed"))
+	headerRow.AddEntry(printer.LineAlignRight("Result"))
+	table := printer.NewTable(0, headerRow.ColumnsCount(), printer.LineAlignCenter("SLO Assertions"))
+	table.SetHeaders(headerRow)
+
+	var tableRows []printer.TableRow
+	for _, result := range e.results {
+		observed, verdict := "-", "FAIL"
+		if !result.Missing {
+			observed = result.Assertion.FormatValue(result.Observed)
+		}
+		if result.Passed {
+			verdict = "PASS"
+		}
+		row := printer.TableRow{
+			printer.LineAlignRight(result.Set.Latency),
+			printer.LineAlignRight(result.Set.Percent),
+		}
+		if withTrials {
+			row.AddEntry(printer.LineAlignRight(fmt.Sprint(result.Set.Trial)))
+		}
+		row.AddEntry(printer.LineAlignLeft(result.Assertion.Name))
+		row.AddEntry(printer.LineAlignRight(observed))
+		row.AddEntry(printer.LineAlignRight(verdict))
+		tableRows = append(tableRows, row)
+	}
+	table.SetDatum(tableRows)
+	sheet.SetTables([]printer.Table{*table})
+
+	printer.PrintEmptyLine()
+	sheet.Print()
+	printer.PrintEmptyLine()
+}
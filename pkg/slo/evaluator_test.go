@@ -0,0 +1,72 @@
+package slo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+// newTestCell instantiates the statistics of a latency-percent pair where ten create requests
+// took 500ms, one of which failed.
+func newTestCell(set metrics.MetricSetID) metrics.CellStats {
+	stats := metrics.NewRequestStats()
+	for index := 0; index < 10; index++ {
+		stats.Latencies.Observe(0.5)
+	}
+	stats.Total, stats.Successful = 10, 9
+	return metrics.CellStats{
+		Set:   set,
+		Verbs: map[string]*metrics.RequestStats{constants.CREATE: stats},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	var assertions []Assertion
+	for _, expression := range []string{
+		"create.p99<1s",
+		"create.max<100ms",
+		"create.success_rate>=90%",
+		"get.p99<1s",
+		"create.p99<1s@latency>50ms",
+	} {
+		assertion, err := ParseExpression(expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertions = append(assertions, assertion)
+	}
+
+	evaluator := NewEvaluator(assertions)
+	results := evaluator.Evaluate(newTestCell(metrics.MetricSetID{Latency: "10ms", Percent: "50"}))
+	want := map[string]struct{ passed, missing bool }{
+		"create.p99<1s":            {passed: true},
+		"create.max<100ms":         {},
+		"create.success_rate>=90%": {passed: true},
+		"get.p99<1s":               {missing: true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %v results, want %v since an assertion does not apply", len(results), len(want))
+	}
+	for _, result := range results {
+		wanted, ok := want[result.Assertion.Name]
+		if !ok {
+			t.Errorf("got a result of %v, want none", result.Assertion.Name)
+			continue
+		}
+		if result.Passed != wanted.passed || result.Missing != wanted.missing {
+			t.Errorf("got passed %v and missing %v evaluating %v, want %v and %v", result.Passed, result.Missing, result.Assertion.Name, wanted.passed, wanted.missing)
+		}
+	}
+
+	if got := evaluator.Failures(); got != 2 {
+		t.Errorf("got %v failures, want 2", got)
+	}
+	if err := evaluator.Err(); !errors.Is(err, ErrViolated) {
+		t.Errorf("got error %v, want %v", err, ErrViolated)
+	}
+	if err := NewEvaluator(assertions[:1]).Err(); err != nil {
+		t.Errorf("got error %v without evaluations, want none", err)
+	}
+}
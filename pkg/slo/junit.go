@@ -0,0 +1,145 @@
+package slo
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+// junitTestSuites is the root element of a JUnit report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the evaluations of a latency-percent pair.
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+// junitProperty is a name-value pair describing a test suite.
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitTestCase is the evaluation of an assertion.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+// junitFailure describes why an assertion failed.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",cdata"`
+}
+
+// ExportJUnit exports the evaluations to a JUnit XML file. Every latency-percent pair
+// collected by the exporter is a test suite, and every evaluated assertion in it is a
+// test case.
+func (e *Evaluator) ExportJUnit(ctx context.Context, cells []metrics.CellStats, filepath string) error {
+	file, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	go func() {
+		<-ctx.Done()
+		_ = file.Close()
+	}()
+
+	report := junitTestSuites{Name: "perftests"}
+	for _, cell := range cells {
+		suite := junitTestSuite{
+			Name: fmt.Sprintf("latency=%v,percent=%v", cell.Set.Latency, cell.Set.Percent),
+			Time: cell.End.Sub(cell.Start).Seconds(),
+			Properties: []junitProperty{
+				{Name: "latency", Value: cell.Set.Latency},
+				{Name: "percent", Value: cell.Set.Percent},
+			},
+		}
+		if cell.Set.Trial > 0 {
+			suite.Name += fmt.Sprintf(",trial=%v", cell.Set.Trial)
+			suite.Properties = append(suite.Properties, junitProperty{Name: "trial", Value: fmt.Sprint(cell.Set.Trial)})
+		}
+		if !cell.Start.IsZero() {
+			suite.Timestamp = cell.Start.UTC().Format("2006-01-02T15:04:05")
+		}
+		if all, ok := cell.Verbs[constants.ALL]; ok {
+			suite.Properties = append(suite.Properties,
+				junitProperty{Name: "requests", Value: fmt.Sprint(all.Total)},
+				junitProperty{Name: "successRate", Value: fmt.Sprintf("%.2f", all.SuccessRate())},
+			)
+		}
+
+		for _, result := range e.results {
+			if result.Set != cell.Set {
+				continue
+			}
+			testCase := junitTestCase{
+				Name:      result.Assertion.Name,
+				ClassName: fmt.Sprintf("perftests.%v.%v", cell.Set.Latency, cell.Set.Percent),
+			}
+			if !result.Passed {
+				testCase.Failure = failureOf(result)
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, testCase)
+			suite.Tests++
+		}
+
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Time += suite.Time
+	}
+
+	if _, err := file.WriteString(xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err = file.WriteString("\n")
+	return err
+}
+
+// failureOf describes the failure of an evaluation.
+func failureOf(result Result) *junitFailure {
+	assertion := result.Assertion
+	expected := fmt.Sprintf("%v %v %v", assertion.Metric, assertion.Operator, assertion.Threshold)
+	if result.Missing {
+		return &junitFailure{
+			Message: fmt.Sprintf("no %v request recorded, expected %v", assertion.Verb, expected),
+			Type:    "MissingData",
+			Content: fmt.Sprintf("verb: %v\nexpected: %v\nobserved: none", assertion.Verb, expected),
+		}
+	}
+	observed := assertion.FormatValue(result.Observed)
+	return &junitFailure{
+		Message: fmt.Sprintf("observed %v %v, expected %v", assertion.Metric, observed, expected),
+		Type:    "SLOViolation",
+		Content: fmt.Sprintf("verb: %v\nexpected: %v\nobserved: %v", assertion.Verb, expected, observed),
+	}
+}
@@ -0,0 +1,370 @@
+package slo
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/nemoremold/perftests/pkg/constants"
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+const (
+	// MetricMax is the maximum latency.
+	MetricMax = "max"
+	// MetricMin is the minimum latency.
+	MetricMin = "min"
+	// MetricMean is the mean latency.
+	MetricMean = "mean"
+	// MetricSuccessRate is the percentage of successful API requests.
+	MetricSuccessRate = "success_rate"
+)
+
+var (
+	// operators are the supported comparison operators, longer ones first so that they
+	// are matched before their prefixes.
+	operators = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+	// quantileMetric matches quantile metrics, e.g. `p99` or `p99.9`.
+	quantileMetric = regexp.MustCompile(`^p([0-9]+(\.[0-9]+)?)$`)
+)
+
+// File is the format of an SLO file.
+type File struct {
+	// Assertions are the SLO assertions.
+	Assertions []Assertion `json:"assertions"`
+}
+
+// Assertion is a threshold a metric of a verb must meet in every latency-percent pair
+// matching its conditions, e.g. the P99 latency of create requests must be less than
+// 1s when the injected latency is no more than 50ms.
+type Assertion struct {
+	// Name is the name of the assertion, generated from its expression when empty.
+	Name string `json:"name,omitempty"`
+	// Verb is the verb the assertion applies to.
+	Verb string `json:"verb"`
+	// Metric is the asserted metric: a quantile (e.g. `p99`, `p99.9`), `min`, `max`,
+	// `mean` or `success_rate`.
+	Metric string `json:"metric"`
+	// Operator compares the observed value with the threshold: <, <=, >, >=, == or !=.
+	Operator string `json:"operator"`
+	// Threshold is a duration (e.g. `1s`) for latency metrics, or a percentage (e.g.
+	// `99.9`) for the success rate.
+	Threshold string `json:"threshold"`
+	// When are the conditions on the latency-percent pairs the assertion applies to,
+	// keyed by `latency` or `percent` (e.g. `latency: "<=50ms"`). The assertion
+	// applies to every latency-percent pair when it is empty.
+	When map[string]string `json:"when,omitempty"`
+
+	// threshold is the parsed threshold, in seconds for latency metrics.
+	threshold float64
+	// conditions are the parsed conditions.
+	conditions []condition
+}
+
+// condition restricts the latency-percent pairs an assertion applies to.
+type condition struct {
+	// label is either `latency` or `percent`.
+	label string
+	// operator compares the value of the label with the value of the condition.
+	operator string
+	// value is the value of the condition, in milliseconds for latencies.
+	value float64
+}
+
+// Load loads SLO assertions from expressions and from an SLO file (ignored when its path
+// is empty). An expression has the format `<verb>.<metric><operator><threshold>[@<conditions>]`,
+// where conditions are comma-separated `<latency|percent><operator><value>`, e.g.
+// `create.p99<1s@latency<=50ms` or `all.success_rate>=99.9`.
+func Load(expressions []string, filePath string) ([]Assertion, error) {
+	var assertions []Assertion
+	for _, expression := range expressions {
+		assertion, err := ParseExpression(expression)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, assertion)
+	}
+
+	if len(filePath) > 0 {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		file := File{}
+		if err := yaml.UnmarshalStrict(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to decode SLO file %v: %w", filePath, err)
+		}
+		for _, assertion := range file.Assertions {
+			if err := assertion.complete(); err != nil {
+				return nil, err
+			}
+			assertions = append(assertions, assertion)
+		}
+	}
+	return assertions, nil
+}
+
+// ParseExpression parses an SLO assertion expression.
+func ParseExpression(expression string) (Assertion, error) {
+	body, conditions := expression, ""
+	if index := strings.Index(expression, "@"); index >= 0 {
+		body, conditions = expression[:index], expression[index+1:]
+	}
+
+	left, operator, threshold, err := splitComparison(body)
+	if err != nil {
+		return Assertion{}, fmt.Errorf("%v is not a valid SLO expression: %w", expression, err)
+	}
+	dot := strings.Index(left, ".")
+	if dot < 0 {
+		return Assertion{}, fmt.Errorf("%v is not a valid SLO expression: expected <verb>.<metric> before the operator", expression)
+	}
+
+	assertion := Assertion{
+		Name:      expression,
+		Verb:      strings.TrimSpace(left[:dot]),
+		Metric:    strings.TrimSpace(left[dot+1:]),
+		Operator:  operator,
+		Threshold: threshold,
+		When:      make(map[string]string),
+	}
+	if len(strings.TrimSpace(conditions)) > 0 {
+		for _, rawCondition := range strings.Split(conditions, ",") {
+			label, conditionOperator, value, err := splitComparison(rawCondition)
+			if err != nil {
+				return Assertion{}, fmt.Errorf("%v is not a valid SLO expression: %w", expression, err)
+			}
+			label = strings.ToLower(label)
+			if _, ok := assertion.When[label]; ok {
+				return Assertion{}, fmt.Errorf("%v is not a valid SLO expression: duplicated condition on %v", expression, label)
+			}
+			assertion.When[label] = conditionOperator + value
+		}
+	}
+
+	if err := assertion.complete(); err != nil {
+		return Assertion{}, err
+	}
+	return assertion, nil
+}
+
+// complete validates an assertion and parses its threshold and conditions.
+func (a *Assertion) complete() error {
+	a.Verb = strings.ToLower(a.Verb)
+	a.Metric = strings.ToLower(a.Metric)
+	if len(a.Name) == 0 {
+		a.Name = a.expression()
+	}
+
+	if !isValidVerb(a.Verb) {
+		return fmt.Errorf("SLO %v: %v is not a valid verb (should be one of %v)", a.Name, a.Verb, strings.Join(constants.Verbs, ", "))
+	}
+	if !isValidOperator(a.Operator) {
+		return fmt.Errorf("SLO %v: %v is not a valid operator (should be one of %v)", a.Name, a.Operator, strings.Join(operators, ", "))
+	}
+
+	switch {
+	case a.Metric == MetricSuccessRate:
+		threshold, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(a.Threshold), "%"), 64)
+		if err != nil {
+			return fmt.Errorf("SLO %v: %v is not a valid percentage", a.Name, a.Threshold)
+		}
+		a.threshold = threshold
+	case a.Metric == MetricMax || a.Metric == MetricMin || a.Metric == MetricMean || quantileMetric.MatchString(a.Metric):
+		threshold, err := parseSeconds(a.Threshold)
+		if err != nil {
+			return fmt.Errorf("SLO %v: %v is not a valid duration", a.Name, a.Threshold)
+		}
+		a.threshold = threshold
+		if matches := quantileMetric.FindStringSubmatch(a.Metric); matches != nil {
+			if percent, _ := strconv.ParseFloat(matches[1], 64); percent > 100 {
+				return fmt.Errorf("SLO %v: %v is not a valid quantile (should be in range [p0, p100])", a.Name, a.Metric)
+			}
+		}
+	default:
+		return fmt.Errorf("SLO %v: %v is not a valid metric (should be a quantile like p99, %v, %v, %v or %v)", a.Name, a.Metric, MetricMin, MetricMax, MetricMean, MetricSuccessRate)
+	}
+
+	a.conditions = nil
+	for label, rawCondition := range a.When {
+		operator, value := splitOperator(strings.TrimSpace(rawCondition))
+		if !isValidOperator(operator) {
+			return fmt.Errorf("SLO %v: %v is not a valid condition", a.Name, rawCondition)
+		}
+		parsed := condition{label: strings.ToLower(strings.TrimSpace(label)), operator: operator}
+		var err error
+		switch parsed.label {
+		case "latency":
+			parsed.value, err = parseMilliseconds(value)
+		case "percent":
+			parsed.value, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		default:
+			return fmt.Errorf("SLO %v: %v is not a valid condition label (should be latency or percent)", a.Name, label)
+		}
+		if err != nil {
+			return fmt.Errorf("SLO %v: %v is not a valid condition value", a.Name, value)
+		}
+		a.conditions = append(a.conditions, parsed)
+	}
+	return nil
+}
+
+// expression returns the expression form of an assertion.
+func (a *Assertion) expression() string {
+	expression := a.Verb + "." + a.Metric + a.Operator + a.Threshold
+	var conditions []string
+	for _, label := range []string{"latency", "percent"} {
+		if value, ok := a.When[label]; ok {
+			conditions = append(conditions, label+value)
+		}
+	}
+	if len(conditions) > 0 {
+		expression += "@" + strings.Join(conditions, ",")
+	}
+	return expression
+}
+
+// Applies checks whether the assertion applies to a latency-percent pair.
+func (a *Assertion) Applies(set metrics.MetricSetID) bool {
+	for _, c := range a.conditions {
+		var (
+			value float64
+			err   error
+		)
+		if c.label == "latency" {
+			value, err = parseMilliseconds(set.Latency)
+		} else {
+			value, err = strconv.ParseFloat(set.Percent, 64)
+		}
+		if err != nil || !compare(value, c.operator, c.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Observe returns the value of the asserted metric in a set of request statistics, in
+// seconds for latency metrics.
+func (a *Assertion) Observe(stats *metrics.RequestStats) float64 {
+	switch a.Metric {
+	case MetricSuccessRate:
+		return stats.SuccessRate()
+	case MetricMin:
+		return stats.Latencies.Min()
+	case MetricMax:
+		return stats.Latencies.Max()
+	case MetricMean:
+		return stats.Latencies.Mean()
+	default:
+		percent, _ := strconv.ParseFloat(quantileMetric.FindStringSubmatch(a.Metric)[1], 64)
+		return stats.Latencies.Quantile(percent / 100)
+	}
+}
+
+// Check checks whether an observed value meets the threshold.
+func (a *Assertion) Check(observed float64) bool {
+	return compare(observed, a.Operator, a.threshold)
+}
+
+// FormatValue formats a value of the asserted metric.
+func (a *Assertion) FormatValue(value float64) string {
+	if a.Metric == MetricSuccessRate {
+		return fmt.Sprintf("%.2f%%", value)
+	}
+	return time.Duration(value * float64(time.Second)).String()
+}
+
+// splitComparison splits `<left><operator><right>` into its three parts.
+func splitComparison(comparison string) (string, string, string, error) {
+	for index := range comparison {
+		for _, operator := range operators {
+			if strings.HasPrefix(comparison[index:], operator) {
+				left := strings.TrimSpace(comparison[:index])
+				right := strings.TrimSpace(comparison[index+len(operator):])
+				if len(left) == 0 || len(right) == 0 {
+					return "", "", "", fmt.Errorf("%v is not a valid comparison", comparison)
+				}
+				return left, operator, right, nil
+			}
+		}
+	}
+	return "", "", "", fmt.Errorf("%v has no comparison operator (should be one of %v)", comparison, strings.Join(operators, ", "))
+}
+
+// splitOperator splits `<operator><value>` into its two parts.
+func splitOperator(comparison string) (string, string) {
+	for _, operator := range operators {
+		if strings.HasPrefix(comparison, operator) {
+			return operator, strings.TrimSpace(strings.TrimPrefix(comparison, operator))
+		}
+	}
+	return "", comparison
+}
+
+// compare compares two values with an operator.
+func compare(left float64, operator string, right float64) bool {
+	switch operator {
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	default:
+		return false
+	}
+}
+
+// parseSeconds parses a duration (e.g. `250ms`) or a plain number of seconds.
+func parseSeconds(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return seconds, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return duration.Seconds(), nil
+}
+
+// parseMilliseconds parses a latency label (e.g. `50ms`) or a duration to milliseconds.
+func parseMilliseconds(value string) (float64, error) {
+	duration, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return float64(duration) / float64(time.Millisecond), nil
+}
+
+// isValidVerb checks whether a verb is one of `constants.Verbs`.
+func isValidVerb(verb string) bool {
+	for _, candidate := range constants.Verbs {
+		if verb == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidOperator checks whether an operator is supported.
+func isValidOperator(operator string) bool {
+	for _, candidate := range operators {
+		if operator == candidate {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package slo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nemoremold/perftests/pkg/metrics"
+)
+
+func TestParseExpression(t *testing.T) {
+	for _, test := range []struct {
+		expression string
+		verb       string
+		metric     string
+		operator   string
+		threshold  float64
+		conditions int
+	}{
+		{expression: "create.p99<=1s", verb: "create", metric: "p99", operator: "<=", threshold: 1},
+		{expression: "create.p99<1s", verb: "create", metric: "p99", operator: "<", threshold: 1},
+		{expression: "GET.P50 >= 250ms", verb: "get", metric: "p50", operator: ">=", threshold: 0.25},
+		{expression: "all.p99.9!=0.5", verb: "all", metric: "p99.9", operator: "!=", threshold: 0.5},
+		{expression: "all.success_rate>=99.9%", verb: "all", metric: MetricSuccessRate, operator: ">=", threshold: 99.9},
+		{expression: "all.success_rate==100", verb: "all", metric: MetricSuccessRate, operator: "==", threshold: 100},
+		{expression: "list.max<2s@latency<=50ms,percent>10%", verb: "list", metric: MetricMax, operator: "<", threshold: 2, conditions: 2},
+	} {
+		t.Run(test.expression, func(t *testing.T) {
+			assertion, err := ParseExpression(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if assertion.Verb != test.verb || assertion.Metric != test.metric || assertion.Operator != test.operator {
+				t.Errorf("got %v.%v%v, want %v.%v%v", assertion.Verb, assertion.Metric, assertion.Operator, test.verb, test.metric, test.operator)
+			}
+			if math.Abs(assertion.threshold-test.threshold) > 1e-9 {
+				t.Errorf("got threshold %v, want %v", assertion.threshold, test.threshold)
+			}
+			if len(assertion.conditions) != test.conditions {
+				t.Errorf("got %v conditions, want %v", len(assertion.conditions), test.conditions)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, expression := range []string{
+		"create.p99",
+		"create.p99<",
+		"p99<1s",
+		"watch.p99<1s",
+		"create.p101<1s",
+		"create.median<1s",
+		"create.p99<fast",
+		"all.success_rate>=most",
+		"create.p99<1s@latency<=50ms,latency>10ms",
+		"create.p99<1s@latency<=50ms,Latency>10ms",
+		"create.p99<1s@disk<=50ms",
+		"create.p99<1s@latency<=fast",
+		"create.p99<1s@percent",
+	} {
+		if _, err := ParseExpression(expression); err == nil {
+			t.Errorf("got no error parsing %v, want one", expression)
+		}
+	}
+}
+
+func TestAssertionApplies(t *testing.T) {
+	assertion, err := ParseExpression("create.p99<1s@latency<=50ms,percent>10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		set  metrics.MetricSetID
+		want bool
+	}{
+		{set: metrics.MetricSetID{Latency: "50ms", Percent: "20"}, want: true},
+		{set: metrics.MetricSetID{Latency: "10ms", Percent: "100", Trial: 2}, want: true},
+		{set: metrics.MetricSetID{Latency: "100ms", Percent: "20"}, want: false},
+		{set: metrics.MetricSetID{Latency: "50ms", Percent: "10"}, want: false},
+		{set: metrics.MetricSetID{Latency: metrics.BaselineLatency, Percent: metrics.BaselinePercent}, want: false},
+	} {
+		if got := assertion.Applies(test.set); got != test.want {
+			t.Errorf("got %v applying to %v, want %v", got, test.set, test.want)
+		}
+	}
+
+	unconditional, err := ParseExpression("create.p99<1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !unconditional.Applies(metrics.MetricSetID{Latency: metrics.BaselineLatency, Percent: metrics.BaselinePercent}) {
+		t.Error("got an assertion without conditions not applying to the baseline, want it to apply")
+	}
+}
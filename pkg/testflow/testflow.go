@@ -3,14 +3,17 @@ package testflow
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 
 	"k8s.io/klog/v2"
 
+	"github.com/nemoremold/perftests/pkg/archive"
 	"github.com/nemoremold/perftests/pkg/chaosmesh"
 	"github.com/nemoremold/perftests/pkg/metrics"
 	"github.com/nemoremold/perftests/pkg/options"
+	"github.com/nemoremold/perftests/pkg/slo"
 	"github.com/nemoremold/perftests/pkg/worker"
 )
 
@@ -27,10 +30,56 @@ type TestFlow struct {
 	// Exporter collects metrics data and generates the final report,
 	// exporting it to a CSV file.
 	Exporter *metrics.Exporter
+
+	// Pusher pushes the results of every test to a Pushgateway, it is
+	// nil when pushing is disabled.
+	Pusher *metrics.Pusher
+
+	// Evaluator evaluates SLO assertions for every test, it is nil when
+	// no assertion is configured.
+	Evaluator *slo.Evaluator
+
+	// APIServerScraper scrapes the metrics of kube-apiserver before and after
+	// every test, it is nil when scraping is disabled.
+	APIServerScraper *metrics.APIServerScraper
+
+	// EtcdScraper scrapes the metrics of etcd members before and after every
+	// test, it is nil when scraping is disabled.
+	EtcdScraper *metrics.EtcdScraper
+
+	// Progress reports the progress of the run while the tests are running, it is nil
+	// when the progress dashboard is disabled.
+	Progress *metrics.Dashboard
+}
+
+// testCase is a single test of a latency-percent pair.
+type testCase struct {
+	// percentIndex is the index of the percent of the IOChaos.
+	percentIndex int
+	// latencyIndex is the index of the latency of the IOChaos.
+	latencyIndex int
+	// trial is the 1-based index of the trial when every latency-percent pair is tested
+	// several times, 0 otherwise. For baseline tests, it is the 1-based index of the
+	// baseline test when the baseline is re-tested, 0 otherwise.
+	trial int
+	// baseline indicates whether the test is a baseline test, which runs without any IOChaos.
+	baseline bool
 }
 
 // NewTestFlow instantiates a new performance testing test flow.
 func NewTestFlow(opts *options.Options) (*TestFlow, error) {
+	// Load SLO assertions before anything else, so that invalid ones fail fast.
+	assertions, err := slo.Load(opts.SLOs, opts.SLOFilePath)
+	if err != nil {
+		return nil, err
+	}
+	var evaluator *slo.Evaluator
+	if len(assertions) > 0 {
+		evaluator = slo.NewEvaluator(assertions)
+	} else if opts.ExportJUnit {
+		klog.Warning("no SLO assertion is configured, no JUnit report will be exported")
+	}
+
 	// Initialize ChaosAgent.
 	agent, err := chaosmesh.NewChaosAgent(
 		opts.IOChaosKubeconfigFilePath,
@@ -42,6 +91,10 @@ func NewTestFlow(opts *options.Options) (*TestFlow, error) {
 		return nil, err
 	}
 
+	// Feed the metrics of the workers' REST clients into the metrics registry, before any of
+	// them is instantiated.
+	metrics.InstallClientGoMetrics()
+
 	// Initialize workers.
 	var workers []*worker.Worker
 	for workerID := 0; workerID < opts.WorkerNumber; workerID++ {
@@ -52,17 +105,47 @@ func NewTestFlow(opts *options.Options) (*TestFlow, error) {
 		workers = append(workers, w)
 	}
 
+	// Configure how API request latencies are recorded and reported.
+	if err := metrics.Configure(opts.LatencyAccuracy, opts.Quantiles); err != nil {
+		return nil, err
+	}
+	metrics.ConfigureWarmUp(uint64(opts.WarmUpRequests), opts.WarmUpDuration, opts.IncludeWarmUp)
+	metrics.ConfigurePerWorker(opts.PerWorker)
+	metrics.ConfigureSummaryPlot(opts.SummaryPlot)
+
 	// Initialize report exporter.
-	var exporter *metrics.Exporter
-	if opts.WriteToCSV {
-		exporter = metrics.NewExporter(opts.Latencies, opts.PercentsStr)
+	exporter := metrics.NewExporter(opts.Latencies, opts.PercentsStr, opts.Trials)
+
+	// Initialize result pusher.
+	var pusher *metrics.Pusher
+	if len(opts.PushgatewayURL) > 0 {
+		pusher = metrics.NewPusher(opts.PushgatewayURL, opts.PushJobName, opts.RunID, opts.PushRetries, nil)
+	}
+
+	// Initialize kube-apiserver metrics scraper, which shares the REST config of the workers.
+	var apiServerScraper *metrics.APIServerScraper
+	if opts.ScrapeAPIServer && len(workers) > 0 {
+		apiServerScraper = metrics.NewAPIServerScraper(workers[0].Client.Discovery().RESTClient())
+	}
+
+	// Initialize etcd metrics scraper.
+	var etcdScraper *metrics.EtcdScraper
+	if len(opts.EtcdMetricsEndpoints) > 0 {
+		etcdScraper, err = metrics.NewEtcdScraper(opts.EtcdMetricsEndpoints, opts.EtcdCAFilePath, opts.EtcdCertFilePath, opts.EtcdKeyFilePath, opts.EtcdInsecureSkipVerify)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &TestFlow{
-		Options:  opts,
-		Agent:    agent,
-		Workers:  workers,
-		Exporter: exporter,
+		Options:          opts,
+		Agent:            agent,
+		Workers:          workers,
+		Exporter:         exporter,
+		Pusher:           pusher,
+		Evaluator:        evaluator,
+		APIServerScraper: apiServerScraper,
+		EtcdScraper:      etcdScraper,
 	}, nil
 }
 
@@ -83,21 +166,33 @@ func (flow *TestFlow) RunTestFlow(ctx context.Context) error {
 
 	klog.V(2).Info("starting test flow")
 	startTime := time.Now()
-	cancelled := false
-	for percentIndex := range flow.Percents {
-		for latencyIndex := range flow.Latencies {
-			select {
-			case <-ctx.Done():
-				klog.V(2).Info("stop signal received, stopping test flow")
-				cancelled = true
-				break
-			default:
-				if err := flow.startTestFlowWithIOChaos(testFlowContext, percentIndex, latencyIndex); err != nil {
-					return err
-				}
+	tests := flow.schedule()
+	if flow.Dashboard {
+		flow.Progress = metrics.StartDashboard(flow.DashboardRefreshInterval, flow.DashboardLineInterval)
+	}
+	defer flow.stopProgress()
+	for index, test := range tests {
+		cancelled := false
+		select {
+		case <-ctx.Done():
+			klog.V(2).Info("stop signal received, stopping test flow")
+			cancelled = true
+		default:
+			set := flow.metricSetID(test)
+			if flow.ExportTimeSeries {
+				metrics.StartTimeSeries(set, flow.TimeSeriesInterval)
+			}
+			var err error
+			if test.baseline {
+				err = flow.startBaselineTestFlow(testFlowContext, test, index, len(tests))
+			} else {
+				err = flow.startTestFlowWithIOChaos(testFlowContext, test, index, len(tests))
 			}
-			if cancelled {
-				break
+			if flow.ExportTimeSeries {
+				flow.Exporter.WriteTimeSeries(writerContext, flow.Options, startTime, set)
+			}
+			if err != nil {
+				return err
 			}
 		}
 		if cancelled {
@@ -105,38 +200,189 @@ func (flow *TestFlow) RunTestFlow(ctx context.Context) error {
 		}
 	}
 	endTime := time.Now()
+	flow.stopProgress()
 	klog.V(2).Info("finished test flow")
 	klog.V(2).Infof("test flow started at %v", startTime.Local())
 	klog.V(2).Infof("test flow finished at %v", endTime.Local())
 	klog.V(2).Infof("test flow duration: %v", endTime.Sub(startTime).String())
+	flow.Exporter.LogDrift()
+
+	// Print the heatmap of the final matrix.
+	if flow.Heatmap && len(flow.Exporter.Cells()) > 0 {
+		flow.Exporter.PrintHeatmap(flow.HeatmapVerb, flow.HeatmapQuantile)
+	}
+	// Print how latencies grow with the injected latency.
+	if len(flow.SensitivityVerbs) > 0 {
+		metrics.SensitivitySummary(flow.Exporter.Sensitivities(flow.SensitivityVerbs))
+	}
+
+	// Push the results of the run.
+	if flow.Pusher != nil {
+		// Baseline tests are not latency-percent pairs, they are neither pushed nor counted.
+		pairs := 0
+		for _, test := range tests {
+			if !test.baseline {
+				pairs++
+			}
+		}
+		flow.Pusher.PushRun(writerContext, flow.Exporter.Cells(), pairs, startTime, endTime)
+	}
 
 	// Export the final report to a CSV file.
 	if flow.WriteToCSV {
 		// Export the report to a CSV file.
 		flow.Exporter.WriteToCSV(writerContext, flow.Options, startTime)
 	}
+	// Export the HTML report with charts.
+	if flow.ExportHTML {
+		flow.Exporter.WriteHTML(writerContext, flow.Options, startTime, endTime)
+	}
+	// Export the Markdown report.
+	if flow.ExportMarkdown {
+		flow.Exporter.WriteMarkdown(writerContext, flow.Options, startTime, endTime)
+	}
+	// Export the latency histograms to a JSON file.
+	if flow.ExportHistograms {
+		flow.Exporter.WriteHistograms(writerContext, flow.Options, startTime)
+	}
+	// Export the result document to a JSON file.
+	if flow.ExportResult {
+		flow.Exporter.WriteResult(writerContext, flow.Options, startTime, endTime)
+	}
+	// Append the result document to the results archive, unless the test flow has been
+	// stopped: the trend of the archive is only followed across complete runs.
+	if len(flow.ArchiveFolderPath) > 0 {
+		if ctx.Err() != nil {
+			klog.Warningf("not appending run %v to results archive %v since the test flow has been stopped", flow.RunID, flow.ArchiveFolderPath)
+		} else {
+			flow.writeArchive(startTime, endTime)
+		}
+	}
+
+	// Report SLO evaluations, failing the test flow if any assertion failed.
+	if flow.Evaluator != nil {
+		flow.Evaluator.Summary()
+		if flow.ExportJUnit {
+			flow.writeJUnit(writerContext, startTime)
+		}
+		return flow.Evaluator.Err()
+	}
+	return nil
+}
+
+// stopProgress stops reporting the progress of the run, if the progress dashboard is enabled.
+func (flow *TestFlow) stopProgress() {
+	if flow.Progress != nil {
+		flow.Progress.Stop()
+		flow.Progress = nil
+	}
+}
+
+// print prints to stdout, hiding the live progress dashboard meanwhile.
+func (flow *TestFlow) print(printFunc func()) {
+	if flow.Progress != nil {
+		flow.Progress.Hide()
+		defer flow.Progress.Show()
+	}
+	printFunc()
+}
+
+// writeArchive appends the result document of the run to the results archive.
+func (flow *TestFlow) writeArchive(startTime, endTime time.Time) {
+	klog.V(2).Infof("appending run %v to results archive %v", flow.RunID, flow.ArchiveFolderPath)
+	if err := archive.NewArchive(flow.ArchiveFolderPath).Append(flow.Exporter.Result(flow.Options, startTime, endTime)); err != nil {
+		klog.Errorf("failed to append run %v to results archive %v: %v", flow.RunID, flow.ArchiveFolderPath, err.Error())
+		return
+	}
+	klog.V(2).Infof("successfully appended run %v to results archive %v", flow.RunID, flow.ArchiveFolderPath)
+}
+
+// writeJUnit exports the SLO evaluations to a JUnit XML file in the target folder.
+func (flow *TestFlow) writeJUnit(ctx context.Context, startTime time.Time) {
+	filepath := metrics.ExportFilePath(flow.Options, startTime, "junit.xml")
+
+	klog.V(2).Infof("writing SLO evaluations to %v", filepath)
+	if err := flow.Evaluator.ExportJUnit(ctx, flow.Exporter.Cells(), filepath); err != nil {
+		klog.Errorf("failed to export to file %v: %v", filepath, err.Error())
+		return
+	}
+	klog.V(2).Infof("successfully wrote SLO evaluations to %v", filepath)
+}
+
+// schedule returns the tests to be run by order. Every latency-percent pair is tested
+// `Trials` times back to back, unless `RandomizeTrials` is set, in which case the trials
+// of all latency-percent pairs are interleaved in a randomized order. When `Baseline` is
+// set, a baseline test is run first, and again last if `BaselineRerun` is set.
+func (flow *TestFlow) schedule() []testCase {
+	var tests []testCase
+	for percentIndex := range flow.Percents {
+		for latencyIndex := range flow.Latencies {
+			if flow.Trials <= 1 {
+				tests = append(tests, testCase{percentIndex: percentIndex, latencyIndex: latencyIndex})
+				continue
+			}
+			for trial := 1; trial <= flow.Trials; trial++ {
+				tests = append(tests, testCase{percentIndex: percentIndex, latencyIndex: latencyIndex, trial: trial})
+			}
+		}
+	}
+
+	if flow.RandomizeTrials {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		r.Shuffle(len(tests), func(i, j int) {
+			tests[i], tests[j] = tests[j], tests[i]
+		})
+	}
+
+	if flow.Baseline {
+		if !flow.BaselineRerun {
+			return append([]testCase{{baseline: true}}, tests...)
+		}
+		tests = append([]testCase{{baseline: true, trial: 1}}, tests...)
+		tests = append(tests, testCase{baseline: true, trial: 2})
+	}
+	return tests
+}
+
+// startBaselineTestFlow runs a baseline test, i.e. the actual tests without any IOChaos,
+// cleaning up the test environment before and after it.
+func (flow *TestFlow) startBaselineTestFlow(ctx context.Context, test testCase, finishedTests, totalTests int) error {
+	klog.V(2).Infof("starting tests (%v/%v) without IOChaos (baseline)", finishedTests+1, totalTests)
+
+	// Ensure the environment is clean before testing.
+	klog.V(4).Info("cleaning up testing environment before performance testing")
+	metrics.SetChaosStatus(metrics.ChaosStatusCleaningUp)
+	flow.cleanup(context.Background())
+
+	jobsCtx, jobsCancel := context.WithCancel(ctx)
+	defer func() {
+		jobsCancel()
+		flow.cleanup(context.Background())
+	}()
+
+	// Run the actual test flow.
+	metrics.SetChaosStatus(metrics.ChaosStatusBaseline)
+	if err := flow.startTestFlow(jobsCtx, test, finishedTests, totalTests); err != nil {
+		return err
+	}
+	klog.V(2).Info("successfully finished tests without IOChaos (baseline)")
 	return nil
 }
 
 // startTestFlowWithIOChaos prepares the IOChaos before running the actual tests and deletes
 // it after the test has finished.
-func (flow *TestFlow) startTestFlowWithIOChaos(ctx context.Context, percentIndex, latencyIndex int) (err error) {
-	totalTests := len(flow.Latencies) * len(flow.Percents)
-	currentTest := percentIndex*len(flow.Latencies) + latencyIndex + 1
-	klog.V(2).Infof("starting tests (%v/%v) with IOChaos (latency: %v, percent: %v)",
-		currentTest,
-		totalTests,
-		flow.Latencies[latencyIndex],
-		flow.Percents[percentIndex],
-	)
+func (flow *TestFlow) startTestFlowWithIOChaos(ctx context.Context, test testCase, finishedTests, totalTests int) (err error) {
+	set := flow.metricSetID(test)
+	klog.V(2).Infof("starting tests (%v/%v) with IOChaos (%v)", finishedTests+1, totalTests, set)
 
 	// Prepare new IOChaos.
-	ioChaos := flow.Agent.NewIOChaos(flow.Latencies[latencyIndex], flow.Percents[percentIndex])
+	ioChaos := flow.Agent.NewIOChaos(flow.Latencies[test.latencyIndex], flow.Percents[test.percentIndex])
 
 	// TODO: cleanup tasks currently return no error info, so this process might still fail, causing the test to run in an unclean environment.
 	// ALWAYS DO CLEANUP WITHOUT IOCHAOS! - RUN CLEANUP FIRST!
 	// Ensure the environment is clean before testing.
 	klog.V(4).Info("cleaning up testing environment before performance testing")
+	metrics.SetChaosStatus(metrics.ChaosStatusCleaningUp)
 	flow.cleanup(context.Background())
 
 	// ALWAYS DO CLEANUP WITHOUT IOCHAOS! - DEFER CLEANUP FIRST!
@@ -152,47 +398,100 @@ func (flow *TestFlow) startTestFlowWithIOChaos(ctx context.Context, percentIndex
 
 	// Ensure IOChaos is deleted after each test.
 	defer func() {
+		metrics.SetChaosStatus(metrics.ChaosStatusRemoving)
 		if deleteErr := flow.Agent.Delete(context.Background(), ioChaos); deleteErr != nil {
 			err = fmt.Errorf("%v: %w", deleteErr.Error(), err)
+		} else {
+			metrics.SetChaosStatus(metrics.ChaosStatusRemoved)
+			metrics.MarkEvent(set, metrics.EventChaosRemoved)
 		}
 	}()
 
 	// Create corresponding IOChaos before each test.
+	metrics.SetChaosStatus(metrics.ChaosStatusInjecting)
 	if err = flow.Agent.Create(context.Background(), ioChaos); err != nil {
 		return
 	}
+	metrics.SetChaosStatus(metrics.ChaosStatusInjected)
+	metrics.MarkEvent(set, metrics.EventChaosInjected)
 
 	// Run the actual test flow.
-	if err = flow.startTestFlow(jobsCtx, percentIndex, latencyIndex); err == nil {
-		klog.V(2).Infof("successfully finished tests with IOChaos (latency: %v, percent: %v)", flow.Latencies[latencyIndex], flow.Percents[percentIndex])
+	if err = flow.startTestFlow(jobsCtx, test, finishedTests, totalTests); err == nil {
+		klog.V(2).Infof("successfully finished tests with IOChaos (%v)", set)
 	}
 	return
 }
 
 // startTestFlow does the actual performance testing, cleaning up the test environment before and
 // after the tests.
-func (flow *TestFlow) startTestFlow(ctx context.Context, percentIndex, latencyIndex int) error {
-	set := metrics.MetricSetID{
-		Latency: flow.Latencies[latencyIndex],
-		Percent: flow.PercentsStr[percentIndex],
-	}
+func (flow *TestFlow) startTestFlow(ctx context.Context, test testCase, finishedTests, totalTests int) error {
+	set := flow.metricSetID(test)
 
 	// Performance testing workflow leverages dedicated context.
 	klog.V(4).Info("starting up testing environment before performance testing")
+	before, etcdBefore := flow.scrapeAPIServer(set), flow.scrapeEtcd(set)
+	metrics.StartTest(set, finishedTests, totalTests, flow.WorkerNumber, flow.requestsPerWorker())
+	var monitor *metrics.ResourceMonitor
+	if flow.ResourceSampleInterval > 0 {
+		monitor = metrics.StartResourceMonitor(flow.ResourceSampleInterval)
+	}
 	startTime := time.Now()
+	metrics.StartWarmUp(set)
+	metrics.MarkEvent(set, metrics.EventTestStarted)
 	flow.performanceTest(ctx, set)
+	metrics.MarkEvent(set, metrics.EventTestFinished)
 	endTime := time.Now()
+	metrics.FinishTest()
+
+	// Warn when the load generator itself was likely saturated, which corrupts the latencies.
+	var usage *metrics.ResourceUsage
+	if monitor != nil {
+		usage = monitor.Stop()
+		for _, warning := range usage.SaturationWarnings() {
+			klog.Warningf("load generator likely saturated when testing with IOChaos (%v): %v", set, warning)
+		}
+	}
 
-	// Print summary for a single test.
-	if flow.Summarize {
-		// Print the report in stdout.
-		metrics.Summary(set, flow.WorkerNumber, flow.JobsPerWorker, startTime, endTime)
+	// Compute the server-side statistics of the test.
+	var server *metrics.ServerStats
+	if after := flow.scrapeAPIServer(set); before != nil && after != nil {
+		server = metrics.DiffAPIServerMetrics(before, after)
 	}
-	// Collect metrics for final report right after a test has finished to avoid
-	// the metrics from expiring (Prometheus Summary metrics has MaxAge).
-	if flow.WriteToCSV {
-		if err := flow.Exporter.Collect(percentIndex, latencyIndex); err != nil {
-			klog.Errorf("failed to collect metrics for testing with IOChaos (latency: %v, percent: %v)", flow.Latencies[latencyIndex], flow.Percents[percentIndex])
+	var etcd *metrics.EtcdStats
+	if etcdAfter := flow.scrapeEtcd(set); etcdBefore != nil && etcdAfter != nil {
+		etcd = metrics.DiffEtcdMetrics(etcdBefore, etcdAfter)
+	}
+
+	// Collect metrics of the finished test for the final report. Baseline tests are collected
+	// apart from the latency-percent pairs.
+	var (
+		cell metrics.CellStats
+		err  error
+	)
+	if test.baseline {
+		cell = flow.Exporter.CollectBaseline(test.trial, startTime, endTime, server, etcd, usage)
+	} else {
+		cell, err = flow.Exporter.Collect(test.percentIndex, test.latencyIndex, test.trial, startTime, endTime, server, etcd, usage)
+	}
+	if err != nil {
+		klog.Errorf("failed to collect metrics for testing with IOChaos (%v)", set)
+	} else {
+		// Print summary for a single test.
+		if flow.Summarize {
+			// Print the report in stdout, comparing the test to the baseline unless it is a
+			// baseline test itself.
+			var baseline *metrics.CellStats
+			if !test.baseline {
+				baseline = flow.Exporter.Baseline()
+			}
+			flow.print(func() {
+				metrics.Summary(cell, flow.WorkerNumber, flow.JobsPerWorker, baseline)
+			})
+		}
+		// Push the metrics of the test, and evaluate them against SLOs. Baseline tests are
+		// neither pushed nor evaluated.
+		if !test.baseline {
+			flow.reportTest(ctx, cell)
 		}
 	}
 
@@ -203,6 +502,77 @@ func (flow *TestFlow) startTestFlow(ctx context.Context, percentIndex, latencyIn
 	return nil
 }
 
+// reportTest pushes the metrics of a finished test of a latency-percent pair, evaluates
+// them against SLOs, and prints the summary across trials once every trial has finished.
+func (flow *TestFlow) reportTest(ctx context.Context, cell metrics.CellStats) {
+	if flow.Pusher != nil {
+		// Pushes are given up when the test flow is stopped.
+		flow.Pusher.PushTest(ctx, cell)
+	}
+	if flow.Evaluator != nil {
+		for _, result := range flow.Evaluator.Evaluate(cell) {
+			if !result.Passed {
+				klog.Warningf("SLO %v failed with IOChaos (%v)", result.Assertion.Name, cell.Set)
+			}
+		}
+	}
+	// Print summary across trials once every trial of the latency-percent pair has finished.
+	if flow.Summarize && flow.Trials > 1 {
+		if trials := flow.Exporter.Trials(cell.Set); len(trials) == flow.Trials {
+			flow.print(func() {
+				metrics.TrialsSummary(trials, flow.WorkerNumber, flow.JobsPerWorker)
+			})
+		}
+	}
+}
+
+// scrapeAPIServer scrapes the metrics of kube-apiserver, returning nil if scraping is
+// disabled or has failed.
+func (flow *TestFlow) scrapeAPIServer(set metrics.MetricSetID) metrics.Scrape {
+	if flow.APIServerScraper == nil {
+		return nil
+	}
+	// Use `context.Background` so that the metrics of a stopped test can still be scraped.
+	scrape, err := flow.APIServerScraper.Scrape(context.Background())
+	if err != nil {
+		klog.Errorf("failed to scrape kube-apiserver metrics for testing with IOChaos (%v): %v", set, err.Error())
+		return nil
+	}
+	return scrape
+}
+
+// scrapeEtcd scrapes the metrics of etcd members, returning nil if scraping is disabled.
+// Members that failed to be scraped are left out.
+func (flow *TestFlow) scrapeEtcd(set metrics.MetricSetID) map[string]metrics.Scrape {
+	if flow.EtcdScraper == nil {
+		return nil
+	}
+	scrapes, err := flow.EtcdScraper.Scrape(context.Background())
+	if err != nil {
+		klog.Errorf("failed to scrape etcd metrics for testing with IOChaos (%v): %v", set, err.Error())
+	}
+	return scrapes
+}
+
+// metricSetID returns the identity of the metrics of a test.
+func (flow *TestFlow) metricSetID(test testCase) metrics.MetricSetID {
+	if test.baseline {
+		return metrics.BaselineSet(test.trial)
+	}
+	return metrics.MetricSetID{
+		Latency: flow.Latencies[test.latencyIndex],
+		Percent: flow.PercentsStr[test.percentIndex],
+		Trial:   test.trial,
+	}
+}
+
+// requestsPerWorker returns the number of API requests every worker is expected to send
+// in a test: a worker creates, gets, updates, patches and deletes each of its deployments,
+// and lists them once.
+func (flow *TestFlow) requestsPerWorker() uint64 {
+	return uint64(flow.JobsPerWorker*5 + 1)
+}
+
 // run tells all workers to run performance testing workflow and waits for them to complete.
 func (flow *TestFlow) performanceTest(ctx context.Context, set metrics.MetricSetID) {
 	klog.V(4).Info("performance testing has started")
@@ -0,0 +1,18 @@
+package printer
+
+import (
+	"math"
+	"strings"
+)
+
+// Bar draws a horizontal bar of a given width, filled with `#` up to a given ratio and
+// with `.` beyond it. It is the bar of plots, heatmaps and progress dashboards.
+func Bar(ratio float64, width int) string {
+	if ratio < 0 || math.IsNaN(ratio) {
+		ratio = 0
+	} else if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(math.Round(ratio * float64(width)))
+	return strings.Repeat("#", filled) + strings.Repeat(".", width-filled)
+}
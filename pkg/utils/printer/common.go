@@ -2,25 +2,31 @@ package printer
 
 import (
 	"fmt"
+	"io"
 	"strings"
 )
 
-// TODO: use logger to provide a better way to also export.
-// PrintEmptyLine prints an empty line.
+// PrintEmptyLine prints an empty line to stdout.
 func PrintEmptyLine() {
 	fmt.Println()
 }
 
-// printInBox prints content in surrounded by a symbol .
-func printInBox(symbol rune, print func()) {
-	fmt.Printf("%c", symbol)
-	print()
-	fmt.Printf("%c\n", symbol)
+// errWriter writes to a writer until the first error, which it keeps, so that rendering
+// does not have to check the error of every single write.
+type errWriter struct {
+	w   io.Writer
+	err error
 }
 
-// printDivider prints a divider with provided rune.
-func printDivider(width int) {
-	printInBox('+', func() {
-		fmt.Print(strings.Repeat("-", width))
-	})
+// print writes its operands to the writer, unless a previous write failed.
+func (w *errWriter) print(a ...interface{}) {
+	if w.err == nil {
+		_, w.err = fmt.Fprint(w.w, a...)
+	}
+}
+
+// plainText collapses the blanks used for alignment in a line, for renderers that do not
+// align their content.
+func plainText(content string) string {
+	return strings.Join(strings.Fields(content), " ")
 }
@@ -0,0 +1,69 @@
+package printer
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// DelimitedRenderer renders sheets and tables as delimiter-separated values, e.g. CSV or
+// TSV, one record per line or table row.
+type DelimitedRenderer struct {
+	// Comma is the field delimiter.
+	Comma rune
+}
+
+// RenderSheet renders a sheet: the title, every line of the header section, every table
+// and every line of the footer section. Lines are single-field records.
+func (r *DelimitedRenderer) RenderSheet(w io.Writer, s *Sheet) error {
+	writer := r.writer(w)
+	records := r.lines([]Line{s.title})
+	records = append(records, r.lines(s.header)...)
+	for index := range s.tables {
+		records = append(records, r.table(&s.tables[index])...)
+	}
+	records = append(records, r.lines(s.footer)...)
+	return writer.WriteAll(records)
+}
+
+// RenderTable renders a table: the title as a single-field record, followed by the header
+// row and the data rows.
+func (r *DelimitedRenderer) RenderTable(w io.Writer, t *Table) error {
+	return r.writer(w).WriteAll(r.table(t))
+}
+
+// writer returns a CSV writer with the delimiter of the renderer.
+func (r *DelimitedRenderer) writer(w io.Writer) *csv.Writer {
+	writer := csv.NewWriter(w)
+	writer.Comma = r.Comma
+	return writer
+}
+
+// table converts a table to records.
+func (r *DelimitedRenderer) table(t *Table) [][]string {
+	records := r.lines([]Line{t.title})
+	if t.headers != nil {
+		records = append(records, r.row(t.headers))
+	}
+	for _, row := range t.datum {
+		records = append(records, r.row(row))
+	}
+	return records
+}
+
+// row converts a table row to a record.
+func (r *DelimitedRenderer) row(row TableRow) []string {
+	record := make([]string, 0, len(row))
+	for _, entry := range row {
+		record = append(record, plainText(entry.Content))
+	}
+	return record
+}
+
+// lines converts lines to single-field records.
+func (r *DelimitedRenderer) lines(lines []Line) [][]string {
+	records := make([][]string, 0, len(lines))
+	for _, line := range lines {
+		records = append(records, []string{plainText(line.Content)})
+	}
+	return records
+}
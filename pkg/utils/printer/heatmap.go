@@ -0,0 +1,162 @@
+package printer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"golang.org/x/term"
+)
+
+const (
+	// heatmapBarWidth is the width of the bars grading the cells of monochrome heatmaps.
+	heatmapBarWidth = 5
+	// heatmapMissing is the content of the cells without value.
+	heatmapMissing = "-"
+)
+
+// heatmapGradient are the background colors (of the ANSI 256-color palette) the cells of
+// colored heatmaps are graded with, from the lowest value to the highest one.
+var heatmapGradient = []int{46, 82, 118, 154, 190, 226, 220, 214, 208, 202, 196}
+
+// Heatmap is a table of values whose cells are graded from the lowest value to the highest
+// one: by background color when colored, by bars otherwise.
+type Heatmap struct {
+	// title is the title of the heatmap.
+	title Line
+	// corner is the content of the top-left cell, e.g. the names of both axes.
+	corner string
+	// rowLabels are the labels of the rows.
+	rowLabels []string
+	// columnLabels are the labels of the columns.
+	columnLabels []string
+	// values are the values of every cell, the first index is the row index. Cells without
+	// value are NaN.
+	values [][]float64
+	// format is the format of the values.
+	format string
+	// colored indicates whether cells are graded by color.
+	colored bool
+}
+
+// NewHeatmap creates a monochrome heatmap of values indexed by row then by column, NaN
+// values being left blank.
+func NewHeatmap(title Line, corner string, rowLabels, columnLabels []string, values [][]float64) *Heatmap {
+	return &Heatmap{
+		title:        title,
+		corner:       corner,
+		rowLabels:    rowLabels,
+		columnLabels: columnLabels,
+		values:       values,
+		format:       "%v",
+	}
+}
+
+// SetTitle sets the title of the heatmap.
+func (h *Heatmap) SetTitle(title Line) *Heatmap {
+	h.title = title
+	return h
+}
+
+// SetFormat sets the format of the values, e.g. `%.3f`.
+func (h *Heatmap) SetFormat(format string) *Heatmap {
+	h.format = format
+	return h
+}
+
+// SetColored sets whether cells are graded by color instead of bars.
+func (h *Heatmap) SetColored(colored bool) *Heatmap {
+	h.colored = colored
+	return h
+}
+
+// Range returns the lowest and the highest values of the heatmap, both NaN when there is
+// no value.
+func (h *Heatmap) Range() (float64, float64) {
+	lowest, highest := math.NaN(), math.NaN()
+	for _, row := range h.values {
+		for _, value := range row {
+			if math.IsNaN(value) {
+				continue
+			}
+			if math.IsNaN(lowest) || value < lowest {
+				lowest = value
+			}
+			if math.IsNaN(highest) || value > highest {
+				highest = value
+			}
+		}
+	}
+	return lowest, highest
+}
+
+// Table generates the table of the heatmap, with one row per row label and one column per
+// column label.
+func (h *Heatmap) Table() *Table {
+	headerRow := TableRow{
+		LineAlignRight(h.corner),
+	}
+	for _, label := range h.columnLabels {
+		headerRow.AddEntry(LineAlignRight(label))
+	}
+	table := NewTable(0, headerRow.ColumnsCount(), h.title)
+	table.SetHeaders(headerRow)
+
+	lowest, highest := h.Range()
+	var tableRows []TableRow
+	for rowIndex, label := range h.rowLabels {
+		tableRow := TableRow{
+			LineAlignRight(label),
+		}
+		for columnIndex := range h.columnLabels {
+			value := math.NaN()
+			if rowIndex < len(h.values) && columnIndex < len(h.values[rowIndex]) {
+				value = h.values[rowIndex][columnIndex]
+			}
+			tableRow.AddEntry(h.cell(value, lowest, highest))
+		}
+		tableRows = append(tableRows, tableRow)
+	}
+	table.SetDatum(tableRows)
+	return table
+}
+
+// cell generates the cell of a value, graded between the lowest and the highest values.
+func (h *Heatmap) cell(value, lowest, highest float64) Line {
+	if math.IsNaN(value) {
+		return LineAlignRight(heatmapMissing)
+	}
+	level := 0.0
+	if highest > lowest {
+		level = (value - lowest) / (highest - lowest)
+	}
+
+	content := fmt.Sprintf(h.format, value)
+	if !h.colored {
+		return LineAlignRight(content + " " + Bar(level, heatmapBarWidth))
+	}
+	line := LineAlignRight(content)
+	color := heatmapGradient[int(math.Round(level*float64(len(heatmapGradient)-1)))]
+	line.Style = fmt.Sprintf("30;48;5;%d", color)
+	return line
+}
+
+// Render renders the heatmap to a writer with a renderer.
+func (h *Heatmap) Render(w io.Writer, r Renderer) error {
+	return r.RenderTable(w, h.Table())
+}
+
+// Print prints the heatmap to stdout with the default renderer.
+func (h *Heatmap) Print() {
+	_ = h.Render(os.Stdout, Default)
+}
+
+// ColorSupported returns whether colors can be drawn to a file: it must be a terminal, and
+// colors must not be disabled by the `NO_COLOR` environment variable or a dumb terminal.
+func ColorSupported(f *os.File) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
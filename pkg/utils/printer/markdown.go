@@ -0,0 +1,83 @@
+package printer
+
+import (
+	"io"
+	"strings"
+)
+
+// MarkdownRenderer renders sheets and tables in GitHub Flavored Markdown.
+type MarkdownRenderer struct{}
+
+// RenderSheet renders a sheet: the title as a heading, the header and footer sections as
+// lists and every table as a Markdown table.
+func (r *MarkdownRenderer) RenderSheet(w io.Writer, s *Sheet) error {
+	writer := &errWriter{w: w}
+	writer.print("### ", markdownText(s.title.Content), "\n\n")
+	r.list(writer, s.header)
+	for index := range s.tables {
+		r.table(writer, &s.tables[index])
+	}
+	r.list(writer, s.footer)
+	return writer.err
+}
+
+// RenderTable renders a table, the title being a bold line above the Markdown table.
+// Column alignments follow the alignments of the header row.
+func (r *MarkdownRenderer) RenderTable(w io.Writer, t *Table) error {
+	writer := &errWriter{w: w}
+	r.table(writer, t)
+	return writer.err
+}
+
+// table renders a table.
+func (r *MarkdownRenderer) table(writer *errWriter, t *Table) {
+	writer.print("**", markdownText(t.title.Content), "**\n\n")
+
+	headers := t.headers
+	if headers == nil {
+		headers = make(TableRow, t.columnsCount)
+	}
+	r.row(writer, headers)
+	delimiters := make([]string, 0, len(headers))
+	for _, header := range headers {
+		switch header.Align {
+		case RIGHT:
+			delimiters = append(delimiters, "---:")
+		case CENTER:
+			delimiters = append(delimiters, ":---:")
+		default:
+			delimiters = append(delimiters, ":---")
+		}
+	}
+	writer.print("| ", strings.Join(delimiters, " | "), " |\n")
+	for _, row := range t.datum {
+		r.row(writer, row)
+	}
+	writer.print("\n")
+}
+
+// row renders a table row as a row of a Markdown table.
+func (r *MarkdownRenderer) row(writer *errWriter, row TableRow) {
+	entries := make([]string, 0, len(row))
+	for _, entry := range row {
+		entries = append(entries, markdownText(entry.Content))
+	}
+	writer.print("| ", strings.Join(entries, " | "), " |\n")
+}
+
+// list renders lines as a Markdown list, nothing is rendered without lines.
+func (r *MarkdownRenderer) list(writer *errWriter, lines []Line) {
+	if len(lines) == 0 {
+		return
+	}
+	for _, line := range lines {
+		writer.print("- ", markdownText(line.Content), "\n")
+	}
+	writer.print("\n")
+}
+
+// markdownText collapses the blanks used for alignment in plain text, and escapes the
+// characters that would break Markdown tables or emphasis.
+func markdownText(content string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`).Replace(plainText(content))
+}
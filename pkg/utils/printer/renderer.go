@@ -0,0 +1,190 @@
+package printer
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// Renderer renders sheets and tables to a writer.
+type Renderer interface {
+	// RenderSheet renders a sheet.
+	RenderSheet(w io.Writer, s *Sheet) error
+	// RenderTable renders a table.
+	RenderTable(w io.Writer, t *Table) error
+}
+
+var (
+	// ASCII renders sheets and tables in boxes drawn with ASCII characters.
+	ASCII Renderer = &BoxRenderer{Style: ASCIIBoxStyle}
+	// Unicode renders sheets and tables in boxes drawn with Unicode box-drawing characters.
+	Unicode Renderer = &BoxRenderer{Style: UnicodeBoxStyle}
+	// Markdown renders sheets and tables in GitHub Flavored Markdown.
+	Markdown Renderer = &MarkdownRenderer{}
+	// CSV renders sheets and tables as comma-separated values.
+	CSV Renderer = &DelimitedRenderer{Comma: ','}
+	// TSV renders sheets and tables as tab-separated values.
+	TSV Renderer = &DelimitedRenderer{Comma: '\t'}
+
+	// Renderers are the built-in renderers by name.
+	Renderers = map[string]Renderer{
+		"ascii":    ASCII,
+		"unicode":  Unicode,
+		"markdown": Markdown,
+		"csv":      CSV,
+		"tsv":      TSV,
+	}
+
+	// Default is the renderer used by `Print`.
+	Default = ASCII
+)
+
+// RendererNames returns the names of the built-in renderers by ascending order.
+func RendererNames() []string {
+	names := make([]string, 0, len(Renderers))
+	for name := range Renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BoxStyle is the set of strings boxes are drawn with. Every string must be a single
+// character wide.
+type BoxStyle struct {
+	// Horizontal fills dividers.
+	Horizontal string
+	// Vertical surrounds lines and separates columns.
+	Vertical string
+	// DividerLeft starts dividers.
+	DividerLeft string
+	// DividerRight ends dividers.
+	DividerRight string
+	// Spacer fills the spacers between the sections of a sheet.
+	Spacer string
+}
+
+var (
+	// ASCIIBoxStyle draws boxes with ASCII characters.
+	ASCIIBoxStyle = BoxStyle{
+		Horizontal:   "-",
+		Vertical:     "|",
+		DividerLeft:  "+",
+		DividerRight: "+",
+		Spacer:       "|",
+	}
+	// UnicodeBoxStyle draws boxes with Unicode box-drawing characters.
+	UnicodeBoxStyle = BoxStyle{
+		Horizontal:   "─",
+		Vertical:     "│",
+		DividerLeft:  "├",
+		DividerRight: "┤",
+		Spacer:       "░",
+	}
+)
+
+// BoxRenderer renders sheets and tables in boxes, aligning their content in columns.
+type BoxRenderer struct {
+	// Style is the style of the boxes.
+	Style BoxStyle
+}
+
+// RenderSheet renders a sheet: the title, the header section, every table and the footer
+// section, separated by dividers and spacers.
+func (r *BoxRenderer) RenderSheet(w io.Writer, s *Sheet) error {
+	s.DetermineWidth()
+	writer := &errWriter{w: w}
+
+	// Title section.
+	r.divider(writer, s.width)
+	r.lines(writer, []Line{s.title}, s.width)
+	r.divider(writer, s.width)
+
+	// Header section.
+	if s.header != nil {
+		r.lines(writer, s.header, s.width)
+		r.divider(writer, s.width)
+	}
+
+	if s.footer != nil || s.tables != nil {
+		r.spacer(writer, s.width)
+	}
+
+	// Table sections.
+	for index := range s.tables {
+		table := s.tables[index]
+		r.table(writer, &table)
+		if s.footer != nil || index < len(s.tables)-1 {
+			r.spacer(writer, s.width)
+		}
+	}
+
+	// Footer section.
+	if s.footer != nil {
+		r.divider(writer, s.width)
+		r.lines(writer, s.footer, s.width)
+		r.divider(writer, s.width)
+	}
+	return writer.err
+}
+
+// RenderTable renders a table: the title, the header row and the data rows, separated by
+// dividers.
+func (r *BoxRenderer) RenderTable(w io.Writer, t *Table) error {
+	writer := &errWriter{w: w}
+	r.table(writer, t)
+	return writer.err
+}
+
+// table renders a table.
+func (r *BoxRenderer) table(writer *errWriter, t *Table) {
+	t.DetermineWidth()
+
+	// Title section.
+	r.divider(writer, t.width)
+	r.lines(writer, []Line{t.title}, t.width)
+	r.divider(writer, t.width)
+
+	// Index section.
+	if t.headers != nil {
+		r.row(writer, t, t.headers)
+		r.divider(writer, t.width)
+	}
+
+	// Datum section.
+	if t.datum != nil {
+		for _, row := range t.datum {
+			r.row(writer, t, row)
+		}
+		r.divider(writer, t.width)
+	}
+}
+
+// row renders a table row inside a table (with the table's column widths).
+func (r *BoxRenderer) row(writer *errWriter, t *Table, row TableRow) {
+	if !row.Valid(t) {
+		return
+	}
+	entries := make([]string, 0, len(row))
+	for index, entry := range row {
+		entries = append(entries, entry.Styled(t.columnWidths[index]))
+	}
+	writer.print(r.Style.Vertical, strings.Join(entries, r.Style.Vertical), r.Style.Vertical, "\n")
+}
+
+// lines renders lines of a given width, each surrounded by vertical lines.
+func (r *BoxRenderer) lines(writer *errWriter, lines []Line, width int) {
+	for _, line := range lines {
+		writer.print(r.Style.Vertical, line.Styled(width), r.Style.Vertical, "\n")
+	}
+}
+
+// divider renders a divider of a given width.
+func (r *BoxRenderer) divider(writer *errWriter, width int) {
+	writer.print(r.Style.DividerLeft, strings.Repeat(r.Style.Horizontal, width), r.Style.DividerRight, "\n")
+}
+
+// spacer renders a spacer of a given width.
+func (r *BoxRenderer) spacer(writer *errWriter, width int) {
+	writer.print(r.Style.Vertical, strings.Repeat(r.Style.Spacer, width), r.Style.Vertical, "\n")
+}
@@ -0,0 +1,82 @@
+package printer
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrites the golden files with the current output of the renderers.
+var update = flag.Bool("update", false, "update the golden files")
+
+// newTestSheet instantiates a sheet with every section, whose content mixes ASCII and
+// multi-byte characters.
+func newTestSheet() *Sheet {
+	table := NewTable(0, 3, LineAlignCenter("Latency (µs)"))
+	table.SetHeaders(TableRow{
+		LineAlignLeft("Verb"),
+		LineAlignRight("Requests"),
+		LineAlignRight("Médiane"),
+	})
+	table.SetDatum([]TableRow{
+		{LineAlignLeft("CREATE"), LineAlignRight("100"), LineAlignRight("12.5")},
+		{LineAlignLeft("GET → cached"), LineAlignRight("2000"), LineAlignRight("1.25")},
+		{LineAlignLeft("DELETE"), LineAlignRight("7"), LineAlignRight("±0.5")},
+	})
+
+	sheet := NewSheet(0, LineAlignCenter("Performance Test Result"))
+	sheet.SetHeader([]Line{
+		LineAlignRight("Latency: 10ms"),
+		LineAlignRight("Percent: 50"),
+	})
+	sheet.SetTables([]Table{*table})
+	sheet.SetFooter([]Line{
+		LineAlignLeft("Success rate: 99.5%"),
+		LineAlignLeft("Durée: 1m30s"),
+	})
+	return sheet
+}
+
+func TestRenderSheetGolden(t *testing.T) {
+	for _, name := range RendererNames() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := newTestSheet().Render(&buffer, Renderers[name]); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buffer.String(); got != string(want) {
+				t.Errorf("got rendered sheet:\n%v\nwant:\n%v", got, string(want))
+			}
+		})
+	}
+}
+
+func TestLineFormatPadsByRunes(t *testing.T) {
+	for _, test := range []struct {
+		line Line
+		want string
+	}{
+		{line: LineAlignLeft("µs"), want: "µs   "},
+		{line: LineAlignRight("µs"), want: "   µs"},
+		{line: LineAlignCenter("µs"), want: " µs  "},
+		{line: LineAlignLeft("Durée: 1m30s"), want: "Durée: 1m30s"},
+	} {
+		if got := test.line.Format(5); got != test.want {
+			t.Errorf("got %q formatting %q, want %q", got, test.line.Content, test.want)
+		}
+	}
+}
@@ -1,8 +1,8 @@
 package printer
 
 import (
-	"fmt"
-	"strings"
+	"io"
+	"os"
 )
 
 // Sheet is an output format where there is a title, followed by a header section,
@@ -157,50 +157,12 @@ func (s *Sheet) DetermineWidth() *Sheet {
 	return s
 }
 
-// Print prints the sheet.
-func (s *Sheet) Print() {
-	s.DetermineWidth()
-
-	// Title section.
-	printDivider(s.width)
-	s.printLines([]Line{s.title})
-	printDivider(s.width)
-
-	// Header section.
-	if s.header != nil {
-		s.printLines(s.header)
-		printDivider(s.width)
-	}
-
-	if s.footer != nil || s.tables != nil {
-		printInBox('|', func() {
-			fmt.Print(strings.Repeat("|", s.width))
-		})
-	}
-
-	// Table sections.
-	for index, table := range s.tables {
-		table.Print()
-		if s.footer != nil || index < len(s.tables)-1 {
-			printInBox('|', func() {
-				fmt.Print(strings.Repeat("|", s.width))
-			})
-		}
-	}
-
-	// Footer section.
-	if s.footer != nil {
-		printDivider(s.width)
-		s.printLines(s.footer)
-		printDivider(s.width)
-	}
+// Render renders the sheet to a writer with a renderer.
+func (s *Sheet) Render(w io.Writer, r Renderer) error {
+	return r.RenderSheet(w, s)
 }
 
-// printLines prints a line of the sheet with surrounded `|`.
-func (s *Sheet) printLines(lines []Line) {
-	for _, line := range lines {
-		printInBox('|', func() {
-			line.Print(s.width)
-		})
-	}
+// Print prints the sheet to stdout with the default renderer.
+func (s *Sheet) Print() {
+	_ = s.Render(os.Stdout, Default)
 }
@@ -1,5 +1,10 @@
 package printer
 
+import (
+	"io"
+	"os"
+)
+
 // Table is an output format where there is a title, followed by a header row,
 // followed by data rows.
 type Table struct {
@@ -141,6 +146,11 @@ func (t *Table) SuggestWidth() *Table {
 				suggestedWidth++
 			}
 		}
+		// Widen the last column if the title is wider than all columns.
+		if t.columnsCount > 0 && suggestedWidth < t.title.Len() {
+			suggestedColumnWidths[t.columnsCount-1] += t.title.Len() - suggestedWidth
+			suggestedWidth = t.title.Len()
+		}
 		t.suggestedWidth = suggestedWidth
 		t.suggestedColumnWidths = suggestedColumnWidths
 		t.suggested = true
@@ -183,28 +193,12 @@ func (t *Table) DetermineWidth() *Table {
 	return t
 }
 
-// Print prints the table.
-func (t *Table) Print() {
-	t.DetermineWidth()
-
-	// Title section.
-	printDivider(t.width)
-	printInBox('|', func() {
-		t.title.Print(t.width)
-	})
-	printDivider(t.width)
-
-	// Index section.
-	if t.headers != nil {
-		t.headers.Print(t)
-		printDivider(t.width)
-	}
+// Render renders the table to a writer with a renderer.
+func (t *Table) Render(w io.Writer, r Renderer) error {
+	return r.RenderTable(w, t)
+}
 
-	// Datum section.
-	if t.datum != nil {
-		for _, row := range t.datum {
-			row.Print(t)
-		}
-		printDivider(t.width)
-	}
+// Print prints the table to stdout with the default renderer.
+func (t *Table) Print() {
+	_ = t.Render(os.Stdout, Default)
 }
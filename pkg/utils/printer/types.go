@@ -1,8 +1,8 @@
 package printer
 
 import (
-	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
 // Alignment indicates where the line should be aligned.
@@ -25,20 +25,6 @@ func (r *TableRow) Valid(t *Table) bool {
 	return r.ColumnsCount() == t.columnsCount
 }
 
-// Print prints a table row inside a table (with the table's column widths).
-func (r *TableRow) Print(t *Table) {
-	if r.Valid(t) {
-		printInBox('|', func() {
-			for index, entry := range *r {
-				if index != 0 {
-					fmt.Print("|")
-				}
-				entry.Print(t.columnWidths[index])
-			}
-		})
-	}
-}
-
 // AddEntry adds an entry to the table row.
 func (r *TableRow) AddEntry(entry Line) {
 	*r = append(*r, entry)
@@ -55,36 +41,43 @@ type Line struct {
 	Content string
 	// Align is the alignment of the line.
 	Align Alignment
+	// Style is the ANSI SGR parameters (e.g. `1;31` for bold red) the line is drawn with by
+	// renderers that draw to terminals, other renderers ignore it. No style is applied when
+	// it is empty.
+	Style string
 }
 
-// Len returns the length of the line.
+// Len returns the length of the line, i.e. the number of characters of its content.
 func (l *Line) Len() int {
-	return len(l.Content)
+	return utf8.RuneCountInString(l.Content)
+}
+
+// Format pads the line to a restricted area (width) according to its alignment. A line
+// longer than the width is not truncated.
+func (l *Line) Format(width int) string {
+	blanks := width - l.Len()
+	if blanks <= 0 {
+		return l.Content
+	}
+	switch l.Align {
+	case RIGHT:
+		return strings.Repeat(" ", blanks) + l.Content
+	case CENTER:
+		left := blanks >> 1
+		return strings.Repeat(" ", left) + l.Content + strings.Repeat(" ", blanks-left)
+	default:
+		// By default align to the left side.
+		return l.Content + strings.Repeat(" ", blanks)
+	}
 }
 
-// Print prints the line inside a restricted area (width), its alignment will
-// be considered when printing in that area.
-func (l *Line) Print(width int) {
-	if width < len(l.Content) {
-		fmt.Print(l.Content)
-	} else {
-		switch l.Align {
-		case LEFT:
-			fmt.Printf("%-*v", width, l.Content)
-		case RIGHT:
-			fmt.Printf("%*v", width, l.Content)
-		case CENTER:
-			blanks := width - len(l.Content)
-			left, right := blanks>>1, blanks>>1
-			if blanks%2 != 0 {
-				right++
-			}
-			fmt.Printf("%*v%v%*v", left, strings.Repeat(" ", left), l.Content, right, strings.Repeat(" ", right))
-		default:
-			// By default align to the left side.
-			fmt.Printf("%-*v", width, l.Content)
-		}
+// Styled formats the line like `Format`, surrounding it with the ANSI escape sequences of
+// its style.
+func (l *Line) Styled(width int) string {
+	if len(l.Style) == 0 {
+		return l.Format(width)
 	}
+	return "\033[" + l.Style + "m" + l.Format(width) + "\033[0m"
 }
 
 // LineAlignLeft creates a line that aligns its content to the left side.
@@ -29,16 +29,16 @@ func (w *Worker) testCreateDeployments(ctx context.Context, numberOfJobs int, se
 			deployment.Spec.Template.Labels[WorkerIDLabel] = fmt.Sprint(w.ID)
 
 			startTime := time.Now()
-			if createdDeployment, err := w.Client.AppsV1().Deployments("default").Create(ctx, deployment, metav1.CreateOptions{}); err != nil {
+			if createdDeployment, err := w.Client.AppsV1().Deployments("default").Create(metrics.WithAPIRequest(ctx, constants.CREATE, set), deployment, metav1.CreateOptions{}); err != nil {
 				if errors.IsAlreadyExists(err) {
 					w.Deployments = append(w.Deployments, deployment)
 					klog.V(4).Infof("[worker %v] finds that deployment %v already exists", w.ID, deployment.Name)
 				} else {
-					metrics.RecordAPIRequest(constants.CREATE, false, utils.GetDurationSince(startTime), set)
+					metrics.RecordAPIRequest(constants.CREATE, err, utils.GetDurationSince(startTime), set, w.ID)
 					klog.Errorf("[worker %v] has failed to create deployment %v: %v", w.ID, deployment.Name, err.Error())
 				}
 			} else {
-				metrics.RecordAPIRequest(constants.CREATE, true, utils.GetDurationSince(startTime), set)
+				metrics.RecordAPIRequest(constants.CREATE, nil, utils.GetDurationSince(startTime), set, w.ID)
 				w.Deployments = append(w.Deployments, deployment)
 				klog.V(4).Infof("[worker %v] has successfully created deployment %v", w.ID, createdDeployment.Name)
 			}
@@ -54,11 +54,11 @@ func (w *Worker) testGetDeployments(ctx context.Context, set metrics.MetricSetID
 			return
 		default:
 			startTime := time.Now()
-			if gotDeployment, err := w.Client.AppsV1().Deployments("default").Get(ctx, deployment.Name, metav1.GetOptions{}); err != nil {
-				metrics.RecordAPIRequest(constants.GET, false, utils.GetDurationSince(startTime), set)
+			if gotDeployment, err := w.Client.AppsV1().Deployments("default").Get(metrics.WithAPIRequest(ctx, constants.GET, set), deployment.Name, metav1.GetOptions{}); err != nil {
+				metrics.RecordAPIRequest(constants.GET, err, utils.GetDurationSince(startTime), set, w.ID)
 				klog.Errorf("[worker %v] has failed to get deployment %v: %v", w.ID, deployment.Name, err.Error())
 			} else {
-				metrics.RecordAPIRequest(constants.GET, true, utils.GetDurationSince(startTime), set)
+				metrics.RecordAPIRequest(constants.GET, nil, utils.GetDurationSince(startTime), set, w.ID)
 				klog.V(4).Infof("[worker %v] has successfully got deployment %v", w.ID, gotDeployment.Name)
 			}
 		}
@@ -75,11 +75,11 @@ func (w *Worker) testUpdateDeployments(ctx context.Context, set metrics.MetricSe
 			deployment.Annotations = UpdateData
 
 			startTime := time.Now()
-			if updatedDeployment, err := w.Client.AppsV1().Deployments("default").Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
-				metrics.RecordAPIRequest(constants.UPDATE, false, utils.GetDurationSince(startTime), set)
+			if updatedDeployment, err := w.Client.AppsV1().Deployments("default").Update(metrics.WithAPIRequest(ctx, constants.UPDATE, set), deployment, metav1.UpdateOptions{}); err != nil {
+				metrics.RecordAPIRequest(constants.UPDATE, err, utils.GetDurationSince(startTime), set, w.ID)
 				klog.Errorf("[worker %v] has failed to update deployment %v: %v", w.ID, deployment.Name, err.Error())
 			} else {
-				metrics.RecordAPIRequest(constants.UPDATE, true, utils.GetDurationSince(startTime), set)
+				metrics.RecordAPIRequest(constants.UPDATE, nil, utils.GetDurationSince(startTime), set, w.ID)
 				w.Deployments[index] = deployment
 				klog.V(4).Infof("[worker %v] has successfully updated deployment %v", w.ID, updatedDeployment.Name)
 			}
@@ -95,11 +95,11 @@ func (w *Worker) testPatchDeployments(ctx context.Context, set metrics.MetricSet
 			return
 		default:
 			startTime := time.Now()
-			if patchedDeployment, err := w.Client.AppsV1().Deployments("default").Patch(ctx, deployment.Name, types.JSONPatchType, PatchData, metav1.PatchOptions{}); err != nil {
-				metrics.RecordAPIRequest(constants.PATCH, false, utils.GetDurationSince(startTime), set)
+			if patchedDeployment, err := w.Client.AppsV1().Deployments("default").Patch(metrics.WithAPIRequest(ctx, constants.PATCH, set), deployment.Name, types.JSONPatchType, PatchData, metav1.PatchOptions{}); err != nil {
+				metrics.RecordAPIRequest(constants.PATCH, err, utils.GetDurationSince(startTime), set, w.ID)
 				klog.Errorf("[worker %v] has failed to patch deployment %v: %v", w.ID, deployment.Name, err.Error())
 			} else {
-				metrics.RecordAPIRequest(constants.PATCH, true, utils.GetDurationSince(startTime), set)
+				metrics.RecordAPIRequest(constants.PATCH, nil, utils.GetDurationSince(startTime), set, w.ID)
 				klog.V(4).Infof("[worker %v] has successfully patched deployment %v", w.ID, patchedDeployment.Name)
 			}
 		}
@@ -113,7 +113,7 @@ func (w *Worker) testListDeployments(ctx context.Context, set metrics.MetricSetI
 		return
 	default:
 		startTime := time.Now()
-		if _, err := w.Client.AppsV1().Deployments("default").List(ctx, metav1.ListOptions{
+		if _, err := w.Client.AppsV1().Deployments("default").List(metrics.WithAPIRequest(ctx, constants.LIST, set), metav1.ListOptions{
 			LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					AppLabel:      AppName,
@@ -121,10 +121,10 @@ func (w *Worker) testListDeployments(ctx context.Context, set metrics.MetricSetI
 				},
 			}),
 		}); err != nil {
-			metrics.RecordAPIRequest(constants.LIST, false, utils.GetDurationSince(startTime), set)
+			metrics.RecordAPIRequest(constants.LIST, err, utils.GetDurationSince(startTime), set, w.ID)
 			klog.Errorf("[worker %v] has failed to list deployments: %v", w.ID, err.Error())
 		} else {
-			metrics.RecordAPIRequest(constants.LIST, true, utils.GetDurationSince(startTime), set)
+			metrics.RecordAPIRequest(constants.LIST, nil, utils.GetDurationSince(startTime), set, w.ID)
 			klog.V(4).Infof("[worker %v] has successfully listed deployments", w.ID)
 		}
 	}
@@ -138,11 +138,11 @@ func (w *Worker) testDeleteDeployments(ctx context.Context, set metrics.MetricSe
 			return
 		default:
 			startTime := time.Now()
-			if err := w.Client.AppsV1().Deployments("default").Delete(ctx, deployment.Name, metav1.DeleteOptions{}); err != nil {
-				metrics.RecordAPIRequest(constants.DELETE, false, utils.GetDurationSince(startTime), set)
+			if err := w.Client.AppsV1().Deployments("default").Delete(metrics.WithAPIRequest(ctx, constants.DELETE, set), deployment.Name, metav1.DeleteOptions{}); err != nil {
+				metrics.RecordAPIRequest(constants.DELETE, err, utils.GetDurationSince(startTime), set, w.ID)
 				klog.Errorf("[worker %v] has failed to delete deployment %v: %v", w.ID, deployment.Name, err.Error())
 			} else {
-				metrics.RecordAPIRequest(constants.DELETE, true, utils.GetDurationSince(startTime), set)
+				metrics.RecordAPIRequest(constants.DELETE, nil, utils.GetDurationSince(startTime), set, w.ID)
 				w.Deployments[index] = nil
 				klog.V(4).Infof("[worker %v] has successfully deleted deployment %v", w.ID, deployment.Name)
 			}
@@ -3,6 +3,7 @@ package worker
 import (
 	"context"
 	"sync"
+	"time"
 
 	v1 "k8s.io/api/apps/v1"
 	"k8s.io/client-go/kubernetes"
@@ -67,12 +68,14 @@ func (w *Worker) Run(ctx context.Context, numberOfJobs int, wg *sync.WaitGroup,
 	klog.V(4).Infof("[worker %v] has started performance testing", w.ID)
 
 	w.Deployments = nil
+	startTime := time.Now()
 	w.testCreateDeployments(ctx, numberOfJobs, set)
 	w.testGetDeployments(ctx, set)
 	w.testUpdateDeployments(ctx, set)
 	w.testPatchDeployments(ctx, set)
 	w.testListDeployments(ctx, set)
 	w.testDeleteDeployments(ctx, set)
+	metrics.RecordWorkerRun(w.ID, set, startTime, time.Now())
 
 	klog.V(4).Infof("[worker %v] performance testing done!", w.ID)
 }